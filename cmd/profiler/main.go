@@ -24,9 +24,13 @@ func main() {
 	// ---- CLI Flags ----
 	var cfgPath string
 	var showVersion bool
+	var replayPath string
+	var replaySpeed float64
 
 	flag.StringVar(&cfgPath, "config", "", "Path to configuration file (YAML or JSON)")
 	flag.BoolVar(&showVersion, "version", false, "Print version information and exit")
+	flag.StringVar(&replayPath, "replay", "", "Replay a trace recorded with trace_record_path instead of sampling this process")
+	flag.Float64Var(&replaySpeed, "replay-speed", 60, "Replay speed-up factor (0 = as fast as possible)")
 	flag.Parse()
 
 	if showVersion {
@@ -46,7 +50,27 @@ func main() {
 	logger.Info("starting goprof-optimizer", "version", version.String())
 
 	// ---- Profiler ----
-	prof := profiler.NewProfiler(cfg, logger)
+	var prof *profiler.Profiler
+	var trace *profiler.TraceReader
+	if replayPath != "" {
+		trace, err = profiler.OpenTrace(replayPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open trace: %v\n", err)
+			os.Exit(1)
+		}
+		defer trace.Close()
+
+		// A replay must not act on this process or overwrite the trace, and
+		// keeps its history in memory.
+		cfg.GCControllerEnabled = false
+		cfg.ProfileCaptureEnabled = false
+		cfg.TraceRecordPath = ""
+		// The replay moves the clock to each record's time.
+		clock := profiler.NewManualClock(time.Time{})
+		prof = profiler.NewProfilerWithOptions(cfg, logger, profiler.Options{Clock: clock})
+	} else {
+		prof = profiler.NewProfiler(cfg, logger)
+	}
 	alertEngine := alerts.NewEngine()
 	healthChecker := health.NewChecker(cfg, prof)
 
@@ -77,9 +101,13 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// ---- Start profiler sampling ----
-	logger.Info("starting profiler sampling")
-	prof.Start(ctx)
+	// ---- Start profiler sampling, or the replay ----
+	if trace != nil {
+		go replayTrace(ctx, prof, trace, alertEngine, cfg, replaySpeed, logger)
+	} else {
+		logger.Info("starting profiler sampling")
+		prof.Start(ctx)
+	}
 
 	// ---- Start HTTP server ----
 	go func() {
@@ -129,5 +157,12 @@ func main() {
 		}
 	}
 
+	// Stop sampling before closing the history store so the last snapshot
+	// is flushed.
+	cancel()
+	if err := prof.Close(); err != nil {
+		logger.Error("history store close failed", "error", err.Error())
+	}
+
 	logger.Info("shutdown complete")
 }
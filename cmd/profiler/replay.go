@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/alerts"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+// replayTrace feeds trace through prof at speed, evaluating the built-in
+// alert rules on every replayed sample and logging when alerts are raised
+// and cleared. The latest alerts are kept in engine.
+func replayTrace(ctx context.Context, prof *profiler.Profiler, trace *profiler.TraceReader, engine *alerts.Engine,
+	cfg config.ProfilerConfig, speed float64, logger logging.Logger) {
+	active := make(map[string]bool)
+	raised := 0
+	onSample := func(snap profiler.ProfilerSnapshot) {
+		built := alerts.BuildAlertsFromSnapshot(snap, prof.Suggestions(), cfg, snap.Timestamp)
+		engine.Replace(built)
+
+		seen := make(map[string]bool, len(built))
+		for _, a := range built {
+			seen[a.ID] = true
+			if !active[a.ID] {
+				raised++
+				logger.Info("replay: alert raised", "at", snap.Timestamp, "id", a.ID,
+					"severity", a.Severity, "message", a.Message)
+			}
+		}
+		for id := range active {
+			if !seen[id] {
+				logger.Info("replay: alert cleared", "at", snap.Timestamp, "id", id)
+			}
+		}
+		active = seen
+	}
+
+	logger.Info("replaying trace", "started_at", trace.Header.StartedAt, "speed", speed)
+	n, err := prof.Replay(ctx, trace, profiler.ReplayOptions{Speed: speed, OnSample: onSample})
+	switch {
+	case errors.Is(err, context.Canceled):
+		logger.Info("replay stopped", "samples", n)
+		return
+	case err != nil:
+		logger.Error("replay failed", "samples", n, "error", err.Error())
+		return
+	}
+	if trace.Truncated() {
+		logger.Warn("trace ends in an incomplete record; replayed up to the last complete one")
+	}
+	logger.Info("replay complete; still serving the HTTP API",
+		"samples", n, "alerts_raised", raised, "last_sample", prof.LastSampleTime())
+}
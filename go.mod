@@ -1,6 +1,6 @@
 module github.com/abhishekchauhan17/goprof-optimizer
 
-go 1.22
+go 1.24
 
 require (
 	github.com/prometheus/client_golang v1.19.0
@@ -48,6 +48,64 @@ func BuildAlertsFromSnapshot(
 		})
 	}
 
+	// Rule 2b: Memory forecast to reach GOMEMLIMIT or the cgroup limit
+	// within OOMAlertMinutes.
+	if f := snap.Forecast; cfg.OOMAlertMinutes > 0 && f.LimitBytes > 0 && f.SecondsToLimit >= 0 {
+		window := float64(cfg.OOMAlertMinutes) * 60
+		if f.SecondsToLimit <= window {
+			severity := "warning"
+			if f.SecondsToLimit <= window/4 {
+				severity = "critical"
+			}
+			eta := (time.Duration(f.SecondsToLimit) * time.Second).Round(time.Minute)
+			out = append(out, Alert{
+				ID:       "oom-forecast",
+				Severity: severity,
+				Message: "Memory is predicted to reach the " + f.LimitSource + " limit of " + formatMiB(float64(f.LimitBytes)) +
+					" in ~" + eta.String() + " (now " + formatMiB(float64(f.UsageBytes)) + ", growing ~" +
+					formatMiB(f.GrowthBytesPerHour) + "/h).",
+				Source:    "forecast",
+				CreatedAt: now,
+			})
+		}
+	}
+
+	// Rule 2c: Container memory from the cgroup.
+	if snap.CgroupLimitBytes > 0 && cfg.CgroupUsageThresholdPercent > 0 {
+		pct := float64(snap.CgroupWorkingSetBytes) / float64(snap.CgroupLimitBytes) * 100
+		if pct >= cfg.CgroupUsageThresholdPercent {
+			out = append(out, Alert{
+				ID:       "cgroup-usage-high",
+				Severity: "critical",
+				Message: "Cgroup working set is " + formatMiB(float64(snap.CgroupWorkingSetBytes)) + " (" + formatPercent(pct) +
+					"% of the " + formatMiB(float64(snap.CgroupLimitBytes)) + " limit); the container is close to being OOM-killed.",
+				Source:    "cgroup",
+				CreatedAt: now,
+			})
+		}
+	}
+	if snap.CgroupOOMKills > 0 {
+		out = append(out, Alert{
+			ID:        "cgroup-oom-kill",
+			Severity:  "warning",
+			Message:   "The cgroup has recorded " + itoa(int64(snap.CgroupOOMKills)) + " OOM kill(s); processes in this container ran out of memory.",
+			Source:    "cgroup",
+			CreatedAt: now,
+		})
+	}
+
+	// Rule 2d: The profiler's own overhead over its budget.
+	if b := cfg.SelfOverheadBudgetPercent; b > 0 && snap.SelfOverheadPercent > b {
+		out = append(out, Alert{
+			ID:       "self-overhead",
+			Severity: "warning",
+			Message: "The profiler used " + profilerPercent(snap.SelfOverheadPercent) + "% of one CPU over the last minute (budget " +
+				profilerPercent(b) + "%); raise sampling_interval_ms or alloc_sample_rate.",
+			Source:    "self",
+			CreatedAt: now,
+		})
+	}
+
 	// Rule 3: Any retention entry above MemorySpikeThresholdPercent.
 	for _, rs := range snap.TopRetentions {
 		if rs.RetainedPercent >= cfg.MemorySpikeThresholdPercent {
@@ -68,9 +126,10 @@ func BuildAlertsFromSnapshot(
 		}
 	}
 
-	// Rule 4: Escalate if there are critical suggestions.
+	// Rule 4: Escalate if there are critical suggestions. Leak and non-Go
+	// memory suggestions are reported by rule 5.
 	for _, s := range suggestions {
-		if s.Severity == "critical" {
+		if s.Severity == "critical" && !ownAlertKind(s.Kind) {
 			out = append(out, Alert{
 				ID:        "critical-suggestion-" + s.TypeName + "-" + s.Tag,
 				Severity:  "critical",
@@ -81,9 +140,39 @@ func BuildAlertsFromSnapshot(
 		}
 	}
 
+	// Rule 5: Growth trends found by leak detection, whose message carries
+	// the estimated growth per hour, and RSS dominated by non-Go memory.
+	for _, s := range suggestions {
+		var id, source string
+		switch s.Kind {
+		case profiler.SuggestionLeak:
+			id, source = "leak-heap", "leak"
+			if s.TypeName != "" || s.Tag != "" {
+				id = "leak-" + s.TypeName + "-" + s.Tag
+			}
+		case profiler.SuggestionNonGoMemory:
+			id, source = "non-go-memory", "rss"
+		default:
+			continue
+		}
+		out = append(out, Alert{
+			ID:        id,
+			Severity:  s.Severity,
+			Message:   s.Message,
+			Source:    source,
+			CreatedAt: now,
+		})
+	}
+
 	return out
 }
 
+// ownAlertKind reports whether suggestions of kind are turned into alerts
+// whatever their severity.
+func ownAlertKind(kind string) bool {
+	return kind == profiler.SuggestionLeak || kind == profiler.SuggestionNonGoMemory
+}
+
 func formatPercent(v float64) string {
 	// We don't need super-precise formatting here.
 	if v < 0 {
@@ -95,6 +184,11 @@ func formatPercent(v float64) string {
 	return profilerPercent(v)
 }
 
+// formatMiB renders a byte count in MiB with one decimal.
+func formatMiB(v float64) string {
+	return profilerFormatFloat(v/(1<<20), 1) + " MiB"
+}
+
 // profilerPercent reuses profiler's minimal float formatter.
 // We keep this function separate to avoid importing fmt here.
 func profilerPercent(v float64) string {
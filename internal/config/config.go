@@ -1,5 +1,9 @@
 package config
 
+// MaxDeepSizeNodes is the hard cap on values a single deep size walk visits,
+// whatever DeepSizeMaxNodes says.
+const MaxDeepSizeNodes = 1 << 20
+
 // ProfilerConfig holds all configuration for the goprof-optimizer service.
 // It is intentionally explicit and flat to keep it easy to map to env vars
 // and to use from other packages.
@@ -8,7 +12,36 @@ type ProfilerConfig struct {
 	// Too low -> overhead; too high -> stale data.
 	SamplingIntervalMs int `json:"sampling_interval_ms" yaml:"sampling_interval_ms"`
 
+	// SamplingMode selects what triggers a sample: "interval" samples every
+	// SamplingIntervalMs; "gc" samples right after a GC cycle completes, at
+	// most once per SamplingIntervalMs, and measures retention against the
+	// post-GC live heap. Without a GC for two intervals, "gc" mode takes an
+	// interval sample so history does not go stale.
+	SamplingMode string `json:"sampling_mode" yaml:"sampling_mode"`
+
+	// AdaptiveSamplingEnabled lets the profiler change the sampling interval
+	// at runtime, starting from SamplingIntervalMs: it halves the interval
+	// when the heap or allocation rate moves by SamplingVolatilityPercent
+	// between samples, grows it by a quarter while both stay within a quarter
+	// of that, and doubles it when sampling takes more than
+	// SamplingOverheadBudgetPercent of the interval.
+	AdaptiveSamplingEnabled bool `json:"adaptive_sampling_enabled" yaml:"adaptive_sampling_enabled"`
+
+	// SamplingMinIntervalMs and SamplingMaxIntervalMs bound the adaptive
+	// sampling interval.
+	SamplingMinIntervalMs int `json:"sampling_min_interval_ms" yaml:"sampling_min_interval_ms"`
+	SamplingMaxIntervalMs int `json:"sampling_max_interval_ms" yaml:"sampling_max_interval_ms"`
+
+	// SamplingVolatilityPercent is the change of heap or allocation rate
+	// between samples that shortens the adaptive interval.
+	SamplingVolatilityPercent float64 `json:"sampling_volatility_percent" yaml:"sampling_volatility_percent"`
+
+	// SamplingOverheadBudgetPercent is the share of the sampling interval
+	// that one sample may take before the adaptive interval grows.
+	SamplingOverheadBudgetPercent float64 `json:"sampling_overhead_budget_percent" yaml:"sampling_overhead_budget_percent"`
+
 	// RetentionWindowSec controls how long (in seconds) we keep historical snapshots.
+	// Older snapshots are pruned even when MaxHistorySamples is not reached.
 	// This affects memory usage of the profiler service itself.
 	RetentionWindowSec int `json:"retention_window_sec" yaml:"retention_window_sec"`
 
@@ -60,6 +93,157 @@ type ProfilerConfig struct {
 	// ProfileCaptureMinIntervalSec enforces a cooldown between automatic captures.
 	ProfileCaptureMinIntervalSec int `json:"profile_capture_min_interval_sec" yaml:"profile_capture_min_interval_sec"`
 
+	// AllocSampleRate enables probabilistic sampling in TrackAllocation.
+	// 0 or 1 records every call. Recorded events are scaled up so that
+	// allocation counts and bytes remain unbiased estimates.
+	AllocSampleRate int `json:"alloc_sample_rate" yaml:"alloc_sample_rate"`
+
+	// AllocSampleMode selects how AllocSampleRate is interpreted: "calls"
+	// records on average 1 in N calls; "bytes" records on average once every
+	// N bytes, similar to runtime.MemProfileRate.
+	AllocSampleMode string `json:"alloc_sample_mode" yaml:"alloc_sample_mode"`
+
+	// DeepSizeEnabled makes TrackAllocation follow pointers, slices, maps,
+	// strings and interfaces when estimating an object's size instead of
+	// counting only its top-level value.
+	DeepSizeEnabled bool `json:"deep_size_enabled" yaml:"deep_size_enabled"`
+
+	// DeepSizeMaxNodes bounds how many values a single deep size walk may
+	// visit. 0 means up to MaxDeepSizeNodes, which also caps larger values.
+	DeepSizeMaxNodes int `json:"deep_size_max_nodes" yaml:"deep_size_max_nodes"`
+
+	// DeepSizeMaxBytes stops a deep size walk once this many bytes have been
+	// counted. 0 means unlimited.
+	DeepSizeMaxBytes int `json:"deep_size_max_bytes" yaml:"deep_size_max_bytes"`
+
+	// MaxAllocSeries caps the number of distinct (type, tag) series tracked.
+	// Once reached, new series are folded into a per-type "other" series and
+	// the least recently active series without live objects are evicted.
+	// 0 means unlimited.
+	MaxAllocSeries int `json:"max_alloc_series" yaml:"max_alloc_series"`
+
+	// AllocAccountingMode selects how allocations are aggregated: "exact"
+	// keeps one series per (type, tag); "sketch" keeps only approximate
+	// heavy hitters in bounded memory, for tags with millions of values.
+	AllocAccountingMode string `json:"alloc_accounting_mode" yaml:"alloc_accounting_mode"`
+
+	// SketchCapacity is the number of heavy-hitter counters kept in sketch
+	// mode. Entries whose true share of bytes exceeds 1/SketchCapacity are
+	// guaranteed to be reported.
+	SketchCapacity int `json:"sketch_capacity" yaml:"sketch_capacity"`
+
+	// HistoryStore selects where snapshot history is kept: "memory" (lost on
+	// restart) or "file", an append-only store in HistoryStoreDir that is
+	// replayed on startup.
+	HistoryStore string `json:"history_store" yaml:"history_store"`
+
+	// HistoryStoreDir is the directory for the file history store.
+	HistoryStoreDir string `json:"history_store_dir" yaml:"history_store_dir"`
+
+	// HistoryStoreRetentionSec drops persisted segments older than this.
+	HistoryStoreRetentionSec int `json:"history_store_retention_sec" yaml:"history_store_retention_sec"`
+
+	// HistoryStoreSegmentBytes is the size at which a segment file is sealed.
+	// Sealed segments older than RetentionWindowSec are compacted to one
+	// snapshot per minute.
+	HistoryStoreSegmentBytes int `json:"history_store_segment_bytes" yaml:"history_store_segment_bytes"`
+
+	// HistoryStoreSyncEvery fsyncs the file store once every this many
+	// snapshots. 1 syncs each one; larger values risk losing up to
+	// HistoryStoreSyncEvery-1 snapshots on power loss.
+	HistoryStoreSyncEvery int `json:"history_store_sync_every" yaml:"history_store_sync_every"`
+
+	// LeakWindowSec is how far back leak detection fits growth trends of the
+	// post-GC heap and per-tag retention. 0 disables leak detection.
+	LeakWindowSec int `json:"leak_window_sec" yaml:"leak_window_sec"`
+
+	// LeakMinGrowthBytesPerHour is the estimated growth above which a trend
+	// is reported as a leak.
+	LeakMinGrowthBytesPerHour float64 `json:"leak_min_growth_bytes_per_hour" yaml:"leak_min_growth_bytes_per_hour"`
+
+	// LeakMinConfidence is the trend confidence (0-1) required before a
+	// growing series is reported as a leak.
+	LeakMinConfidence float64 `json:"leak_min_confidence" yaml:"leak_min_confidence"`
+
+	// LeakMinSamples is the minimum number of points a trend is fitted on.
+	LeakMinSamples int `json:"leak_min_samples" yaml:"leak_min_samples"`
+
+	// CgroupRoot is where the cgroup filesystem is mounted. Memory usage,
+	// limit, stats and OOM events of the process's cgroup (v1 or v2) are read
+	// below it. Empty disables cgroup accounting.
+	CgroupRoot string `json:"cgroup_root" yaml:"cgroup_root"`
+
+	// ProcRoot is where procfs is mounted; RSS is read from self/status and
+	// self/smaps_rollup below it. Empty disables procfs accounting.
+	ProcRoot string `json:"proc_root" yaml:"proc_root"`
+
+	// NonGoMemoryThresholdPercent flags RSS not managed by the Go runtime
+	// (cgo, mmap) above this share of RSS. 0 disables the check.
+	NonGoMemoryThresholdPercent float64 `json:"non_go_memory_threshold_percent" yaml:"non_go_memory_threshold_percent"`
+
+	// CgroupUsageThresholdPercent alerts when the cgroup working set exceeds
+	// this share of the cgroup memory limit. 0 disables the alert.
+	CgroupUsageThresholdPercent float64 `json:"cgroup_usage_threshold_percent" yaml:"cgroup_usage_threshold_percent"`
+
+	// OOMAlertMinutes raises an alert when memory is forecast to reach the
+	// effective limit within this many minutes. 0 disables the alert.
+	OOMAlertMinutes int `json:"oom_alert_minutes" yaml:"oom_alert_minutes"`
+
+	// GCTuningWindowSec is how much history the GOGC/GOMEMLIMIT advisor
+	// looks at. 0 disables the advisor.
+	GCTuningWindowSec int `json:"gc_tuning_window_sec" yaml:"gc_tuning_window_sec"`
+
+	// GCTargetCPUPercent is the share of CPU time the advisor aims to spend
+	// in the GC. GOGC is raised when the GC uses more than twice this and
+	// lowered when it uses less than a quarter of it.
+	GCTargetCPUPercent float64 `json:"gc_target_cpu_percent" yaml:"gc_target_cpu_percent"`
+
+	// GCMemoryHeadroomPercent is the share of the cgroup memory limit, after
+	// non-Go memory, kept free when sizing GOMEMLIMIT.
+	GCMemoryHeadroomPercent float64 `json:"gc_memory_headroom_percent" yaml:"gc_memory_headroom_percent"`
+
+	// GCControllerEnabled lets the profiler apply GOMEMLIMIT and GOGC itself
+	// via debug.SetMemoryLimit and debug.SetGCPercent, targeting
+	// GCMemoryHeadroomPercent under the cgroup limit and the GC tuning
+	// advice. Off by default. Enabling it also registers the
+	// unauthenticated POST /v1/gc-controller/kill, so MetricsListenAddr must
+	// then only be reachable by operators.
+	GCControllerEnabled bool `json:"gc_controller_enabled" yaml:"gc_controller_enabled"`
+
+	// GCControllerMinIntervalSec is the minimum time between two adjustments.
+	GCControllerMinIntervalSec int `json:"gc_controller_min_interval_sec" yaml:"gc_controller_min_interval_sec"`
+
+	// GCControllerMaxStepPercent caps how much one adjustment may change
+	// GOGC or the memory limit, relative to the current value.
+	GCControllerMaxStepPercent float64 `json:"gc_controller_max_step_percent" yaml:"gc_controller_max_step_percent"`
+
+	// GCControllerMinGOGC and GCControllerMaxGOGC bound the GOGC values the
+	// controller sets.
+	GCControllerMinGOGC int `json:"gc_controller_min_gogc" yaml:"gc_controller_min_gogc"`
+	GCControllerMaxGOGC int `json:"gc_controller_max_gogc" yaml:"gc_controller_max_gogc"`
+
+	// GCControllerMinMemoryLimitBytes is the lowest memory limit the
+	// controller sets.
+	GCControllerMinMemoryLimitBytes int `json:"gc_controller_min_memory_limit_bytes" yaml:"gc_controller_min_memory_limit_bytes"`
+
+	// HeapIdleThresholdPercent flags free heap memory not yet returned to the
+	// OS above this share of the heap in use. 0 disables the check.
+	HeapIdleThresholdPercent float64 `json:"heap_idle_threshold_percent" yaml:"heap_idle_threshold_percent"`
+
+	// SmallObjectChurnPerSec flags allocation of objects of at most 128 bytes
+	// above this rate. 0 disables the check.
+	SmallObjectChurnPerSec float64 `json:"small_object_churn_per_sec" yaml:"small_object_churn_per_sec"`
+
+	// SelfOverheadBudgetPercent raises an alert when the profiler spends more
+	// than this share of one CPU sampling and in TrackAllocation. 0 disables
+	// the alert.
+	SelfOverheadBudgetPercent float64 `json:"self_overhead_budget_percent" yaml:"self_overhead_budget_percent"`
+
+	// TraceRecordPath, when set, records every raw sample and its allocation
+	// deltas to this file for replay with `profiler -replay`. The file is
+	// truncated on startup.
+	TraceRecordPath string `json:"trace_record_path" yaml:"trace_record_path"`
+
 	// ProfileCaptureOnSeverities lists alert severities that should trigger capture
 	// (e.g., ["critical"], or ["warning","critical"]). Case-insensitive.
 	ProfileCaptureOnSeverities []string `json:"profile_capture_on_severities" yaml:"profile_capture_on_severities"`
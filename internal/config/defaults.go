@@ -6,9 +6,17 @@ package config
 func DefaultConfig() ProfilerConfig {
 	return ProfilerConfig{
 		SamplingIntervalMs:            1000, // 1s
+		SamplingMode:                  "interval",
 		RetentionWindowSec:            600,  // 10 minutes
 		HighRetentionThresholdPercent: 70.0, // 70% of heap
 
+		// Adaptive sampling, off unless opted in.
+		AdaptiveSamplingEnabled:       false,
+		SamplingMinIntervalMs:         250,
+		SamplingMaxIntervalMs:         10000,
+		SamplingVolatilityPercent:     10.0,
+		SamplingOverheadBudgetPercent: 1.0,
+
 		MetricsListenAddr: ":8080",
 
 		PrometheusEnabled: true,
@@ -22,6 +30,64 @@ func DefaultConfig() ProfilerConfig {
 		LogLevel:               "info",
 		ShutdownGracePeriodSec: 15,
 
+		// TrackAllocation sampling defaults (record every call)
+		AllocSampleRate: 0,
+		AllocSampleMode: "calls",
+
+		// Deep size estimation is opt-in; budgets keep a single walk bounded.
+		DeepSizeEnabled:  false,
+		DeepSizeMaxNodes: 10000,
+		DeepSizeMaxBytes: 0,
+
+		MaxAllocSeries: 10000,
+
+		AllocAccountingMode: "exact",
+		SketchCapacity:      1024,
+
+		// History persistence is opt-in.
+		HistoryStore:             "memory",
+		HistoryStoreDir:          "./history",
+		HistoryStoreRetentionSec: 30 * 24 * 3600, // 30 days, like the 1h rollup tier
+		HistoryStoreSegmentBytes: 8 << 20,
+		HistoryStoreSyncEvery:    1,
+
+		// Leak detection over the last hour of history.
+		LeakWindowSec:             3600,
+		LeakMinGrowthBytesPerHour: 10 << 20, // 10 MiB/h
+		LeakMinConfidence:         0.95,
+		LeakMinSamples:            10,
+
+		// Container accounting and OOM forecasting against GOMEMLIMIT or the
+		// cgroup memory limit.
+		CgroupRoot:                  "/sys/fs/cgroup",
+		ProcRoot:                    "/proc",
+		NonGoMemoryThresholdPercent: 50.0,
+		CgroupUsageThresholdPercent: 90.0,
+		OOMAlertMinutes:             60,
+
+		// GOGC/GOMEMLIMIT advisor over the last 15 minutes.
+		GCTuningWindowSec:       900,
+		GCTargetCPUPercent:      2.5,
+		GCMemoryHeadroomPercent: 10.0,
+
+		// GC controller, off unless opted in.
+		GCControllerEnabled:             false,
+		GCControllerMinIntervalSec:      60,
+		GCControllerMaxStepPercent:      25.0,
+		GCControllerMinGOGC:             50,
+		GCControllerMaxGOGC:             400,
+		GCControllerMinMemoryLimitBytes: 64 << 20,
+
+		// Fragmentation and allocation churn.
+		HeapIdleThresholdPercent: 50.0,
+		SmallObjectChurnPerSec:   1e6,
+
+		// Profiler self-overhead budget, as a share of one CPU.
+		SelfOverheadBudgetPercent: 1.0,
+
+		// Trace recording, off unless a path is set.
+		TraceRecordPath: "",
+
 		// Auto profile capture defaults
 		ProfileCaptureEnabled:        false,
 		ProfileCaptureDir:            "./profiles",
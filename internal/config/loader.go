@@ -16,6 +16,12 @@ import (
 // Keeping them here makes it easy to see the surface we expose.
 const (
 	envSamplingIntervalMs        = "GOPROF_SAMPLING_INTERVAL_MS"
+	envSamplingMode              = "GOPROF_SAMPLING_MODE"
+	envAdaptiveSamplingEnabled   = "GOPROF_ADAPTIVE_SAMPLING_ENABLED"
+	envSamplingMinIntervalMs     = "GOPROF_SAMPLING_MIN_INTERVAL_MS"
+	envSamplingMaxIntervalMs     = "GOPROF_SAMPLING_MAX_INTERVAL_MS"
+	envSamplingVolatilityPct     = "GOPROF_SAMPLING_VOLATILITY_PERCENT"
+	envSamplingOverheadBudgetPct = "GOPROF_SAMPLING_OVERHEAD_BUDGET_PERCENT"
 	envRetentionWindowSec        = "GOPROF_RETENTION_WINDOW_SEC"
 	envHighRetentionThresholdPct = "GOPROF_HIGH_RETENTION_THRESHOLD_PERCENT"
 	envMetricsListenAddr         = "GOPROF_METRICS_LISTEN_ADDR"
@@ -27,6 +33,41 @@ const (
 	envMemorySpikeThresholdPct   = "GOPROF_MEMORY_SPIKE_THRESHOLD_PERCENT"
 	envLogLevel                  = "GOPROF_LOG_LEVEL"
 	envShutdownGracePeriodSec    = "GOPROF_SHUTDOWN_GRACE_PERIOD_SEC"
+	envAllocSampleRate           = "GOPROF_ALLOC_SAMPLE_RATE"
+	envAllocSampleMode           = "GOPROF_ALLOC_SAMPLE_MODE"
+	envDeepSizeEnabled           = "GOPROF_DEEP_SIZE_ENABLED"
+	envDeepSizeMaxNodes          = "GOPROF_DEEP_SIZE_MAX_NODES"
+	envDeepSizeMaxBytes          = "GOPROF_DEEP_SIZE_MAX_BYTES"
+	envMaxAllocSeries            = "GOPROF_MAX_ALLOC_SERIES"
+	envAllocAccountingMode       = "GOPROF_ALLOC_ACCOUNTING_MODE"
+	envSketchCapacity            = "GOPROF_SKETCH_CAPACITY"
+	envHistoryStore              = "GOPROF_HISTORY_STORE"
+	envHistoryStoreDir           = "GOPROF_HISTORY_STORE_DIR"
+	envHistoryStoreRetentionSec  = "GOPROF_HISTORY_STORE_RETENTION_SEC"
+	envHistoryStoreSegmentBytes  = "GOPROF_HISTORY_STORE_SEGMENT_BYTES"
+	envHistoryStoreSyncEvery     = "GOPROF_HISTORY_STORE_SYNC_EVERY"
+	envLeakWindowSec             = "GOPROF_LEAK_WINDOW_SEC"
+	envLeakMinGrowthBytesPerHour = "GOPROF_LEAK_MIN_GROWTH_BYTES_PER_HOUR"
+	envLeakMinConfidence         = "GOPROF_LEAK_MIN_CONFIDENCE"
+	envLeakMinSamples            = "GOPROF_LEAK_MIN_SAMPLES"
+	envCgroupRoot                = "GOPROF_CGROUP_ROOT"
+	envProcRoot                  = "GOPROF_PROC_ROOT"
+	envNonGoMemoryThresholdPct   = "GOPROF_NON_GO_MEMORY_THRESHOLD_PERCENT"
+	envCgroupUsageThresholdPct   = "GOPROF_CGROUP_USAGE_THRESHOLD_PERCENT"
+	envOOMAlertMinutes           = "GOPROF_OOM_ALERT_MINUTES"
+	envGCTuningWindowSec         = "GOPROF_GC_TUNING_WINDOW_SEC"
+	envGCTargetCPUPercent        = "GOPROF_GC_TARGET_CPU_PERCENT"
+	envGCMemoryHeadroomPercent   = "GOPROF_GC_MEMORY_HEADROOM_PERCENT"
+	envGCControllerEnabled       = "GOPROF_GC_CONTROLLER_ENABLED"
+	envGCControllerMinInterval   = "GOPROF_GC_CONTROLLER_MIN_INTERVAL_SEC"
+	envGCControllerMaxStepPct    = "GOPROF_GC_CONTROLLER_MAX_STEP_PERCENT"
+	envGCControllerMinGOGC       = "GOPROF_GC_CONTROLLER_MIN_GOGC"
+	envGCControllerMaxGOGC       = "GOPROF_GC_CONTROLLER_MAX_GOGC"
+	envGCControllerMinMemLimit   = "GOPROF_GC_CONTROLLER_MIN_MEMORY_LIMIT_BYTES"
+	envHeapIdleThresholdPct      = "GOPROF_HEAP_IDLE_THRESHOLD_PERCENT"
+	envSmallObjectChurnPerSec    = "GOPROF_SMALL_OBJECT_CHURN_PER_SEC"
+	envSelfOverheadBudgetPct     = "GOPROF_SELF_OVERHEAD_BUDGET_PERCENT"
+	envTraceRecordPath           = "GOPROF_TRACE_RECORD_PATH"
 
 	// Auto profile capture env vars
 	envProfileCaptureEnabled        = "GOPROF_PROFILE_CAPTURE_ENABLED"
@@ -112,6 +153,46 @@ func overlayFromEnv(cfg *ProfilerConfig) error {
 		}
 	}
 
+	if v, ok := os.LookupEnv(envSamplingMode); ok {
+		cfg.SamplingMode = strings.ToLower(strings.TrimSpace(v))
+	}
+
+	if v, ok := os.LookupEnv(envAdaptiveSamplingEnabled); ok {
+		if b, err := parseBool(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envAdaptiveSamplingEnabled, err))
+		} else {
+			cfg.AdaptiveSamplingEnabled = b
+		}
+	}
+	if v, ok := os.LookupEnv(envSamplingMinIntervalMs); ok {
+		if i, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envSamplingMinIntervalMs, err))
+		} else {
+			cfg.SamplingMinIntervalMs = i
+		}
+	}
+	if v, ok := os.LookupEnv(envSamplingMaxIntervalMs); ok {
+		if i, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envSamplingMaxIntervalMs, err))
+		} else {
+			cfg.SamplingMaxIntervalMs = i
+		}
+	}
+	if v, ok := os.LookupEnv(envSamplingVolatilityPct); ok {
+		if f, err := strconv.ParseFloat(v, 64); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envSamplingVolatilityPct, err))
+		} else {
+			cfg.SamplingVolatilityPercent = f
+		}
+	}
+	if v, ok := os.LookupEnv(envSamplingOverheadBudgetPct); ok {
+		if f, err := strconv.ParseFloat(v, 64); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envSamplingOverheadBudgetPct, err))
+		} else {
+			cfg.SamplingOverheadBudgetPercent = f
+		}
+	}
+
 	if v, ok := os.LookupEnv(envRetentionWindowSec); ok {
 		if i, err := strconv.Atoi(v); err != nil {
 			errs = append(errs, fmt.Errorf("%s: %w", envRetentionWindowSec, err))
@@ -188,6 +269,234 @@ func overlayFromEnv(cfg *ProfilerConfig) error {
 		}
 	}
 
+	if v, ok := os.LookupEnv(envAllocSampleRate); ok {
+		if i, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envAllocSampleRate, err))
+		} else {
+			cfg.AllocSampleRate = i
+		}
+	}
+
+	if v, ok := os.LookupEnv(envAllocSampleMode); ok {
+		cfg.AllocSampleMode = strings.ToLower(strings.TrimSpace(v))
+	}
+
+	if v, ok := os.LookupEnv(envDeepSizeEnabled); ok {
+		if b, err := parseBool(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envDeepSizeEnabled, err))
+		} else {
+			cfg.DeepSizeEnabled = b
+		}
+	}
+
+	if v, ok := os.LookupEnv(envDeepSizeMaxNodes); ok {
+		if i, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envDeepSizeMaxNodes, err))
+		} else {
+			cfg.DeepSizeMaxNodes = i
+		}
+	}
+
+	if v, ok := os.LookupEnv(envDeepSizeMaxBytes); ok {
+		if i, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envDeepSizeMaxBytes, err))
+		} else {
+			cfg.DeepSizeMaxBytes = i
+		}
+	}
+
+	if v, ok := os.LookupEnv(envMaxAllocSeries); ok {
+		if i, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envMaxAllocSeries, err))
+		} else {
+			cfg.MaxAllocSeries = i
+		}
+	}
+
+	if v, ok := os.LookupEnv(envAllocAccountingMode); ok {
+		cfg.AllocAccountingMode = strings.ToLower(strings.TrimSpace(v))
+	}
+
+	if v, ok := os.LookupEnv(envSketchCapacity); ok {
+		if i, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envSketchCapacity, err))
+		} else {
+			cfg.SketchCapacity = i
+		}
+	}
+
+	if v, ok := os.LookupEnv(envHistoryStore); ok {
+		cfg.HistoryStore = strings.ToLower(strings.TrimSpace(v))
+	}
+	if v, ok := os.LookupEnv(envHistoryStoreDir); ok {
+		cfg.HistoryStoreDir = strings.TrimSpace(v)
+	}
+	if v, ok := os.LookupEnv(envHistoryStoreRetentionSec); ok {
+		if i, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envHistoryStoreRetentionSec, err))
+		} else {
+			cfg.HistoryStoreRetentionSec = i
+		}
+	}
+	if v, ok := os.LookupEnv(envHistoryStoreSegmentBytes); ok {
+		if i, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envHistoryStoreSegmentBytes, err))
+		} else {
+			cfg.HistoryStoreSegmentBytes = i
+		}
+	}
+	if v, ok := os.LookupEnv(envHistoryStoreSyncEvery); ok {
+		if i, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envHistoryStoreSyncEvery, err))
+		} else {
+			cfg.HistoryStoreSyncEvery = i
+		}
+	}
+
+	if v, ok := os.LookupEnv(envLeakWindowSec); ok {
+		if i, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envLeakWindowSec, err))
+		} else {
+			cfg.LeakWindowSec = i
+		}
+	}
+	if v, ok := os.LookupEnv(envLeakMinGrowthBytesPerHour); ok {
+		if f, err := strconv.ParseFloat(v, 64); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envLeakMinGrowthBytesPerHour, err))
+		} else {
+			cfg.LeakMinGrowthBytesPerHour = f
+		}
+	}
+	if v, ok := os.LookupEnv(envLeakMinConfidence); ok {
+		if f, err := strconv.ParseFloat(v, 64); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envLeakMinConfidence, err))
+		} else {
+			cfg.LeakMinConfidence = f
+		}
+	}
+	if v, ok := os.LookupEnv(envLeakMinSamples); ok {
+		if i, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envLeakMinSamples, err))
+		} else {
+			cfg.LeakMinSamples = i
+		}
+	}
+
+	if v, ok := os.LookupEnv(envCgroupRoot); ok {
+		cfg.CgroupRoot = strings.TrimSpace(v)
+	}
+	if v, ok := os.LookupEnv(envProcRoot); ok {
+		cfg.ProcRoot = strings.TrimSpace(v)
+	}
+	if v, ok := os.LookupEnv(envNonGoMemoryThresholdPct); ok {
+		if f, err := strconv.ParseFloat(v, 64); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envNonGoMemoryThresholdPct, err))
+		} else {
+			cfg.NonGoMemoryThresholdPercent = f
+		}
+	}
+	if v, ok := os.LookupEnv(envCgroupUsageThresholdPct); ok {
+		if f, err := strconv.ParseFloat(v, 64); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envCgroupUsageThresholdPct, err))
+		} else {
+			cfg.CgroupUsageThresholdPercent = f
+		}
+	}
+	if v, ok := os.LookupEnv(envOOMAlertMinutes); ok {
+		if i, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envOOMAlertMinutes, err))
+		} else {
+			cfg.OOMAlertMinutes = i
+		}
+	}
+	if v, ok := os.LookupEnv(envGCTuningWindowSec); ok {
+		if i, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envGCTuningWindowSec, err))
+		} else {
+			cfg.GCTuningWindowSec = i
+		}
+	}
+	if v, ok := os.LookupEnv(envGCTargetCPUPercent); ok {
+		if f, err := strconv.ParseFloat(v, 64); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envGCTargetCPUPercent, err))
+		} else {
+			cfg.GCTargetCPUPercent = f
+		}
+	}
+	if v, ok := os.LookupEnv(envGCMemoryHeadroomPercent); ok {
+		if f, err := strconv.ParseFloat(v, 64); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envGCMemoryHeadroomPercent, err))
+		} else {
+			cfg.GCMemoryHeadroomPercent = f
+		}
+	}
+	if v, ok := os.LookupEnv(envGCControllerEnabled); ok {
+		if b, err := parseBool(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envGCControllerEnabled, err))
+		} else {
+			cfg.GCControllerEnabled = b
+		}
+	}
+	if v, ok := os.LookupEnv(envGCControllerMinInterval); ok {
+		if i, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envGCControllerMinInterval, err))
+		} else {
+			cfg.GCControllerMinIntervalSec = i
+		}
+	}
+	if v, ok := os.LookupEnv(envGCControllerMaxStepPct); ok {
+		if f, err := strconv.ParseFloat(v, 64); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envGCControllerMaxStepPct, err))
+		} else {
+			cfg.GCControllerMaxStepPercent = f
+		}
+	}
+	if v, ok := os.LookupEnv(envGCControllerMinGOGC); ok {
+		if i, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envGCControllerMinGOGC, err))
+		} else {
+			cfg.GCControllerMinGOGC = i
+		}
+	}
+	if v, ok := os.LookupEnv(envGCControllerMaxGOGC); ok {
+		if i, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envGCControllerMaxGOGC, err))
+		} else {
+			cfg.GCControllerMaxGOGC = i
+		}
+	}
+	if v, ok := os.LookupEnv(envGCControllerMinMemLimit); ok {
+		if i, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envGCControllerMinMemLimit, err))
+		} else {
+			cfg.GCControllerMinMemoryLimitBytes = i
+		}
+	}
+	if v, ok := os.LookupEnv(envHeapIdleThresholdPct); ok {
+		if f, err := strconv.ParseFloat(v, 64); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envHeapIdleThresholdPct, err))
+		} else {
+			cfg.HeapIdleThresholdPercent = f
+		}
+	}
+	if v, ok := os.LookupEnv(envSmallObjectChurnPerSec); ok {
+		if f, err := strconv.ParseFloat(v, 64); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envSmallObjectChurnPerSec, err))
+		} else {
+			cfg.SmallObjectChurnPerSec = f
+		}
+	}
+	if v, ok := os.LookupEnv(envSelfOverheadBudgetPct); ok {
+		if f, err := strconv.ParseFloat(v, 64); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envSelfOverheadBudgetPct, err))
+		} else {
+			cfg.SelfOverheadBudgetPercent = f
+		}
+	}
+	if v, ok := os.LookupEnv(envTraceRecordPath); ok {
+		cfg.TraceRecordPath = strings.TrimSpace(v)
+	}
+
 	// Auto profile capture overlays
 	if v, ok := os.LookupEnv(envProfileCaptureEnabled); ok {
 		if b, err := parseBool(v); err != nil {
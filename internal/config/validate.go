@@ -3,6 +3,7 @@ package config
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 // Validate validates the given configuration and returns an error if any field
@@ -14,6 +15,27 @@ func Validate(cfg *ProfilerConfig) error {
 		errs = append(errs, fmt.Errorf("sampling_interval_ms must be > 0 (got %d)", cfg.SamplingIntervalMs))
 	}
 
+	switch cfg.SamplingMode {
+	case "interval", "gc":
+		// ok
+	default:
+		errs = append(errs, fmt.Errorf("sampling_mode must be one of [interval, gc] (got %q)", cfg.SamplingMode))
+	}
+
+	if cfg.AdaptiveSamplingEnabled {
+		if cfg.SamplingMinIntervalMs <= 0 || cfg.SamplingMaxIntervalMs < cfg.SamplingMinIntervalMs {
+			errs = append(errs, fmt.Errorf("sampling_min_interval_ms must be > 0 and <= sampling_max_interval_ms (got %d, %d)", cfg.SamplingMinIntervalMs, cfg.SamplingMaxIntervalMs))
+		} else if cfg.SamplingIntervalMs < cfg.SamplingMinIntervalMs || cfg.SamplingIntervalMs > cfg.SamplingMaxIntervalMs {
+			errs = append(errs, fmt.Errorf("sampling_interval_ms (%d) must be within [sampling_min_interval_ms, sampling_max_interval_ms] (got %d, %d)", cfg.SamplingIntervalMs, cfg.SamplingMinIntervalMs, cfg.SamplingMaxIntervalMs))
+		}
+		if cfg.SamplingVolatilityPercent <= 0 {
+			errs = append(errs, fmt.Errorf("sampling_volatility_percent must be > 0 (got %v)", cfg.SamplingVolatilityPercent))
+		}
+		if cfg.SamplingOverheadBudgetPercent <= 0 || cfg.SamplingOverheadBudgetPercent > 100 {
+			errs = append(errs, fmt.Errorf("sampling_overhead_budget_percent must be within (0, 100] (got %v)", cfg.SamplingOverheadBudgetPercent))
+		}
+	}
+
 	if cfg.RetentionWindowSec <= 0 {
 		errs = append(errs, fmt.Errorf("retention_window_sec must be > 0 (got %d)", cfg.RetentionWindowSec))
 	}
@@ -58,6 +80,117 @@ func Validate(cfg *ProfilerConfig) error {
 		errs = append(errs, fmt.Errorf("shutdown_grace_period_sec must be >= 0 (got %d)", cfg.ShutdownGracePeriodSec))
 	}
 
+	if cfg.AllocSampleRate < 0 {
+		errs = append(errs, fmt.Errorf("alloc_sample_rate must be >= 0 (got %d)", cfg.AllocSampleRate))
+	}
+
+	switch cfg.AllocSampleMode {
+	case "calls", "bytes":
+		// ok
+	default:
+		errs = append(errs, fmt.Errorf("alloc_sample_mode must be one of [calls, bytes] (got %q)", cfg.AllocSampleMode))
+	}
+
+	if cfg.DeepSizeMaxNodes < 0 || cfg.DeepSizeMaxNodes > MaxDeepSizeNodes {
+		errs = append(errs, fmt.Errorf("deep_size_max_nodes must be between 0 and %d (got %d)", MaxDeepSizeNodes, cfg.DeepSizeMaxNodes))
+	}
+
+	if cfg.DeepSizeMaxBytes < 0 {
+		errs = append(errs, fmt.Errorf("deep_size_max_bytes must be >= 0 (got %d)", cfg.DeepSizeMaxBytes))
+	}
+
+	if cfg.MaxAllocSeries < 0 {
+		errs = append(errs, fmt.Errorf("max_alloc_series must be >= 0 (got %d)", cfg.MaxAllocSeries))
+	}
+
+	switch cfg.AllocAccountingMode {
+	case "exact":
+		// ok
+	case "sketch":
+		if cfg.SketchCapacity <= 0 {
+			errs = append(errs, fmt.Errorf("sketch_capacity must be > 0 when alloc_accounting_mode is sketch (got %d)", cfg.SketchCapacity))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("alloc_accounting_mode must be one of [exact, sketch] (got %q)", cfg.AllocAccountingMode))
+	}
+
+	switch cfg.HistoryStore {
+	case "memory":
+		// ok
+	case "file":
+		if strings.TrimSpace(cfg.HistoryStoreDir) == "" {
+			errs = append(errs, errors.New("history_store_dir must not be empty when history_store is file"))
+		}
+		if cfg.HistoryStoreRetentionSec <= 0 {
+			errs = append(errs, fmt.Errorf("history_store_retention_sec must be > 0 (got %d)", cfg.HistoryStoreRetentionSec))
+		}
+		if cfg.HistoryStoreSegmentBytes <= 0 {
+			errs = append(errs, fmt.Errorf("history_store_segment_bytes must be > 0 (got %d)", cfg.HistoryStoreSegmentBytes))
+		}
+		if cfg.HistoryStoreSyncEvery <= 0 {
+			errs = append(errs, fmt.Errorf("history_store_sync_every must be > 0 (got %d)", cfg.HistoryStoreSyncEvery))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("history_store must be one of [memory, file] (got %q)", cfg.HistoryStore))
+	}
+
+	if cfg.LeakWindowSec < 0 {
+		errs = append(errs, fmt.Errorf("leak_window_sec must be >= 0 (got %d)", cfg.LeakWindowSec))
+	}
+	if cfg.LeakWindowSec > 0 {
+		if cfg.LeakMinGrowthBytesPerHour <= 0 {
+			errs = append(errs, fmt.Errorf("leak_min_growth_bytes_per_hour must be > 0 (got %v)", cfg.LeakMinGrowthBytesPerHour))
+		}
+		if cfg.LeakMinConfidence <= 0 || cfg.LeakMinConfidence >= 1 {
+			errs = append(errs, fmt.Errorf("leak_min_confidence must be in (0, 1) (got %v)", cfg.LeakMinConfidence))
+		}
+		if cfg.LeakMinSamples < 3 {
+			errs = append(errs, fmt.Errorf("leak_min_samples must be >= 3 (got %d)", cfg.LeakMinSamples))
+		}
+	}
+
+	if cfg.NonGoMemoryThresholdPercent < 0 || cfg.NonGoMemoryThresholdPercent > 100 {
+		errs = append(errs, fmt.Errorf("non_go_memory_threshold_percent must be within [0, 100] (got %v)", cfg.NonGoMemoryThresholdPercent))
+	}
+	if cfg.CgroupUsageThresholdPercent < 0 || cfg.CgroupUsageThresholdPercent > 100 {
+		errs = append(errs, fmt.Errorf("cgroup_usage_threshold_percent must be within [0, 100] (got %v)", cfg.CgroupUsageThresholdPercent))
+	}
+	if cfg.OOMAlertMinutes < 0 {
+		errs = append(errs, fmt.Errorf("oom_alert_minutes must be >= 0 (got %d)", cfg.OOMAlertMinutes))
+	}
+	if cfg.GCTuningWindowSec < 0 {
+		errs = append(errs, fmt.Errorf("gc_tuning_window_sec must be >= 0 (got %d)", cfg.GCTuningWindowSec))
+	}
+	if cfg.GCTargetCPUPercent <= 0 || cfg.GCTargetCPUPercent > 100 {
+		errs = append(errs, fmt.Errorf("gc_target_cpu_percent must be within (0, 100] (got %v)", cfg.GCTargetCPUPercent))
+	}
+	if cfg.GCMemoryHeadroomPercent < 0 || cfg.GCMemoryHeadroomPercent >= 100 {
+		errs = append(errs, fmt.Errorf("gc_memory_headroom_percent must be within [0, 100) (got %v)", cfg.GCMemoryHeadroomPercent))
+	}
+	if cfg.HeapIdleThresholdPercent < 0 {
+		errs = append(errs, fmt.Errorf("heap_idle_threshold_percent must be >= 0 (got %v)", cfg.HeapIdleThresholdPercent))
+	}
+	if cfg.SmallObjectChurnPerSec < 0 {
+		errs = append(errs, fmt.Errorf("small_object_churn_per_sec must be >= 0 (got %v)", cfg.SmallObjectChurnPerSec))
+	}
+	if cfg.SelfOverheadBudgetPercent < 0 {
+		errs = append(errs, fmt.Errorf("self_overhead_budget_percent must be >= 0 (got %v)", cfg.SelfOverheadBudgetPercent))
+	}
+	if cfg.GCControllerEnabled {
+		if cfg.GCControllerMinIntervalSec < 1 {
+			errs = append(errs, fmt.Errorf("gc_controller_min_interval_sec must be >= 1 (got %d)", cfg.GCControllerMinIntervalSec))
+		}
+		if cfg.GCControllerMaxStepPercent <= 0 || cfg.GCControllerMaxStepPercent > 100 {
+			errs = append(errs, fmt.Errorf("gc_controller_max_step_percent must be within (0, 100] (got %v)", cfg.GCControllerMaxStepPercent))
+		}
+		if cfg.GCControllerMinGOGC < 1 || cfg.GCControllerMaxGOGC < cfg.GCControllerMinGOGC {
+			errs = append(errs, fmt.Errorf("gc_controller_min_gogc must be >= 1 and <= gc_controller_max_gogc (got %d, %d)", cfg.GCControllerMinGOGC, cfg.GCControllerMaxGOGC))
+		}
+		if cfg.GCControllerMinMemoryLimitBytes < 0 {
+			errs = append(errs, fmt.Errorf("gc_controller_min_memory_limit_bytes must be >= 0 (got %d)", cfg.GCControllerMinMemoryLimitBytes))
+		}
+	}
+
 	// Validate profile capture fields when enabled (non-breaking defaults used elsewhere)
 	if cfg.ProfileCaptureEnabled {
 		if cfg.ProfileCaptureMaxFiles < 0 {
@@ -44,7 +44,12 @@ func (c *Checker) Readiness() error {
 		return errors.New("profiler has not produced any samples yet")
 	}
 
-	allowedStaleness := 3 * time.Duration(c.cfg.SamplingIntervalMs) * time.Millisecond
+	// With adaptive sampling the interval may grow up to its maximum.
+	intervalMs := c.cfg.SamplingIntervalMs
+	if c.cfg.AdaptiveSamplingEnabled {
+		intervalMs = max(intervalMs, c.cfg.SamplingMaxIntervalMs)
+	}
+	allowedStaleness := 3 * time.Duration(intervalMs) * time.Millisecond
 	if time.Since(last) > allowedStaleness {
 		return errors.New("profiler samples are stale")
 	}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
 	"github.com/abhishekchauhan17/goprof-optimizer/internal/util"
 )
 
@@ -38,9 +39,51 @@ func (s *Server) handleMetricsHistory(w http.ResponseWriter, r *http.Request) {
 		limit = 0
 	}
 
-	snaps := s.prof.Snapshots(limit)
-	logger.Debug("served metrics history", "count", len(snaps))
-	util.WriteJSON(w, http.StatusOK, snaps)
+	from, err := parseTimeQuery(r, "from")
+	if err != nil {
+		logger.Warn("invalid from", "error", err)
+		util.WriteError(w, http.StatusBadRequest, "from must be RFC3339 or Unix seconds")
+		return
+	}
+	to, err := parseTimeQuery(r, "to")
+	if err != nil {
+		logger.Warn("invalid to", "error", err)
+		util.WriteError(w, http.StatusBadRequest, "to must be RFC3339 or Unix seconds")
+		return
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		logger.Warn("invalid range", "from", from, "to", to)
+		util.WriteError(w, http.StatusBadRequest, "to must not be before from")
+		return
+	}
+
+	var step time.Duration
+	if raw := r.URL.Query().Get("step"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			logger.Warn("invalid step", "step", raw)
+			util.WriteError(w, http.StatusBadRequest, "step must be a positive duration")
+			return
+		}
+		step = d
+	}
+
+	// Without step the response keeps its original shape, a plain array of
+	// raw snapshots. Rollups are opt-in and come wrapped in an envelope that
+	// names the tier, so clients never get a different shape unasked.
+	if step == 0 {
+		snaps := s.prof.SnapshotsRange(from, to, limit)
+		w.Header().Set("X-Goprof-History-Tier", profiler.TierRaw)
+		logger.Debug("served metrics history", "tier", profiler.TierRaw, "count", len(snaps))
+		util.WriteJSON(w, http.StatusOK, snaps)
+		return
+	}
+
+	res := s.prof.History(from, to, step, limit)
+	w.Header().Set("X-Goprof-History-Tier", res.Tier)
+	w.Header().Set("X-Goprof-History-Step", res.Step)
+	logger.Debug("served metrics history", "tier", res.Tier, "snapshots", len(res.Snapshots), "rollups", len(res.Rollups))
+	util.WriteJSON(w, http.StatusOK, res)
 
 	_ = ctx // future use (tracing, etc.)
 }
@@ -59,11 +102,124 @@ func (s *Server) handleTopAllocations(w http.ResponseWriter, r *http.Request) {
 		limit = 0
 	}
 
-	top := s.prof.TopAllocations(limit)
-	logger.Debug("served top allocations", "count", len(top))
+	window := profiler.DefaultRateWindow
+	if raw := r.URL.Query().Get("window"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 || d > profiler.MaxRateWindow {
+			logger.Warn("invalid window", "window", raw)
+			util.WriteError(w, http.StatusBadRequest, "window must be a duration in (0, "+profiler.MaxRateWindow.String()+"]")
+			return
+		}
+		window = d
+	}
+
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy == "" {
+		sortBy = profiler.SortByBytes
+	}
+	if !profiler.ValidAllocationSort(sortBy) {
+		logger.Warn("invalid sort", "sort", sortBy)
+		util.WriteError(w, http.StatusBadRequest, "sort must be one of [bytes, count, bytes_rate, allocs_rate]")
+		return
+	}
+
+	top := s.prof.TopAllocationsWindow(limit, window, sortBy)
+	mode := s.prof.AccountingMode()
+	logger.Debug("served top allocations", "count", len(top), "window", window.String(), "sort", sortBy, "mode", mode)
+	w.Header().Set("X-Goprof-Accounting-Mode", mode)
 	util.WriteJSON(w, http.StatusOK, top)
 }
 
+func (s *Server) handleMetricsQuery(w http.ResponseWriter, r *http.Request) {
+	logger := s.logger.With("path", "/v1/metrics/query", "method", r.Method)
+
+	if r.Method != http.MethodGet {
+		logger.Warn("invalid method")
+		util.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	q := profiler.Query{
+		Field: r.URL.Query().Get("field"),
+		Agg:   r.URL.Query().Get("agg"),
+	}
+	if q.Agg == "" {
+		q.Agg = profiler.AggAvg
+	}
+
+	var err error
+	if q.From, err = parseTimeQuery(r, "from"); err != nil {
+		logger.Warn("invalid from", "error", err)
+		util.WriteError(w, http.StatusBadRequest, "from must be RFC3339 or Unix seconds")
+		return
+	}
+	if q.To, err = parseTimeQuery(r, "to"); err != nil {
+		logger.Warn("invalid to", "error", err)
+		util.WriteError(w, http.StatusBadRequest, "to must be RFC3339 or Unix seconds")
+		return
+	}
+	if raw := r.URL.Query().Get("step"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			logger.Warn("invalid step", "step", raw)
+			util.WriteError(w, http.StatusBadRequest, "step must be a positive duration")
+			return
+		}
+		q.Step = d
+	}
+
+	res, err := s.prof.Query(q)
+	if err != nil {
+		logger.Warn("invalid query", "error", err)
+		util.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	logger.Debug("served metrics query", "field", q.Field, "agg", q.Agg, "tier", res.Tier, "points", len(res.Points))
+	util.WriteJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handleMetricsDiff(w http.ResponseWriter, r *http.Request) {
+	logger := s.logger.With("path", "/v1/metrics/diff", "method", r.Method)
+
+	if r.Method != http.MethodGet {
+		logger.Warn("invalid method")
+		util.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	q := profiler.DiffQuery{Limit: parseIntQuery(r, "limit", 10)}
+
+	var err error
+	if q.From, err = parseTimeQuery(r, "from"); err != nil || q.From.IsZero() {
+		logger.Warn("invalid from", "error", err)
+		util.WriteError(w, http.StatusBadRequest, "from is required (RFC3339 or Unix seconds)")
+		return
+	}
+	if q.To, err = parseTimeQuery(r, "to"); err != nil {
+		logger.Warn("invalid to", "error", err)
+		util.WriteError(w, http.StatusBadRequest, "to must be RFC3339 or Unix seconds")
+		return
+	}
+	if raw := r.URL.Query().Get("window"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			logger.Warn("invalid window", "window", raw)
+			util.WriteError(w, http.StatusBadRequest, "window must be a positive duration")
+			return
+		}
+		q.Window = d
+	}
+
+	diff, err := s.prof.Diff(q)
+	if err != nil {
+		logger.Warn("invalid diff", "error", err)
+		util.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	logger.Debug("served metrics diff", "from", diff.From, "to", diff.To, "appeared", len(diff.Appeared), "disappeared", len(diff.Disappeared))
+	util.WriteJSON(w, http.StatusOK, diff)
+}
+
 func (s *Server) handleTopRetentions(w http.ResponseWriter, r *http.Request) {
 	logger := s.logger.With("path", "/v1/metrics/retentions/top", "method", r.Method)
 
@@ -95,5 +251,64 @@ func parseIntQuery(r *http.Request, key string, def int) int {
 	return v
 }
 
+// parseTimeQuery parses key as RFC3339 or integer Unix seconds. A missing
+// parameter yields the zero time.
+func parseTimeQuery(r *http.Request, key string) (time.Time, error) {
+	raw := r.URL.Query().Get(key)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if secs, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
 // We may use ctx and logger further for tracing; keep imports alive.
 var _ = time.Now
+
+// handleForecast serves the time-to-OOM forecast for the latest snapshot.
+func (s *Server) handleForecast(w http.ResponseWriter, r *http.Request) {
+	logger := s.logger.With("path", "/v1/forecast", "method", r.Method)
+
+	if r.Method != http.MethodGet {
+		logger.Warn("invalid method")
+		util.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	f := s.prof.Forecast()
+	logger.Debug("served forecast", "seconds_to_limit", f.SecondsToLimit)
+	util.WriteJSON(w, http.StatusOK, f)
+}
+
+// handleSizeClasses serves per size class allocation stats of the latest
+// sample.
+func (s *Server) handleSizeClasses(w http.ResponseWriter, r *http.Request) {
+	logger := s.logger.With("path", "/v1/metrics/size-classes", "method", r.Method)
+
+	if r.Method != http.MethodGet {
+		logger.Warn("invalid method")
+		util.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	classes := s.prof.SizeClasses()
+	logger.Debug("served size classes", "count", len(classes))
+	util.WriteJSON(w, http.StatusOK, classes)
+}
+
+// handleSelf serves the profiler's own overhead.
+func (s *Server) handleSelf(w http.ResponseWriter, r *http.Request) {
+	logger := s.logger.With("path", "/v1/self", "method", r.Method)
+
+	if r.Method != http.MethodGet {
+		logger.Warn("invalid method")
+		util.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	st := s.prof.SelfStats()
+	logger.Debug("served self stats", "overhead_percent", st.OverheadPercent)
+	util.WriteJSON(w, http.StatusOK, st)
+}
@@ -19,3 +19,64 @@ func (s *Server) handleSuggestions(w http.ResponseWriter, r *http.Request) {
 	logger.Debug("served suggestions", "count", len(suggestions))
 	util.WriteJSON(w, http.StatusOK, suggestions)
 }
+
+// handleLeaks serves the latest leak detection report.
+func (s *Server) handleLeaks(w http.ResponseWriter, r *http.Request) {
+	logger := s.logger.With("path", "/v1/suggestions/leaks", "method", r.Method)
+
+	if r.Method != http.MethodGet {
+		logger.Warn("invalid method")
+		util.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	rep := s.prof.Leaks()
+	logger.Debug("served leak report", "retentions", len(rep.Retentions))
+	util.WriteJSON(w, http.StatusOK, rep)
+}
+
+// handleGCTuning serves the latest GOGC/GOMEMLIMIT tuning advice.
+func (s *Server) handleGCTuning(w http.ResponseWriter, r *http.Request) {
+	logger := s.logger.With("path", "/v1/suggestions/gc-tuning", "method", r.Method)
+
+	if r.Method != http.MethodGet {
+		logger.Warn("invalid method")
+		util.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	rep := s.prof.GCTuning()
+	logger.Debug("served gc tuning report", "suggestions", len(rep.Suggestions))
+	util.WriteJSON(w, http.StatusOK, rep)
+}
+
+// handleGCController serves the GC controller status and audit log.
+func (s *Server) handleGCController(w http.ResponseWriter, r *http.Request) {
+	logger := s.logger.With("path", "/v1/gc-controller", "method", r.Method)
+
+	if r.Method != http.MethodGet {
+		logger.Warn("invalid method")
+		util.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	st := s.prof.GCController()
+	logger.Debug("served gc controller status", "adjustments", len(st.Adjustments))
+	util.WriteJSON(w, http.StatusOK, st)
+}
+
+// handleGCControllerKill stops the GC controller and restores the settings
+// it changed.
+func (s *Server) handleGCControllerKill(w http.ResponseWriter, r *http.Request) {
+	logger := s.logger.With("path", "/v1/gc-controller/kill", "method", r.Method)
+
+	if r.Method != http.MethodPost {
+		logger.Warn("invalid method")
+		util.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	st := s.prof.KillGCController()
+	logger.Info("gc controller killed", "gogc", st.GOGCPercent, "memory_limit_bytes", st.MemoryLimitBytes)
+	util.WriteJSON(w, http.StatusOK, st)
+}
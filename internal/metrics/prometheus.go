@@ -1,74 +1,410 @@
 package metrics
 
 import (
-"net/http"
+	"net/http"
 
-"github.com/prometheus/client_golang/prometheus"
-"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type prometheusExporter struct {
-heapAllocGauge prometheus.Gauge
-heapInuseGauge prometheus.Gauge
-heapIdleGauge  prometheus.Gauge
-heapReleased   prometheus.Gauge
-numGCGauge     prometheus.Gauge
-capturesGauge  prometheus.Gauge
+	heapAllocGauge prometheus.Gauge
+	heapInuseGauge prometheus.Gauge
+	heapIdleGauge  prometheus.Gauge
+	heapReleased   prometheus.Gauge
+	numGCGauge     prometheus.Gauge
+	capturesGauge  prometheus.Gauge
+	intervalGauge  prometheus.Gauge
+
+	heapLiveGauge     prometheus.Gauge
+	heapObjectsGauge  prometheus.Gauge
+	heapGoalGauge     prometheus.Gauge
+	stackGauge        prometheus.Gauge
+	mspanInuseGauge   prometheus.Gauge
+	mcacheInuseGauge  prometheus.Gauge
+	runtimeTotalGauge prometheus.Gauge
+	goroutinesGauge   prometheus.Gauge
+	gogcGauge         prometheus.Gauge
+	memLimitGauge     prometheus.Gauge
+	gcCPUSeconds      prometheus.Gauge
+	gcCPUFraction     prometheus.Gauge
+	gcPauseQuantiles  *prometheus.GaugeVec
+	schedLatQuantiles *prometheus.GaugeVec
+
+	allocSeriesGauge prometheus.Gauge
+	foldedCalls      prometheus.Gauge
+	seriesEvicted    prometheus.Gauge
+	distinctTags     prometheus.Gauge
+
+	memoryLimitGauge prometheus.Gauge
+	oomForecastGauge prometheus.Gauge
+
+	rssGauge              prometheus.Gauge
+	nonGoGauge            prometheus.Gauge
+	cgroupUsageGauge      prometheus.Gauge
+	cgroupWorkingSetGauge prometheus.Gauge
+	cgroupLimitGauge      prometheus.Gauge
+	cgroupOOMKills        prometheus.Gauge
+
+	gcControllerActive      prometheus.Gauge
+	gcControllerGOGC        prometheus.Gauge
+	gcControllerMemLimit    prometheus.Gauge
+	gcControllerAdjustments prometheus.Gauge
+
+	idleNotReleasedGauge prometheus.Gauge
+	unusedInSpanGauge    prometheus.Gauge
+	spanOverheadGauge    prometheus.Gauge
+	smallAllocsGauge     prometheus.Gauge
+
+	selfSamples       prometheus.Gauge
+	selfSampleSeconds prometheus.Gauge
+	selfTrackCalls    prometheus.Gauge
+	selfTrackSeconds  prometheus.Gauge
+	selfLockWait      prometheus.Gauge
+	selfMemory        *prometheus.GaugeVec
+	selfSeries        prometheus.Gauge
+	selfOverhead      prometheus.Gauge
 }
 
 // prometheusHandler returns an http.Handler that exposes Prometheus metrics.
 func (s *Server) prometheusHandler() http.Handler {
-reg := prometheus.NewRegistry()
-
-exp := &prometheusExporter{
-heapAllocGauge: prometheus.NewGauge(prometheus.GaugeOpts{
-Name: "goprof_heap_alloc_bytes",
-Help: "Bytes of allocated heap memory according to latest snapshot.",
-}),
-heapInuseGauge: prometheus.NewGauge(prometheus.GaugeOpts{
-Name: "goprof_heap_inuse_bytes",
-Help: "Bytes of heap in use according to latest snapshot.",
-}),
-heapIdleGauge: prometheus.NewGauge(prometheus.GaugeOpts{
-Name: "goprof_heap_idle_bytes",
-Help: "Bytes of idle heap memory according to latest snapshot.",
-}),
-heapReleased: prometheus.NewGauge(prometheus.GaugeOpts{
-Name: "goprof_heap_released_bytes",
-Help: "Bytes of heap released to the OS according to latest snapshot.",
-}),
-numGCGauge: prometheus.NewGauge(prometheus.GaugeOpts{
-Name: "goprof_num_gc",
-Help: "Number of completed GC cycles according to latest snapshot.",
-}),
-capturesGauge: prometheus.NewGauge(prometheus.GaugeOpts{
-Name: "goprof_profile_captures_total",
-Help: "Total number of automatic heap profile captures performed by the profiler.",
-}),
-}
+	reg := prometheus.NewRegistry()
 
-reg.MustRegister(
-exp.heapAllocGauge,
-exp.heapInuseGauge,
-exp.heapIdleGauge,
-exp.heapReleased,
-exp.numGCGauge,
-exp.capturesGauge,
-)
+	exp := &prometheusExporter{
+		heapAllocGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_heap_alloc_bytes",
+			Help: "Bytes of allocated heap memory according to latest snapshot.",
+		}),
+		heapInuseGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_heap_inuse_bytes",
+			Help: "Bytes of heap in use according to latest snapshot.",
+		}),
+		heapIdleGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_heap_idle_bytes",
+			Help: "Bytes of idle heap memory according to latest snapshot.",
+		}),
+		heapReleased: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_heap_released_bytes",
+			Help: "Bytes of heap released to the OS according to latest snapshot.",
+		}),
+		numGCGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_num_gc",
+			Help: "Number of completed GC cycles according to latest snapshot.",
+		}),
+		capturesGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_profile_captures_total",
+			Help: "Total number of automatic heap profile captures performed by the profiler.",
+		}),
+		intervalGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_sampling_interval_seconds",
+			Help: "Sampling interval in effect; varies with adaptive sampling.",
+		}),
+		heapLiveGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_heap_live_bytes",
+			Help: "Heap bytes marked live by the last GC according to latest snapshot.",
+		}),
+		heapObjectsGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_heap_objects",
+			Help: "Number of objects occupying heap memory according to latest snapshot.",
+		}),
+		heapGoalGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_heap_goal_bytes",
+			Help: "Heap size target for the end of the current GC cycle according to latest snapshot.",
+		}),
+		stackGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_stack_bytes",
+			Help: "Bytes of goroutine and OS thread stacks according to latest snapshot.",
+		}),
+		mspanInuseGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_mspan_inuse_bytes",
+			Help: "Bytes of memory used by in-use mspan structures according to latest snapshot.",
+		}),
+		mcacheInuseGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_mcache_inuse_bytes",
+			Help: "Bytes of memory used by in-use mcache structures according to latest snapshot.",
+		}),
+		runtimeTotalGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_runtime_total_bytes",
+			Help: "All memory mapped by the Go runtime according to latest snapshot.",
+		}),
+		goroutinesGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_goroutines",
+			Help: "Number of live goroutines according to latest snapshot.",
+		}),
+		gogcGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_gogc_percent",
+			Help: "Current GOGC setting according to latest snapshot.",
+		}),
+		memLimitGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_gomemlimit_bytes",
+			Help: "Current GOMEMLIMIT setting according to latest snapshot.",
+		}),
+		gcCPUSeconds: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_gc_cpu_seconds",
+			Help: "Estimated CPU time spent in the GC since process start according to latest snapshot.",
+		}),
+		gcCPUFraction: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_gc_cpu_fraction",
+			Help: "Fraction of process CPU time spent in the GC according to latest snapshot.",
+		}),
+		gcPauseQuantiles: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "goprof_gc_pause_seconds",
+			Help: "Quantiles of GC stop-the-world pause latencies since process start.",
+		}, []string{"quantile"}),
+		schedLatQuantiles: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "goprof_sched_latency_seconds",
+			Help: "Quantiles of time goroutines spent runnable before running, since process start.",
+		}, []string{"quantile"}),
+		allocSeriesGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_alloc_series",
+			Help: "Number of (type, tag) allocation series tracked by the profiler.",
+		}),
+		foldedCalls: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_alloc_folded_calls_total",
+			Help: "TrackAllocation calls folded into an overflow series because max_alloc_series was reached.",
+		}),
+		seriesEvicted: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_alloc_series_evicted_total",
+			Help: "Cold allocation series evicted to stay within max_alloc_series.",
+		}),
+		distinctTags: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_alloc_distinct_tags",
+			Help: "Estimated distinct allocation tags (HyperLogLog, sketch accounting mode only).",
+		}),
+		memoryLimitGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_memory_limit_bytes",
+			Help: "Effective memory limit (lower of GOMEMLIMIT and the cgroup limit); 0 when unknown.",
+		}),
+		oomForecastGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_oom_forecast_seconds",
+			Help: "Predicted seconds until memory reaches the effective limit at the current heap growth; -1 when none is predicted.",
+		}),
+		rssGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_rss_bytes",
+			Help: "Resident set size of the process from procfs according to latest snapshot.",
+		}),
+		nonGoGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_non_go_bytes",
+			Help: "RSS not held by the Go runtime (cgo, mmap) according to latest snapshot.",
+		}),
+		cgroupUsageGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_cgroup_usage_bytes",
+			Help: "Memory usage of the process's cgroup according to latest snapshot.",
+		}),
+		cgroupWorkingSetGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_cgroup_working_set_bytes",
+			Help: "Cgroup memory usage minus inactive page cache according to latest snapshot.",
+		}),
+		cgroupLimitGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_cgroup_limit_bytes",
+			Help: "Memory limit of the process's cgroup (0 when unlimited) according to latest snapshot.",
+		}),
+		cgroupOOMKills: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_cgroup_oom_kills_total",
+			Help: "OOM kills recorded by the process's cgroup according to latest snapshot.",
+		}),
+		gcControllerActive: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_gc_controller_active",
+			Help: "1 when the GC controller is enabled and not killed, else 0.",
+		}),
+		gcControllerGOGC: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_gc_controller_gogc_percent",
+			Help: "GOGC currently in effect (-1 when off).",
+		}),
+		gcControllerMemLimit: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_gc_controller_memory_limit_bytes",
+			Help: "Memory limit currently in effect (0 when unset).",
+		}),
+		gcControllerAdjustments: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_gc_controller_adjustments_total",
+			Help: "Adjustments made by the GC controller, including kill switch restores.",
+		}),
+		idleNotReleasedGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_heap_idle_not_released_bytes",
+			Help: "Free heap memory not yet returned to the OS according to latest snapshot.",
+		}),
+		unusedInSpanGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_heap_unused_in_span_bytes",
+			Help: "Memory in in-use spans that holds no objects according to latest snapshot.",
+		}),
+		spanOverheadGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_span_overhead_percent",
+			Help: "Span metadata as a percentage of heap in use according to latest snapshot.",
+		}),
+		smallAllocsGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_small_allocs_per_second",
+			Help: "Heap allocations of at most 128 bytes per second according to latest snapshot.",
+		}),
+		selfSamples: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_self_samples_total",
+			Help: "Samples taken by the profiler.",
+		}),
+		selfSampleSeconds: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_self_sample_seconds_total",
+			Help: "Time spent taking samples.",
+		}),
+		selfTrackCalls: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_self_track_allocation_calls_total",
+			Help: "TrackAllocation calls.",
+		}),
+		selfTrackSeconds: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_self_track_allocation_seconds_total",
+			Help: "Time spent in TrackAllocation, estimated from one in 64 calls.",
+		}),
+		selfLockWait: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_self_lock_wait_seconds_total",
+			Help: "Time spent waiting for the profiler's state lock, estimated from one in 16 acquisitions.",
+		}),
+		selfMemory: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "goprof_self_memory_bytes",
+			Help: "Approximate memory held by the profiler's history, allocation series and retentions.",
+		}, []string{"component"}),
+		selfSeries: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_self_series",
+			Help: "Allocation series (or sketch counters) tracked by the profiler.",
+		}),
+		selfOverhead: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goprof_self_overhead_percent",
+			Help: "Time spent sampling and in TrackAllocation as a percentage of one CPU over the last minute.",
+		}),
+	}
 
-update := func() {
-snap := s.prof.LatestSnapshot()
-exp.heapAllocGauge.Set(float64(snap.HeapAllocBytes))
-exp.heapInuseGauge.Set(float64(snap.HeapInuseBytes))
-exp.heapIdleGauge.Set(float64(snap.HeapIdleBytes))
-exp.heapReleased.Set(float64(snap.HeapReleased))
-exp.numGCGauge.Set(float64(snap.NumGC))
-exp.capturesGauge.Set(float64(s.prof.CaptureCount()))
-}
+	reg.MustRegister(
+		exp.heapAllocGauge,
+		exp.heapInuseGauge,
+		exp.heapIdleGauge,
+		exp.heapReleased,
+		exp.numGCGauge,
+		exp.capturesGauge,
+		exp.intervalGauge,
+		exp.heapLiveGauge,
+		exp.heapObjectsGauge,
+		exp.heapGoalGauge,
+		exp.stackGauge,
+		exp.mspanInuseGauge,
+		exp.mcacheInuseGauge,
+		exp.runtimeTotalGauge,
+		exp.goroutinesGauge,
+		exp.gogcGauge,
+		exp.memLimitGauge,
+		exp.gcCPUSeconds,
+		exp.gcCPUFraction,
+		exp.gcPauseQuantiles,
+		exp.schedLatQuantiles,
+		exp.allocSeriesGauge,
+		exp.foldedCalls,
+		exp.seriesEvicted,
+		exp.distinctTags,
+		exp.memoryLimitGauge,
+		exp.oomForecastGauge,
+		exp.rssGauge,
+		exp.nonGoGauge,
+		exp.cgroupUsageGauge,
+		exp.cgroupWorkingSetGauge,
+		exp.cgroupLimitGauge,
+		exp.cgroupOOMKills,
+		exp.gcControllerActive,
+		exp.gcControllerGOGC,
+		exp.gcControllerMemLimit,
+		exp.gcControllerAdjustments,
+		exp.idleNotReleasedGauge,
+		exp.unusedInSpanGauge,
+		exp.spanOverheadGauge,
+		exp.smallAllocsGauge,
+		exp.selfSamples,
+		exp.selfSampleSeconds,
+		exp.selfTrackCalls,
+		exp.selfTrackSeconds,
+		exp.selfLockWait,
+		exp.selfMemory,
+		exp.selfSeries,
+		exp.selfOverhead,
+	)
 
-return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-update()
-promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
-})
+	update := func() {
+		snap := s.prof.LatestSnapshot()
+		exp.heapAllocGauge.Set(float64(snap.HeapAllocBytes))
+		exp.heapInuseGauge.Set(float64(snap.HeapInuseBytes))
+		exp.heapIdleGauge.Set(float64(snap.HeapIdleBytes))
+		exp.heapReleased.Set(float64(snap.HeapReleased))
+		exp.numGCGauge.Set(float64(snap.NumGC))
+		exp.capturesGauge.Set(float64(s.prof.CaptureCount()))
+		exp.intervalGauge.Set(s.prof.SamplingInterval().Seconds())
+
+		exp.heapLiveGauge.Set(float64(snap.HeapLiveBytes))
+		exp.heapObjectsGauge.Set(float64(snap.HeapObjects))
+		exp.heapGoalGauge.Set(float64(snap.NextGCBytes))
+		exp.stackGauge.Set(float64(snap.StackBytes))
+		exp.mspanInuseGauge.Set(float64(snap.MSpanInuseBytes))
+		exp.mcacheInuseGauge.Set(float64(snap.MCacheInuseBytes))
+		exp.runtimeTotalGauge.Set(float64(snap.RuntimeTotalBytes))
+		exp.goroutinesGauge.Set(float64(snap.Goroutines))
+		exp.gogcGauge.Set(float64(snap.GOGCPercent))
+		exp.memLimitGauge.Set(float64(snap.GOMemLimitBytes))
+		exp.gcCPUSeconds.Set(snap.GCCPUSeconds)
+		exp.gcCPUFraction.Set(snap.GCCPUFraction)
+
+		series := s.prof.SeriesStats()
+		exp.allocSeriesGauge.Set(float64(series.Series))
+		exp.foldedCalls.Set(float64(series.FoldedCalls))
+		exp.seriesEvicted.Set(float64(series.Evicted))
+		exp.distinctTags.Set(float64(series.DistinctTags))
+
+		exp.memoryLimitGauge.Set(float64(snap.Forecast.LimitBytes))
+		exp.rssGauge.Set(float64(snap.RSSBytes))
+		exp.nonGoGauge.Set(float64(snap.NonGoBytes))
+		exp.cgroupUsageGauge.Set(float64(snap.CgroupUsageBytes))
+		exp.cgroupWorkingSetGauge.Set(float64(snap.CgroupWorkingSetBytes))
+		exp.cgroupLimitGauge.Set(float64(snap.CgroupLimitBytes))
+		exp.cgroupOOMKills.Set(float64(snap.CgroupOOMKills))
+
+		exp.idleNotReleasedGauge.Set(float64(snap.Fragmentation.IdleNotReleasedBytes))
+		exp.unusedInSpanGauge.Set(float64(snap.Fragmentation.UnusedInSpanBytes))
+		exp.spanOverheadGauge.Set(snap.Fragmentation.SpanOverheadPercent)
+		exp.smallAllocsGauge.Set(snap.Fragmentation.SmallAllocsPerSec)
+
+		self := s.prof.SelfStats()
+		exp.selfSamples.Set(float64(self.SamplesTotal))
+		exp.selfSampleSeconds.Set(self.SampleSecondsTotal)
+		exp.selfTrackCalls.Set(float64(self.TrackAllocationCallsTotal))
+		exp.selfTrackSeconds.Set(self.TrackAllocationSecondsTotal)
+		exp.selfLockWait.Set(self.LockWaitSecondsTotal)
+		exp.selfMemory.WithLabelValues("history").Set(float64(self.HistoryBytes))
+		exp.selfMemory.WithLabelValues("allocs").Set(float64(self.AllocsBytes))
+		exp.selfMemory.WithLabelValues("retentions").Set(float64(self.RetentionsBytes))
+		exp.selfSeries.Set(float64(self.Series))
+		exp.selfOverhead.Set(self.OverheadPercent)
+
+		ctl := s.prof.GCController()
+		if ctl.Active {
+			exp.gcControllerActive.Set(1)
+		} else {
+			exp.gcControllerActive.Set(0)
+		}
+		exp.gcControllerGOGC.Set(float64(ctl.GOGCPercent))
+		exp.gcControllerMemLimit.Set(float64(ctl.MemoryLimitBytes))
+		exp.gcControllerAdjustments.Set(float64(ctl.AdjustmentsTotal))
+
+		// Snapshots taken before forecasting existed carry a zero value.
+		if snap.Forecast.LimitBytes > 0 {
+			exp.oomForecastGauge.Set(snap.Forecast.SecondsToLimit)
+		} else {
+			exp.oomForecastGauge.Set(-1)
+		}
+
+		setQuantiles(exp.gcPauseQuantiles, snap.GCPauses.P50Seconds, snap.GCPauses.P90Seconds, snap.GCPauses.P99Seconds, snap.GCPauses.MaxSeconds)
+		setQuantiles(exp.schedLatQuantiles, snap.SchedLatency.P50Seconds, snap.SchedLatency.P90Seconds, snap.SchedLatency.P99Seconds, snap.SchedLatency.MaxSeconds)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		update()
+		promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
 }
 
+func setQuantiles(vec *prometheus.GaugeVec, p50, p90, p99, maxV float64) {
+	vec.WithLabelValues("0.5").Set(p50)
+	vec.WithLabelValues("0.9").Set(p90)
+	vec.WithLabelValues("0.99").Set(p99)
+	vec.WithLabelValues("1").Set(maxV)
+}
@@ -55,12 +55,27 @@ func (s *Server) Router() http.Handler {
 	// Metrics + profiler endpoints.
 	mux.HandleFunc("/v1/metrics/latest", s.handleMetricsLatest)
 	mux.HandleFunc("/v1/metrics/history", s.handleMetricsHistory)
+	mux.HandleFunc("/v1/metrics/query", s.handleMetricsQuery)
+	mux.HandleFunc("/v1/metrics/diff", s.handleMetricsDiff)
 	mux.HandleFunc("/v1/metrics/allocations/top", s.handleTopAllocations)
 	mux.HandleFunc("/v1/metrics/retentions/top", s.handleTopRetentions)
+	mux.HandleFunc("/v1/metrics/size-classes", s.handleSizeClasses)
+	mux.HandleFunc("/v1/forecast", s.handleForecast)
+	mux.HandleFunc("/v1/self", s.handleSelf)
 
 	// Suggestions + alerts.
 	mux.HandleFunc("/v1/suggestions", s.handleSuggestions)
+	mux.HandleFunc("/v1/suggestions/leaks", s.handleLeaks)
+	mux.HandleFunc("/v1/suggestions/gc-tuning", s.handleGCTuning)
 	mux.HandleFunc("/v1/alerts", s.handleAlerts)
+
+	// GC controller. The kill switch is unauthenticated, so it only exists
+	// when there is a controller to stop.
+	mux.HandleFunc("/v1/gc-controller", s.handleGCController)
+	if s.cfg.GCControllerEnabled {
+		mux.HandleFunc("/v1/gc-controller/kill", s.handleGCControllerKill)
+	}
+
 	// Manual capture endpoints.
 	mux.HandleFunc("/v1/capture/heap", s.handleCaptureHeap)
 
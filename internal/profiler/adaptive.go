@@ -0,0 +1,80 @@
+package profiler
+
+import (
+	"math"
+	"time"
+)
+
+// minVolatileAllocRate is the allocation rate below which changes of the
+// rate do not count as volatility.
+const minVolatileAllocRate = 1 << 20
+
+// adaptiveSampler is the state of the adaptive sampling interval. It is only
+// used from the sampling goroutine.
+type adaptiveSampler struct {
+	at        time.Time
+	heap      uint64
+	total     uint64
+	allocRate float64
+	hasRate   bool
+}
+
+// SamplingInterval returns the sampling interval in effect: SamplingIntervalMs,
+// or the current adaptive interval with adaptive sampling enabled.
+func (p *Profiler) SamplingInterval() time.Duration {
+	return time.Duration(p.interval.Load())
+}
+
+// adaptInterval sets the next sampling interval from the change of the live
+// heap and allocation rate since the previous sample and from cost, the time
+// the sample took. The interval doubles when cost exceeds the overhead
+// budget, halves when the heap or allocation rate moved by the volatility
+// threshold, and grows by a quarter while both moved by less than a quarter
+// of it.
+func (p *Profiler) adaptInterval(snap *ProfilerSnapshot, cost time.Duration) {
+	if !p.cfg.AdaptiveSamplingEnabled {
+		return
+	}
+	a := &p.adapt
+	prev := *a
+	heap := heapBaseline(float64(snap.HeapLiveBytes), float64(snap.HeapAllocBytes))
+	a.at, a.heap, a.total, a.hasRate = snap.Timestamp, uint64(heap), snap.TotalAllocBytes, false
+	dt := snap.Timestamp.Sub(prev.at).Seconds()
+	if prev.at.IsZero() || dt <= 0 || snap.TotalAllocBytes < prev.total {
+		return
+	}
+	a.allocRate, a.hasRate = float64(snap.TotalAllocBytes-prev.total)/dt, true
+	if !prev.hasRate {
+		return
+	}
+
+	change := math.Abs(percentChange(float64(prev.heap), heap))
+	if max(prev.allocRate, a.allocRate) >= minVolatileAllocRate {
+		change = max(change, math.Abs(percentChange(prev.allocRate, a.allocRate)))
+	}
+
+	cur := p.SamplingInterval()
+	budget := p.cfg.SamplingOverheadBudgetPercent / 100
+	volatility := p.cfg.SamplingVolatilityPercent
+	next := cur
+	switch {
+	case cost.Seconds() > budget*cur.Seconds():
+		next = cur * 2
+	case change >= volatility:
+		next = cur / 2
+	case change < volatility/4:
+		next = cur * 5 / 4
+	}
+	// Never shorten the interval past the overhead budget.
+	next = max(next, time.Duration(float64(cost)/budget))
+	minI := time.Duration(p.cfg.SamplingMinIntervalMs) * time.Millisecond
+	maxI := time.Duration(p.cfg.SamplingMaxIntervalMs) * time.Millisecond
+	next = min(max(next, minI), maxI).Round(time.Millisecond)
+
+	if next != cur {
+		p.interval.Store(int64(next))
+		p.logger.Debug("sampling interval adapted",
+			"from_ms", cur.Milliseconds(), "to_ms", next.Milliseconds(),
+			"change_percent", change, "cost", cost)
+	}
+}
@@ -0,0 +1,167 @@
+package profiler
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// OverflowTag is reported for allocations folded into a type's overflow
+	// series once MaxAllocSeries is reached.
+	OverflowTag = "other"
+
+	// overflowKeySuffix keeps overflow series apart from a user tag that
+	// happens to be "other".
+	overflowKeySuffix = "|\x00" + OverflowTag
+
+	// evictionLowWatermark is the fraction of MaxAllocSeries eviction aims
+	// for, so it does not run again on the very next sample.
+	evictionLowWatermark = 0.9
+)
+
+// SeriesStats describes the size of the profiler's own allocation map.
+type SeriesStats struct {
+	// Series is the number of (type, tag) series currently tracked.
+	Series int `json:"series"`
+	// FoldedCalls counts TrackAllocation calls folded into an overflow
+	// series because the series limit was reached. It counts calls, not
+	// distinct refused series, which would need an unbounded set.
+	FoldedCalls uint64 `json:"folded_calls"`
+	// Evicted counts cold series removed to make room for new ones.
+	Evicted uint64 `json:"evicted"`
+
+	// Mode is the allocation accounting mode. In sketch mode Series is the
+	// number of heavy-hitter counters and DistinctTags a HyperLogLog
+	// estimate of the tags seen.
+	Mode         string `json:"mode"`
+	DistinctTags uint64 `json:"distinct_tags,omitempty"`
+}
+
+// entryFor returns the shard entry for key, registering a new series if the
+// limit allows. Otherwise the call is folded into the type's overflow
+// series, which does not count towards the limit; types are bounded by the
+// program so the overflow series are too.
+func (p *Profiler) entryFor(key, typeName, tag, source string) *shardEntry {
+	sh := p.shardFor()
+	if v, ok := sh.entries.Load(key); ok {
+		return v.(*shardEntry)
+	}
+
+	overflow := false
+	if !p.admitSeries(key) {
+		p.foldedCalls.Add(1)
+		key = typeName + overflowKeySuffix
+		tag = OverflowTag
+		overflow = true
+		if v, ok := sh.entries.Load(key); ok {
+			return v.(*shardEntry)
+		}
+	}
+
+	v, _ := sh.entries.LoadOrStore(key, &shardEntry{
+		typeName: typeName,
+		tag:      tag,
+		source:   source,
+		overflow: overflow,
+	})
+	return v.(*shardEntry)
+}
+
+// admitSeries reports whether key is (or may become) a tracked series.
+func (p *Profiler) admitSeries(key string) bool {
+	if _, ok := p.series.Load(key); ok {
+		return true
+	}
+
+	limit := int64(p.cfg.MaxAllocSeries)
+	if limit <= 0 {
+		p.series.Store(key, struct{}{})
+		p.seriesCount.Add(1)
+		return true
+	}
+
+	// Reserve a slot first so concurrent admissions cannot overshoot.
+	if p.seriesCount.Add(1) > limit {
+		p.seriesCount.Add(-1)
+		return false
+	}
+	if _, loaded := p.series.LoadOrStore(key, struct{}{}); loaded {
+		p.seriesCount.Add(-1)
+	}
+	return true
+}
+
+// evictColdSeriesLocked removes the least recently active series once the
+// limit is reached, down to evictionLowWatermark of it. Series that still
+// have live objects are kept since they are what retention reports on.
+// Caller must hold p.mu.
+func (p *Profiler) evictColdSeriesLocked() {
+	limit := p.cfg.MaxAllocSeries
+	if limit <= 0 || int(p.seriesCount.Load()) < limit {
+		return
+	}
+	target := int64(float64(limit) * evictionLowWatermark)
+
+	type candidate struct {
+		key      string
+		lastSeen time.Time
+	}
+	cands := make([]candidate, 0, len(p.allocs))
+	for key, st := range p.allocs {
+		if st.overflow || p.live[key].objects > 0 {
+			continue
+		}
+		cands = append(cands, candidate{key: key, lastSeen: st.lastSeen})
+	}
+	sort.Slice(cands, func(i, j int) bool {
+		return cands[i].lastSeen.Before(cands[j].lastSeen)
+	})
+
+	for _, c := range cands {
+		if p.seriesCount.Load() <= target {
+			break
+		}
+		p.dropSeriesLocked(c.key)
+	}
+}
+
+// dropSeriesLocked forgets everything about key. Updates racing with the
+// removal may be lost, which is acceptable for a series that was idle.
+// Caller must hold p.mu.
+func (p *Profiler) dropSeriesLocked(key string) {
+	delete(p.allocs, key)
+	delete(p.rates, key)
+	delete(p.retentions, key)
+	delete(p.live, key)
+	for i := range p.shards {
+		p.shards[i].entries.Delete(key)
+	}
+	if _, ok := p.series.LoadAndDelete(key); ok {
+		p.seriesCount.Add(-1)
+		p.seriesEvicted.Add(1)
+	}
+}
+
+// SeriesStats reports the current series count and overflow/eviction
+// counters.
+func (p *Profiler) SeriesStats() SeriesStats {
+	p.rlockMu()
+	defer p.mu.RUnlock()
+	return p.seriesStatsLocked()
+}
+
+func (p *Profiler) seriesStatsLocked() SeriesStats {
+	if p.sketch != nil {
+		return SeriesStats{
+			Series:       p.sketch.counters(),
+			Mode:         AccountingSketch,
+			DistinctTags: p.sketch.merge().distinct,
+		}
+	}
+	return SeriesStats{
+		Series:      len(p.allocs),
+		FoldedCalls: p.foldedCalls.Load(),
+		Evicted:     p.seriesEvicted.Load(),
+		Mode:        AccountingExact,
+	}
+}
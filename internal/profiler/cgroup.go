@@ -0,0 +1,159 @@
+package profiler
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cgroupUnlimited is the smallest cgroup v1 limit treated as "no limit"; v1
+// reports an unset limit as a huge page-aligned number.
+const cgroupUnlimited = 1 << 62
+
+// cgroupFS reads the memory accounting of the process's cgroup.
+type cgroupFS struct {
+	version int    // 1 or 2; 0 when no memory cgroup was found
+	dir     string // memory cgroup directory of the process
+}
+
+// openCgroup locates the process's memory cgroup under root, the cgroup
+// mount. The cgroup path comes from <procRoot>/self/cgroup; when that
+// directory is not visible (e.g. a container without a cgroup namespace
+// that still mounts its own cgroup at root), root itself is used.
+func openCgroup(root, procRoot string) cgroupFS {
+	if root == "" {
+		return cgroupFS{}
+	}
+
+	v2, v1 := procCgroupPaths(procRoot)
+	if fileExists(filepath.Join(root, "cgroup.controllers")) {
+		return cgroupFS{version: 2, dir: cgroupDir(root, v2, "memory.current")}
+	}
+	if mem := filepath.Join(root, "memory"); fileExists(mem) {
+		return cgroupFS{version: 1, dir: cgroupDir(mem, v1, "memory.usage_in_bytes")}
+	}
+	return cgroupFS{}
+}
+
+// cgroupDir joins base and rel when that directory has probe, falling back
+// to base.
+func cgroupDir(base, rel, probe string) string {
+	if rel != "" && rel != "/" {
+		dir := filepath.Join(base, rel)
+		if fileExists(filepath.Join(dir, probe)) {
+			return dir
+		}
+	}
+	return base
+}
+
+// procCgroupPaths returns the unified (v2) and v1 memory controller paths
+// from <procRoot>/self/cgroup.
+func procCgroupPaths(procRoot string) (v2, v1 string) {
+	if procRoot == "" {
+		return "", ""
+	}
+	b, err := os.ReadFile(filepath.Join(procRoot, "self", "cgroup"))
+	if err != nil {
+		return "", ""
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		// hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		switch {
+		case parts[0] == "0" && parts[1] == "":
+			v2 = parts[2]
+		case containsString(strings.Split(parts[1], ","), "memory"):
+			v1 = parts[2]
+		}
+	}
+	return v2, v1
+}
+
+// read fills the cgroup fields of s. Files that are missing leave their
+// fields at zero.
+func (c cgroupFS) read(s *containerSample) {
+	s.cgroupVersion = c.version
+	switch c.version {
+	case 2:
+		s.cgroupUsage, _ = readCgroupValue(filepath.Join(c.dir, "memory.current"))
+		s.cgroupLimit, _ = readCgroupValue(filepath.Join(c.dir, "memory.max"))
+		stat := readKeyValues(filepath.Join(c.dir, "memory.stat"))
+		s.cgroupInactiveFile = stat["inactive_file"]
+		events := readKeyValues(filepath.Join(c.dir, "memory.events"))
+		s.cgroupOOMEvents = events["oom"]
+		s.cgroupOOMKills = events["oom_kill"]
+	case 1:
+		s.cgroupUsage, _ = readCgroupValue(filepath.Join(c.dir, "memory.usage_in_bytes"))
+		s.cgroupLimit, _ = readCgroupValue(filepath.Join(c.dir, "memory.limit_in_bytes"))
+		stat := readKeyValues(filepath.Join(c.dir, "memory.stat"))
+		s.cgroupInactiveFile = stat["total_inactive_file"]
+		if s.cgroupInactiveFile == 0 {
+			s.cgroupInactiveFile = stat["inactive_file"]
+		}
+		// v1 only counts kills (kernel 4.13+), not OOM events.
+		s.cgroupOOMKills = readKeyValues(filepath.Join(c.dir, "memory.oom_control"))["oom_kill"]
+	}
+	if s.cgroupLimit >= cgroupUnlimited {
+		s.cgroupLimit = 0
+	}
+}
+
+// readCgroupValue parses a single-value cgroup file. "max" is reported as
+// cgroupUnlimited.
+func readCgroupValue(path string) (uint64, bool) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	s := strings.TrimSpace(string(b))
+	if s == "max" {
+		return cgroupUnlimited, true
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// readKeyValues parses "key value" lines such as memory.stat. Lines whose
+// value is not an unsigned integer are skipped.
+func readKeyValues(path string) map[string]uint64 {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	out := make(map[string]uint64)
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), " ")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64); err == nil {
+			out[k] = n
+		}
+	}
+	return out
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, it := range list {
+		if it == s {
+			return true
+		}
+	}
+	return false
+}
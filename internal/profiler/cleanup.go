@@ -0,0 +1,19 @@
+package profiler
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+// attachCleanup arranges for releaseLive(r) to run once the object at ptr is
+// reclaimed and reports whether it could. The runtime offers no test for
+// whether ptr is in a Go heap block; AddCleanup panics when it is not.
+func attachCleanup(ptr unsafe.Pointer, r liveRelease) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	runtime.AddCleanup((*byte)(ptr), releaseLive, r)
+	return true
+}
@@ -0,0 +1,149 @@
+package profiler
+
+import (
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Clock supplies the profiler's notion of time: snapshot timestamps, rate
+// windows, history ranges and the sampling ticker. The profiler's own cost
+// accounting always uses the wall clock.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is the subset of time.Ticker used by the sampling loop.
+type Ticker interface {
+	C() <-chan time.Time
+	Reset(d time.Duration)
+	Stop()
+}
+
+// MemStatsSource supplies the memory statistics the profiler samples. When
+// set, it replaces the runtime/metrics reader; fields runtime.MemStats does
+// not carry, such as the live heap and GOGC, are reported as zero.
+type MemStatsSource interface {
+	ReadMemStats(ms *runtime.MemStats)
+}
+
+// MemStatsFunc adapts a function, such as runtime.ReadMemStats or a scripted
+// heap trace, to MemStatsSource.
+type MemStatsFunc func(ms *runtime.MemStats)
+
+// ReadMemStats calls f(ms).
+func (f MemStatsFunc) ReadMemStats(ms *runtime.MemStats) { f(ms) }
+
+// SystemClock returns the Clock backed by the time package.
+func SystemClock() Clock { return systemClock{} }
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{t: time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	t *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time   { return t.t.C }
+func (t systemTicker) Reset(d time.Duration) { t.t.Reset(d) }
+func (t systemTicker) Stop()                 { t.t.Stop() }
+
+// ManualClock is a Clock that only moves when Advance is called. Its
+// tickers fire from Advance and, like time.Ticker, drop ticks the receiver
+// is not ready for.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*manualTicker
+}
+
+// NewManualClock returns a ManualClock set to start.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now returns the clock's current time.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d and fires the tickers that came due.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.AdvanceTo(c.Now().Add(d))
+}
+
+// AdvanceTo moves the clock forward to to, if that is later than its
+// current time, and fires the tickers that came due.
+func (c *ManualClock) AdvanceTo(to time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !to.After(c.now) {
+		return
+	}
+	c.now = to
+	for _, t := range c.tickers {
+		if c.now.Before(t.next) {
+			continue
+		}
+		select {
+		case t.ch <- c.now:
+		default:
+		}
+		for !c.now.Before(t.next) {
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+// NewTicker returns a Ticker that fires every d of clock time.
+func (c *ManualClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("profiler: non-positive interval for NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &manualTicker{clock: c, ch: make(chan time.Time, 1), period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// manualTicker is a ManualClock ticker; its fields are guarded by the
+// clock's mutex.
+type manualTicker struct {
+	clock   *ManualClock
+	ch      chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (t *manualTicker) C() <-chan time.Time { return t.ch }
+
+func (t *manualTicker) Reset(d time.Duration) {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	if t.stopped {
+		t.clock.tickers = append(t.clock.tickers, t)
+	}
+	t.period, t.next, t.stopped = d, t.clock.now.Add(d), false
+}
+
+func (t *manualTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	if !t.stopped {
+		t.stopped = true
+		t.clock.tickers = slices.DeleteFunc(t.clock.tickers, func(o *manualTicker) bool { return o == t })
+	}
+}
@@ -0,0 +1,175 @@
+package profiler
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// smapsInterval throttles reads of smaps_rollup, which walks every mapping
+// of the process.
+const smapsInterval = 30 * time.Second
+
+// nonGoMinRSS keeps small processes, where runtime overhead and binary
+// text dominate RSS, from being flagged for non-Go memory.
+const nonGoMinRSS = 64 << 20
+
+// containerSample is memory accounting from outside the Go runtime: procfs
+// for the process and the cgroup filesystem for its container. Zero fields
+// were unavailable.
+type containerSample struct {
+	rss      uint64
+	rssAnon  uint64
+	rssFile  uint64
+	rssShmem uint64
+	pss      uint64
+	swap     uint64
+
+	cgroupVersion      int
+	cgroupUsage        uint64
+	cgroupLimit        uint64
+	cgroupInactiveFile uint64
+	cgroupOOMEvents    uint64
+	cgroupOOMKills     uint64
+}
+
+// cgroupWorkingSet is cgroup usage minus inactive page cache, the figure
+// the kernel and kubelet act on before reclaiming.
+func (s *containerSample) cgroupWorkingSet() uint64 {
+	if s.cgroupInactiveFile >= s.cgroupUsage {
+		return 0
+	}
+	return s.cgroupUsage - s.cgroupInactiveFile
+}
+
+// goManagedBytes is the memory the Go runtime holds from the OS: everything
+// it mapped minus what it released.
+func (s *memSample) goManagedBytes() uint64 {
+	if s.runtimeTotal <= s.heapReleased {
+		return 0
+	}
+	return s.runtimeTotal - s.heapReleased
+}
+
+// nonGoBytes is RSS not explained by Go-managed memory. Go memory that is
+// mapped but not resident makes this an underestimate.
+func (s *memSample) nonGoBytes() uint64 {
+	if goBytes := s.goManagedBytes(); s.container.rss > goBytes {
+		return s.container.rss - goBytes
+	}
+	return 0
+}
+
+// containerReader reads containerSample values. It is only used from the
+// sampling goroutine.
+type containerReader struct {
+	procRoot string
+	cgroup   cgroupFS
+
+	// smaps_rollup values are reused between reads.
+	smapsAt time.Time
+	pss     uint64
+	swap    uint64
+}
+
+func newContainerReader(cgroupRoot, procRoot string) *containerReader {
+	return &containerReader{
+		procRoot: procRoot,
+		cgroup:   openCgroup(cgroupRoot, procRoot),
+	}
+}
+
+func (r *containerReader) read(now time.Time) containerSample {
+	var s containerSample
+	if r.procRoot != "" {
+		status := readProcKB(filepath.Join(r.procRoot, "self", "status"))
+		s.rss = status["VmRSS"]
+		s.rssAnon = status["RssAnon"]
+		s.rssFile = status["RssFile"]
+		s.rssShmem = status["RssShmem"]
+
+		if r.smapsAt.IsZero() || now.Sub(r.smapsAt) >= smapsInterval {
+			rollup := readProcKB(filepath.Join(r.procRoot, "self", "smaps_rollup"))
+			r.pss, r.swap = rollup["Pss"], rollup["Swap"]
+			r.smapsAt = now
+		}
+		s.pss, s.swap = r.pss, r.swap
+	}
+	r.cgroup.read(&s)
+	return s
+}
+
+// readProcKB parses "Key: <n> kB" lines as found in /proc/self/status and
+// smaps_rollup, returning bytes. Lines without a kB value are skipped.
+func readProcKB(path string) map[string]uint64 {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	out := make(map[string]uint64)
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(v)
+		if len(fields) != 2 || fields[1] != "kB" {
+			continue
+		}
+		if n, err := strconv.ParseUint(fields[0], 10, 64); err == nil {
+			out[k] = n * 1024
+		}
+	}
+	return out
+}
+
+// nonGoSuggestionsLocked flags RSS dominated by memory outside the Go
+// runtime. It is critical when non-Go memory alone exceeds half the cgroup
+// limit. Caller must hold p.mu.
+func (p *Profiler) nonGoSuggestionsLocked(ms *memSample, now time.Time) []OptimizationSuggestion {
+	threshold := p.cfg.NonGoMemoryThresholdPercent
+	c := &ms.container
+	nonGo := ms.nonGoBytes()
+	if threshold <= 0 || c.rss < nonGoMinRSS {
+		return nil
+	}
+	pct := float64(nonGo) / float64(c.rss) * 100
+	if pct < threshold {
+		return nil
+	}
+
+	severity := "warning"
+	if c.cgroupLimit > 0 && nonGo > c.cgroupLimit/2 {
+		severity = "critical"
+	}
+
+	b := strings.Builder{}
+	b.WriteString("Memory outside the Go runtime is ~")
+	b.WriteString(formatBytes(float64(nonGo)))
+	b.WriteString(" (")
+	b.WriteString(formatFloat(pct, 1))
+	b.WriteString("% of RSS ")
+	b.WriteString(formatBytes(float64(c.rss)))
+	b.WriteString("; Go holds ")
+	b.WriteString(formatBytes(float64(ms.goManagedBytes())))
+	b.WriteString(").")
+	if c.rssFile+c.rssShmem > c.rssAnon {
+		b.WriteString(" Most resident memory is file-backed or shared; look for large mmapped files and unmap or madvise regions no longer needed.")
+	} else {
+		b.WriteString(" Most resident memory is anonymous; look for cgo allocations (C libraries, malloc arenas) that are not freed.")
+	}
+	b.WriteString(" GOMEMLIMIT does not cover this memory, so leave room for it below the container limit.")
+
+	return []OptimizationSuggestion{{
+		ID:        nextID("suggestion"),
+		Kind:      SuggestionNonGoMemory,
+		Severity:  severity,
+		Message:   b.String(),
+		CreatedAt: now,
+	}}
+}
@@ -0,0 +1,223 @@
+package profiler
+
+import (
+	"reflect"
+	"sync"
+	"unsafe"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+)
+
+// typeLayout caches per-type facts needed by the deep walk so repeated calls
+// for the same type skip re-inspecting struct fields.
+type typeLayout struct {
+	size uint64
+	// refs reports whether a value of this type can reference memory outside
+	// its own inline representation (pointers, slices, maps, strings, ...).
+	refs bool
+	// fields lists struct field indices whose types have refs.
+	fields []int
+}
+
+var layoutCache sync.Map // reflect.Type -> *typeLayout
+
+func layoutOf(typ reflect.Type) *typeLayout {
+	if v, ok := layoutCache.Load(typ); ok {
+		return v.(*typeLayout)
+	}
+
+	l := &typeLayout{size: uint64(typ.Size())}
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.String,
+		reflect.Interface, reflect.Chan:
+		l.refs = true
+	case reflect.Array:
+		l.refs = typ.Len() > 0 && layoutOf(typ.Elem()).refs
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			if layoutOf(typ.Field(i).Type).refs {
+				l.fields = append(l.fields, i)
+			}
+		}
+		l.refs = len(l.fields) > 0
+	}
+
+	v, _ := layoutCache.LoadOrStore(typ, l)
+	return v.(*typeLayout)
+}
+
+// deepSizer holds the per-call budget for deep size estimation.
+type deepSizer struct {
+	enabled  bool
+	maxNodes int
+	maxBytes uint64
+}
+
+// visitKey identifies a referenced block. The type is part of the key since
+// a struct and its first field share an address.
+type visitKey struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// sizeWalk is the state of a single deep size estimation. stack holds the
+// values whose referenced memory is still to be walked; the walk is
+// iterative so long linked structures cannot exhaust the caller's stack.
+type sizeWalk struct {
+	budget deepSizer
+	nodes  int
+	total  uint64
+	seen   map[visitKey]struct{}
+	stack  []reflect.Value
+}
+
+// deepSize estimates the memory reachable from obj by following pointers,
+// slices, maps, strings and interfaces. Shared references and cycles are
+// counted once. Slices and channels are sized by capacity. Like
+// estimateSize, the header of a root reference (pointer word, slice header)
+// is not counted. The walk stops early once the node or byte budget is spent
+// and returns what it has seen so far.
+func (d deepSizer) deepSize(obj any, typ reflect.Type) uint64 {
+	w := sizeWalk{budget: d}
+	v := reflect.ValueOf(obj)
+
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.String, reflect.Chan:
+	default:
+		w.total = uint64(typ.Size())
+	}
+	w.walk(v)
+	return w.total
+}
+
+func (w *sizeWalk) maxNodes() int {
+	if m := w.budget.maxNodes; m > 0 && m < config.MaxDeepSizeNodes {
+		return m
+	}
+	return config.MaxDeepSizeNodes
+}
+
+func (w *sizeWalk) exhausted() bool {
+	if w.nodes >= w.maxNodes() {
+		return true
+	}
+	return w.budget.maxBytes > 0 && w.total >= w.budget.maxBytes
+}
+
+// visit records a referenced block and reports whether it is new.
+func (w *sizeWalk) visit(addr uintptr, typ reflect.Type) bool {
+	k := visitKey{addr: addr, typ: typ}
+	if w.seen == nil {
+		w.seen = make(map[visitKey]struct{})
+	} else if _, ok := w.seen[k]; ok {
+		return false
+	}
+	w.seen[k] = struct{}{}
+	return true
+}
+
+// push queues v to be walked. Values without references need no walk. It
+// reports false once the queued values would exceed the node budget, so the
+// stack never outgrows it.
+func (w *sizeWalk) push(v reflect.Value) bool {
+	if !layoutOf(v.Type()).refs {
+		return true
+	}
+	if w.nodes+len(w.stack) >= w.maxNodes() {
+		return false
+	}
+	w.stack = append(w.stack, v)
+	return true
+}
+
+// walk adds the size of memory referenced by root, excluding root's own
+// inline representation which the caller has already counted.
+func (w *sizeWalk) walk(root reflect.Value) {
+	w.push(root)
+	for len(w.stack) > 0 && !w.exhausted() {
+		v := w.stack[len(w.stack)-1]
+		w.stack = w.stack[:len(w.stack)-1]
+		w.nodes++
+		w.step(v)
+	}
+}
+
+// step counts the memory v references directly and queues the values in it
+// that may reference more.
+func (w *sizeWalk) step(v reflect.Value) {
+	typ := v.Type()
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || !w.visit(v.Pointer(), typ) {
+			return
+		}
+		elem := v.Elem()
+		w.total += layoutOf(elem.Type()).size
+		w.push(elem)
+
+	case reflect.Slice:
+		if v.Cap() == 0 || !w.visit(v.Pointer(), typ) {
+			return
+		}
+		elem := layoutOf(typ.Elem())
+		w.total += uint64(v.Cap()) * elem.size
+		if elem.refs {
+			for i := 0; i < v.Len() && w.push(v.Index(i)); i++ {
+			}
+		}
+
+	case reflect.String:
+		if v.Len() == 0 {
+			return
+		}
+		s := v.String()
+		if w.visit(uintptr(unsafe.Pointer(unsafe.StringData(s))), typ) {
+			w.total += uint64(len(s))
+		}
+
+	case reflect.Map:
+		if v.IsNil() || !w.visit(v.Pointer(), typ) {
+			return
+		}
+		key := layoutOf(typ.Key())
+		val := layoutOf(typ.Elem())
+		w.total += uint64(v.Len()) * (key.size + val.size)
+		if key.refs || val.refs {
+			it := v.MapRange()
+			for it.Next() && w.push(it.Key()) && w.push(it.Value()) {
+			}
+		}
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		elem := v.Elem()
+		switch elem.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+			// Stored directly in the interface word.
+		default:
+			// Boxed copy of a non-pointer value.
+			w.total += layoutOf(elem.Type()).size
+		}
+		w.push(elem)
+
+	case reflect.Struct:
+		for _, i := range layoutOf(typ).fields {
+			if !w.push(v.Field(i)) {
+				return
+			}
+		}
+
+	case reflect.Array:
+		for i := 0; i < v.Len() && w.push(v.Index(i)); i++ {
+		}
+
+	case reflect.Chan:
+		if v.IsNil() || !w.visit(v.Pointer(), typ) {
+			return
+		}
+		w.total += uint64(v.Cap()) * layoutOf(typ.Elem()).size
+	}
+}
@@ -0,0 +1,377 @@
+package profiler
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DiffQuery selects the two sides of a Diff. Each side is the latest raw
+// snapshot at or before its time or, with Window > 0, the snapshots in
+// (t-Window, t] averaged together. A side older than the raw ring uses the
+// finest rollup tier instead: the bucket holding its time, or the buckets
+// overlapping its window. A zero To means now.
+type DiffQuery struct {
+	From   time.Time
+	To     time.Time
+	Window time.Duration
+	// Limit caps each ranking (default 10).
+	Limit int
+}
+
+// FieldDelta is the change of one numeric snapshot field.
+type FieldDelta struct {
+	From    float64 `json:"from"`
+	To      float64 `json:"to"`
+	Delta   float64 `json:"delta"`
+	Percent float64 `json:"percent"`
+}
+
+// SeriesKey identifies a (type, tag) series.
+type SeriesKey struct {
+	TypeName string `json:"type_name"`
+	Tag      string `json:"tag"`
+}
+
+// SeriesChange is the change of one series between the two sides. Percent is
+// relative to From and 0 when From is 0.
+type SeriesChange struct {
+	SeriesKey
+	From    float64 `json:"from"`
+	To      float64 `json:"to"`
+	Delta   float64 `json:"delta"`
+	Percent float64 `json:"percent"`
+}
+
+// DiffRanking orders series changes by absolute and relative change.
+// Relative rankings only include series present on both sides.
+type DiffRanking struct {
+	Growers           []SeriesChange `json:"growers"`
+	Shrinkers         []SeriesChange `json:"shrinkers"`
+	RelativeGrowers   []SeriesChange `json:"relative_growers"`
+	RelativeShrinkers []SeriesChange `json:"relative_shrinkers"`
+}
+
+// SnapshotDiff describes what changed between two points in history.
+// Allocations compare BytesPerSec and retentions RetainedBytes. A historical
+// side knows the series in its snapshots' top lists (10 each); when To is
+// the latest sample, that side covers every live series instead. A series
+// missing from a truncated top list is unknown rather than 0, so it is left
+// out of Appeared, Disappeared and the rankings.
+//
+// FromTier and ToTier name the history tier each side came from. Rollups
+// keep no series, so when either side is a rollup SeriesUnavailable is set
+// and only Fields are filled.
+type SnapshotDiff struct {
+	From              time.Time             `json:"from"`
+	To                time.Time             `json:"to"`
+	FromTier          string                `json:"from_tier"`
+	ToTier            string                `json:"to_tier"`
+	FromSamples       int                   `json:"from_samples"`
+	ToSamples         int                   `json:"to_samples"`
+	Fields            map[string]FieldDelta `json:"fields"`
+	SeriesUnavailable bool                  `json:"series_unavailable,omitempty"`
+	Appeared          []SeriesKey           `json:"appeared"`
+	Disappeared       []SeriesKey           `json:"disappeared"`
+	Allocations       DiffRanking           `json:"allocations"`
+	Retentions        DiffRanking           `json:"retentions"`
+}
+
+// diffSide is one side of a diff, averaged over its snapshots. complete
+// means allocs and retains hold every series that existed, so a missing
+// series was absent; otherwise they hold top lists only. Rollup sides hold
+// no series.
+type diffSide struct {
+	at       time.Time
+	tier     string
+	samples  int
+	fields   []float64 // indexed like snapshotFields
+	allocs   map[SeriesKey]float64
+	retains  map[SeriesKey]float64
+	complete bool
+}
+
+// Diff compares two points (or windows) of snapshot history.
+func (p *Profiler) Diff(q DiffQuery) (SnapshotDiff, error) {
+	if q.To.IsZero() {
+		q.To = p.clock.Now().UTC()
+	}
+	if q.From.IsZero() || q.To.Before(q.From) {
+		return SnapshotDiff{}, fmt.Errorf("%w: from must be set and not after to", ErrInvalidQuery)
+	}
+	if q.Window < 0 {
+		return SnapshotDiff{}, fmt.Errorf("%w: negative window", ErrInvalidQuery)
+	}
+	if q.Limit <= 0 {
+		q.Limit = 10
+	}
+
+	from, err := p.diffSide(q.From, q.Window)
+	if err != nil {
+		return SnapshotDiff{}, err
+	}
+	to, err := p.diffSide(q.To, q.Window)
+	if err != nil {
+		return SnapshotDiff{}, err
+	}
+
+	d := SnapshotDiff{
+		From:        from.at,
+		To:          to.at,
+		FromTier:    from.tier,
+		ToTier:      to.tier,
+		FromSamples: from.samples,
+		ToSamples:   to.samples,
+		Fields:      make(map[string]FieldDelta, len(snapshotFields)),
+	}
+	for i, f := range snapshotFields {
+		d.Fields[f.name] = FieldDelta{
+			From:    from.fields[i],
+			To:      to.fields[i],
+			Delta:   to.fields[i] - from.fields[i],
+			Percent: percentChange(from.fields[i], to.fields[i]),
+		}
+	}
+	if from.tier != TierRaw || to.tier != TierRaw {
+		d.SeriesUnavailable = true
+		return d, nil
+	}
+
+	born := p.liveDiffSide(&to, from.at, q.Window)
+	seen := func(side *diffSide, k SeriesKey) bool {
+		_, a := side.allocs[k]
+		_, r := side.retains[k]
+		return a || r
+	}
+	// A series is known to be absent from a complete side, or from the
+	// From side when live tracking first saw it afterwards.
+	absent := func(side *diffSide, k SeriesKey) bool {
+		return side.complete || (side == &from && born[k])
+	}
+	appeared := make(map[SeriesKey]bool)
+	for _, k := range seriesUnion(to.allocs, to.retains) {
+		if !seen(&from, k) && absent(&from, k) {
+			d.Appeared = append(d.Appeared, k)
+			appeared[k] = true
+		}
+	}
+	for _, k := range seriesUnion(from.allocs, from.retains) {
+		if !seen(&to, k) && absent(&to, k) {
+			d.Disappeared = append(d.Disappeared, k)
+		}
+	}
+
+	d.Allocations = rankChanges(from.allocs, to.allocs, from.complete, to.complete, appeared, q.Limit)
+	d.Retentions = rankChanges(from.retains, to.retains, from.complete, to.complete, appeared, q.Limit)
+	return d, nil
+}
+
+func (p *Profiler) diffSide(at time.Time, window time.Duration) (diffSide, error) {
+	var snaps []ProfilerSnapshot
+	if window > 0 {
+		snaps = p.SnapshotsRange(at.Add(-window).Add(time.Nanosecond), at, 0)
+	} else {
+		snaps = p.SnapshotsRange(time.Time{}, at, 1)
+	}
+	if len(snaps) == 0 {
+		if side, ok := p.rollupDiffSide(at, window); ok {
+			return side, nil
+		}
+		return diffSide{}, fmt.Errorf("%w: no snapshot at or before %s", ErrInvalidQuery, at.Format(time.RFC3339))
+	}
+
+	side := diffSide{
+		at:       snaps[len(snaps)-1].Timestamp,
+		tier:     TierRaw,
+		samples:  len(snaps),
+		fields:   make([]float64, len(snapshotFields)),
+		allocs:   make(map[SeriesKey]float64),
+		retains:  make(map[SeriesKey]float64),
+		complete: true,
+	}
+	allocN := make(map[SeriesKey]int)
+	retainN := make(map[SeriesKey]int)
+	for i := range snaps {
+		s := &snaps[i]
+		for j, f := range snapshotFields {
+			side.fields[j] += f.get(s)
+		}
+		if len(s.TopAllocations) >= snapshotTopN || len(s.TopRetentions) >= snapshotTopN {
+			side.complete = false
+		}
+		for _, a := range s.TopAllocations {
+			k := SeriesKey{a.TypeName, a.Tag}
+			side.allocs[k] += a.BytesPerSec
+			allocN[k]++
+		}
+		for _, r := range s.TopRetentions {
+			k := SeriesKey{r.TypeName, r.Tag}
+			side.retains[k] += float64(r.RetainedBytes)
+			retainN[k]++
+		}
+	}
+
+	n := float64(len(snaps))
+	for j := range side.fields {
+		side.fields[j] /= n
+	}
+	// Series average over the snapshots that recorded them.
+	for k, c := range allocN {
+		side.allocs[k] /= float64(c)
+	}
+	for k, c := range retainN {
+		side.retains[k] /= float64(c)
+	}
+	return side, nil
+}
+
+// rollupDiffSide builds a side from the finest rollup tier that has a bucket
+// at or before at, for times the raw ring no longer holds. Fields are the
+// bucket averages weighted by their sample counts.
+func (p *Profiler) rollupDiffSide(at time.Time, window time.Duration) (diffSide, bool) {
+	var from time.Time
+	if window > 0 {
+		from = at.Add(-window).Add(time.Nanosecond)
+	}
+
+	p.rlockMu()
+	defer p.mu.RUnlock()
+
+	for _, t := range p.tiers {
+		pts := t.rollups(from, at, 0)
+		if len(pts) == 0 {
+			continue
+		}
+		if window <= 0 {
+			pts = pts[len(pts)-1:]
+		}
+
+		side := diffSide{
+			at:     pts[len(pts)-1].Start,
+			tier:   t.name,
+			fields: make([]float64, len(snapshotFields)),
+		}
+		for _, pt := range pts {
+			for j, f := range snapshotFields {
+				side.fields[j] += pt.Fields[f.name].Avg * float64(pt.Samples)
+			}
+			side.samples += pt.Samples
+		}
+		for j := range side.fields {
+			side.fields[j] /= float64(side.samples)
+		}
+		return side, true
+	}
+	return diffSide{}, false
+}
+
+// liveDiffSide replaces the series of side with every series the profiler
+// tracks when side ends at the latest sample, which is when the live maps
+// were last merged. Allocation rates are taken over window (the snapshot
+// rate window by default). The side is complete when live tracking started
+// by since; the returned set holds the series first tracked after since.
+func (p *Profiler) liveDiffSide(side *diffSide, since time.Time, window time.Duration) map[SeriesKey]bool {
+	p.rlockMu()
+	defer p.mu.RUnlock()
+
+	if p.sketch != nil || p.histCount == 0 {
+		return nil
+	}
+	latest := p.history[(p.histStart+p.histCount-1)%len(p.history)].Timestamp
+	if !side.at.Equal(latest) {
+		return nil
+	}
+	if window <= 0 {
+		window = DefaultRateWindow
+	}
+
+	side.allocs = make(map[SeriesKey]float64, len(p.allocs))
+	side.retains = make(map[SeriesKey]float64, len(p.retentions))
+	side.complete = !p.liveSince.After(since)
+	born := make(map[SeriesKey]bool)
+	for _, a := range p.topAllocationsLocked(0, window, SortByBytes, side.at) {
+		k := SeriesKey{a.TypeName, a.Tag}
+		side.allocs[k] = a.BytesPerSec
+		if a.firstSeen.After(since) {
+			born[k] = true
+		}
+	}
+	for _, r := range p.retentions {
+		side.retains[SeriesKey{r.TypeName, r.Tag}] = float64(r.RetainedBytes)
+	}
+	return born
+}
+
+// seriesUnion returns the keys of a and b in a stable order.
+func seriesUnion(a, b map[SeriesKey]float64) []SeriesKey {
+	set := make(map[SeriesKey]struct{}, len(a)+len(b))
+	for k := range a {
+		set[k] = struct{}{}
+	}
+	for k := range b {
+		set[k] = struct{}{}
+	}
+	keys := make([]SeriesKey, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].TypeName != keys[j].TypeName {
+			return keys[i].TypeName < keys[j].TypeName
+		}
+		return keys[i].Tag < keys[j].Tag
+	})
+	return keys
+}
+
+// rankChanges ranks series by change from -> to. A series missing on one
+// side counts as 0 there if that side is complete or, for from, the series
+// appeared after it; otherwise its change is unknown and it is skipped.
+func rankChanges(from, to map[SeriesKey]float64, fromComplete, toComplete bool, appeared map[SeriesKey]bool, limit int) DiffRanking {
+	var all, both []SeriesChange
+	for _, k := range seriesUnion(from, to) {
+		f, inFrom := from[k]
+		t, inTo := to[k]
+		if (!inFrom && !fromComplete && !appeared[k]) || (!inTo && !toComplete) {
+			continue
+		}
+		c := SeriesChange{SeriesKey: k, From: f, To: t, Delta: t - f, Percent: percentChange(f, t)}
+		if c.Delta == 0 {
+			continue
+		}
+		all = append(all, c)
+		if inFrom && inTo && f != 0 {
+			both = append(both, c)
+		}
+	}
+
+	pick := func(src []SeriesChange, less func(a, b *SeriesChange) bool, keep func(c *SeriesChange) bool) []SeriesChange {
+		out := make([]SeriesChange, 0, len(src))
+		for i := range src {
+			if keep(&src[i]) {
+				out = append(out, src[i])
+			}
+		}
+		sort.SliceStable(out, func(i, j int) bool { return less(&out[i], &out[j]) })
+		if len(out) > limit {
+			out = out[:limit]
+		}
+		return out
+	}
+	grew := func(c *SeriesChange) bool { return c.Delta > 0 }
+	shrank := func(c *SeriesChange) bool { return c.Delta < 0 }
+
+	return DiffRanking{
+		Growers:           pick(all, func(a, b *SeriesChange) bool { return a.Delta > b.Delta }, grew),
+		Shrinkers:         pick(all, func(a, b *SeriesChange) bool { return a.Delta < b.Delta }, shrank),
+		RelativeGrowers:   pick(both, func(a, b *SeriesChange) bool { return a.Percent > b.Percent }, grew),
+		RelativeShrinkers: pick(both, func(a, b *SeriesChange) bool { return a.Percent < b.Percent }, shrank),
+	}
+}
+
+func percentChange(from, to float64) float64 {
+	if from == 0 {
+		return 0
+	}
+	return (to - from) / from * 100
+}
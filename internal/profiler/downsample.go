@@ -0,0 +1,281 @@
+package profiler
+
+import (
+	"math"
+	"time"
+)
+
+// History tiers. The raw tier is the snapshot ring; the others hold rollups
+// built from every snapshot as it is appended.
+const (
+	TierRaw    = "raw"
+	TierMinute = "1m"
+	TierHour   = "1h"
+)
+
+// rollupTiers lists the downsampled tiers from finest to coarsest.
+var rollupTiers = []struct {
+	name      string
+	step      time.Duration
+	retention time.Duration
+}{
+	{TierMinute, time.Minute, 24 * time.Hour},
+	{TierHour, time.Hour, 30 * 24 * time.Hour},
+}
+
+// snapshotFields are the numeric snapshot fields kept in rollups, by JSON
+// name.
+var snapshotFields = []struct {
+	name string
+	get  func(*ProfilerSnapshot) float64
+}{
+	{"heap_alloc_bytes", func(s *ProfilerSnapshot) float64 { return float64(s.HeapAllocBytes) }},
+	{"heap_inuse_bytes", func(s *ProfilerSnapshot) float64 { return float64(s.HeapInuseBytes) }},
+	{"heap_idle_bytes", func(s *ProfilerSnapshot) float64 { return float64(s.HeapIdleBytes) }},
+	{"heap_released_bytes", func(s *ProfilerSnapshot) float64 { return float64(s.HeapReleased) }},
+	{"num_gc", func(s *ProfilerSnapshot) float64 { return float64(s.NumGC) }},
+	{"next_gc_bytes", func(s *ProfilerSnapshot) float64 { return float64(s.NextGCBytes) }},
+	{"total_alloc_bytes", func(s *ProfilerSnapshot) float64 { return float64(s.TotalAllocBytes) }},
+	{"heap_live_bytes", func(s *ProfilerSnapshot) float64 { return float64(s.HeapLiveBytes) }},
+	{"heap_objects", func(s *ProfilerSnapshot) float64 { return float64(s.HeapObjects) }},
+	{"stack_bytes", func(s *ProfilerSnapshot) float64 { return float64(s.StackBytes) }},
+	{"mspan_inuse_bytes", func(s *ProfilerSnapshot) float64 { return float64(s.MSpanInuseBytes) }},
+	{"mcache_inuse_bytes", func(s *ProfilerSnapshot) float64 { return float64(s.MCacheInuseBytes) }},
+	{"runtime_total_bytes", func(s *ProfilerSnapshot) float64 { return float64(s.RuntimeTotalBytes) }},
+	{"goroutines", func(s *ProfilerSnapshot) float64 { return float64(s.Goroutines) }},
+	{"gogc_percent", func(s *ProfilerSnapshot) float64 { return float64(s.GOGCPercent) }},
+	{"gomemlimit_bytes", func(s *ProfilerSnapshot) float64 { return float64(s.GOMemLimitBytes) }},
+	{"gc_cpu_seconds", func(s *ProfilerSnapshot) float64 { return s.GCCPUSeconds }},
+	{"gc_cpu_fraction", func(s *ProfilerSnapshot) float64 { return s.GCCPUFraction }},
+	{"gc_pause_p99_seconds", func(s *ProfilerSnapshot) float64 { return s.GCPauses.P99Seconds }},
+	{"sched_latency_p99_seconds", func(s *ProfilerSnapshot) float64 { return s.SchedLatency.P99Seconds }},
+	{"idle_not_released_bytes", func(s *ProfilerSnapshot) float64 { return float64(s.Fragmentation.IdleNotReleasedBytes) }},
+	{"small_allocs_per_sec", func(s *ProfilerSnapshot) float64 { return s.Fragmentation.SmallAllocsPerSec }},
+	{"rss_bytes", func(s *ProfilerSnapshot) float64 { return float64(s.RSSBytes) }},
+	{"non_go_bytes", func(s *ProfilerSnapshot) float64 { return float64(s.NonGoBytes) }},
+	{"cgroup_usage_bytes", func(s *ProfilerSnapshot) float64 { return float64(s.CgroupUsageBytes) }},
+	{"cgroup_working_set_bytes", func(s *ProfilerSnapshot) float64 { return float64(s.CgroupWorkingSetBytes) }},
+	{"cgroup_limit_bytes", func(s *ProfilerSnapshot) float64 { return float64(s.CgroupLimitBytes) }},
+	{"sampling_interval_ms", func(s *ProfilerSnapshot) float64 { return float64(s.SamplingIntervalMs) }},
+}
+
+// Rollup aggregates one field over a rollup step.
+type Rollup struct {
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Avg  float64 `json:"avg"`
+	Last float64 `json:"last"`
+}
+
+// RollupPoint summarizes the snapshots taken in [Start, Start+step). Fields
+// is keyed by the snapshot's JSON field names.
+type RollupPoint struct {
+	Start   time.Time         `json:"start"`
+	Samples int               `json:"samples"`
+	Fields  map[string]Rollup `json:"fields"`
+}
+
+// HistoryResult is returned by History. Exactly one of Snapshots (raw tier)
+// or Rollups is set.
+type HistoryResult struct {
+	Tier      string             `json:"tier"`
+	Step      string             `json:"step"`
+	Snapshots []ProfilerSnapshot `json:"snapshots,omitempty"`
+	Rollups   []RollupPoint      `json:"rollups,omitempty"`
+}
+
+// rollupAcc accumulates per-field aggregates, indexed like snapshotFields.
+type rollupAcc struct {
+	start time.Time
+	n     int
+	min   []float64
+	max   []float64
+	sum   []float64
+	last  []float64
+}
+
+func newRollupAcc(start time.Time) rollupAcc {
+	n := len(snapshotFields)
+	a := rollupAcc{
+		start: start,
+		min:   make([]float64, n),
+		max:   make([]float64, n),
+		sum:   make([]float64, n),
+		last:  make([]float64, n),
+	}
+	for i := range a.min {
+		a.min[i] = math.Inf(1)
+		a.max[i] = math.Inf(-1)
+	}
+	return a
+}
+
+func (a *rollupAcc) observe(snap *ProfilerSnapshot) {
+	for i, f := range snapshotFields {
+		v := f.get(snap)
+		a.min[i] = min(a.min[i], v)
+		a.max[i] = max(a.max[i], v)
+		a.sum[i] += v
+		a.last[i] = v
+	}
+	a.n++
+}
+
+func (a *rollupAcc) point() RollupPoint {
+	pt := RollupPoint{
+		Start:   a.start,
+		Samples: a.n,
+		Fields:  make(map[string]Rollup, len(snapshotFields)),
+	}
+	for i, f := range snapshotFields {
+		pt.Fields[f.name] = Rollup{
+			Min:  a.min[i],
+			Max:  a.max[i],
+			Avg:  a.sum[i] / float64(a.n),
+			Last: a.last[i],
+		}
+	}
+	return pt
+}
+
+// rollupTier holds closed rollups for one step plus the bucket still being
+// filled.
+type rollupTier struct {
+	name      string
+	step      time.Duration
+	retention time.Duration
+	points    []rollupAcc
+	cur       rollupAcc
+}
+
+func newRollupTiers() []*rollupTier {
+	tiers := make([]*rollupTier, 0, len(rollupTiers))
+	for _, t := range rollupTiers {
+		tiers = append(tiers, &rollupTier{name: t.name, step: t.step, retention: t.retention})
+	}
+	return tiers
+}
+
+func (t *rollupTier) add(snap *ProfilerSnapshot) {
+	start := snap.Timestamp.Truncate(t.step)
+	if t.cur.n > 0 && !t.cur.start.Equal(start) {
+		t.points = append(t.points, t.cur)
+		t.cur = rollupAcc{}
+	}
+	if t.cur.n == 0 {
+		t.cur = newRollupAcc(start)
+	}
+	t.cur.observe(snap)
+
+	cutoff := snap.Timestamp.Add(-t.retention)
+	drop := 0
+	for drop < len(t.points) && !t.points[drop].start.Add(t.step).After(cutoff) {
+		drop++
+	}
+	if drop > 0 {
+		t.points = append(t.points[:0], t.points[drop:]...)
+	}
+}
+
+// rollups returns points starting in [from, to], including the open bucket.
+// Zero bounds are open; limit > 0 keeps the most recent points.
+func (t *rollupTier) rollups(from, to time.Time, limit int) []RollupPoint {
+	var out []RollupPoint
+	emit := func(a *rollupAcc) {
+		if (!from.IsZero() && a.start.Add(t.step).Before(from)) || (!to.IsZero() && a.start.After(to)) {
+			return
+		}
+		out = append(out, a.point())
+	}
+	for i := range t.points {
+		emit(&t.points[i])
+	}
+	if t.cur.n > 0 {
+		emit(&t.cur)
+	}
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out
+}
+
+// rawStepLocked and rawRetentionLocked describe the snapshot ring as a tier.
+// The step is the mean spacing of the snapshots in the ring, so it follows
+// adaptive and GC-triggered sampling; with fewer than two snapshots it is
+// the sampling interval in effect. The retention is what the full ring holds
+// at that step, capped by RetentionWindowSec.
+func (p *Profiler) rawStepLocked() time.Duration {
+	if p.histCount >= 2 {
+		oldest := p.history[p.histStart].Timestamp
+		newest := p.history[(p.histStart+p.histCount-1)%len(p.history)].Timestamp
+		if span := newest.Sub(oldest); span > 0 {
+			return (span / time.Duration(p.histCount-1)).Round(time.Millisecond)
+		}
+	}
+	return p.SamplingInterval()
+}
+
+func (p *Profiler) rawRetentionLocked() time.Duration {
+	ret := time.Duration(p.cfg.RetentionWindowSec) * time.Second
+	if ring := time.Duration(len(p.history)) * p.rawStepLocked(); ret <= 0 || ring < ret {
+		ret = ring
+	}
+	return ret
+}
+
+// History returns snapshot history for [from, to] from the tier that fits
+// best. Without step, the finest tier still covering from is used (the raw
+// ring when from is zero). With step, the coarsest tier whose step does not
+// exceed it and that covers from is used, falling back to the finest tier
+// covering from. Zero bounds are open; limit > 0 keeps the most recent
+// entries.
+func (p *Profiler) History(from, to time.Time, step time.Duration, limit int) HistoryResult {
+	p.rlockMu()
+	rawStep := p.rawStepLocked()
+	rawRetention := p.rawRetentionLocked()
+	p.mu.RUnlock()
+
+	type tier struct {
+		name      string
+		step      time.Duration
+		retention time.Duration
+	}
+	tiers := []tier{{TierRaw, rawStep, rawRetention}}
+	for _, t := range rollupTiers {
+		tiers = append(tiers, tier{t.name, t.step, t.retention})
+	}
+
+	now := p.clock.Now().UTC()
+	covers := func(t tier) bool {
+		return from.IsZero() || !from.Before(now.Add(-t.retention))
+	}
+
+	chosen := -1
+	if step > 0 {
+		for i, t := range tiers {
+			if t.step <= step && (covers(t) || chosen < 0) {
+				chosen = i
+			}
+		}
+	}
+	if chosen < 0 || !covers(tiers[chosen]) {
+		chosen = len(tiers) - 1
+		for i, t := range tiers {
+			if covers(t) {
+				chosen = i
+				break
+			}
+		}
+	}
+
+	res := HistoryResult{Tier: tiers[chosen].name, Step: tiers[chosen].step.String()}
+	if chosen == 0 {
+		res.Snapshots = p.SnapshotsRange(from, to, limit)
+		return res
+	}
+
+	p.rlockMu()
+	defer p.mu.RUnlock()
+	res.Rollups = p.tiers[chosen-1].rollups(from, to, limit)
+	return res
+}
@@ -0,0 +1,698 @@
+package profiler
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	segmentPrefix = "seg-"
+	segmentSuffix = ".log"
+	indexFile     = "index.json"
+	stateFile     = "state.json"
+
+	// recordHeader is a little-endian uint32 payload length, the CRC-32
+	// (IEEE) of sequence number and payload, and the uint64 record sequence
+	// number.
+	recordHeader = 16
+
+	// maxRecordBytes guards against reading a garbage length after a torn
+	// write.
+	maxRecordBytes = 64 << 20
+
+	// compactStep is the resolution compacted segments keep.
+	compactStep = time.Minute
+
+	defaultSegmentBytes     = 8 << 20
+	defaultMaintainInterval = time.Minute
+)
+
+// FileStoreOptions configures OpenFileStore. Zero values pick defaults.
+type FileStoreOptions struct {
+	// SegmentBytes is the size at which the active segment is sealed and a
+	// new one started (default 8 MiB).
+	SegmentBytes int64
+	// Retention drops sealed segments whose newest snapshot is older than
+	// this (0 keeps everything).
+	Retention time.Duration
+	// CompactAfter is the age past which sealed segments are compacted to
+	// one snapshot per minute without top allocations/retentions
+	// (0 disables compaction).
+	CompactAfter time.Duration
+	// MaintainInterval is how often Append applies retention and compaction
+	// when no segment is sealed, so a slowly filling store still ages out
+	// old data (default 1 minute). Both also run on open and on rotation.
+	MaintainInterval time.Duration
+	// SyncEvery fsyncs the active segment once every SyncEvery appends
+	// (default 1). Snapshots arrive at the sampling interval, seconds apart,
+	// so syncing each one is cheap; batching trades up to SyncEvery-1
+	// snapshots on power loss for fewer fsyncs. Records reach the OS on every
+	// append, so a process crash loses none of them either way.
+	SyncEvery int
+	// Clock supplies the time retention and compaction are measured against
+	// (default SystemClock).
+	Clock Clock
+}
+
+// segmentMeta describes one segment file in the index. First and Last bound
+// the snapshot timestamps in the segment, which are in append order and may
+// step backwards with the wall clock. LastRecord is the highest record
+// sequence number in the segment.
+type segmentMeta struct {
+	Name       string    `json:"name"`
+	Seq        uint64    `json:"seq"`
+	First      time.Time `json:"first"`
+	Last       time.Time `json:"last"`
+	Records    int       `json:"records"`
+	LastRecord uint64    `json:"last_record"`
+	Size       int64     `json:"size"`
+	Compacted  bool      `json:"compacted"`
+}
+
+// extend widens the segment's bounds to include record seq taken at ts.
+func (m *segmentMeta) extend(seq uint64, ts time.Time) {
+	if m.First.IsZero() || ts.Before(m.First) {
+		m.First = ts
+	}
+	if ts.After(m.Last) {
+		m.Last = ts
+	}
+	m.LastRecord = max(m.LastRecord, seq)
+}
+
+// storedSnapshot is a snapshot with the sequence number of its record.
+// Numbers increase with every append across the store and are kept by
+// compaction, so they identify a snapshot even when timestamps collide.
+type storedSnapshot struct {
+	seq  uint64
+	snap ProfilerSnapshot
+}
+
+// FileStore is an append-only HistoryStore on local disk. Snapshots are
+// written as length-prefixed, checksummed and sequence-numbered JSON records
+// to numbered segment files; index.json caches per-segment time bounds. Appends are fsynced per
+// FileStoreOptions.SyncEvery and a torn record at the tail of the last
+// segment is truncated on open, so a crash loses at most the snapshot being
+// written. Index and compacted segments are replaced via rename. It is also
+// a StateStore keeping ProfilerState in state.json.
+type FileStore struct {
+	mu   sync.Mutex
+	dir  string
+	opts FileStoreOptions
+
+	segments   []segmentMeta // ordered by Seq; the last one is active
+	active     *os.File
+	nextRecord uint64    // sequence number of the next appended record
+	unsynced   int       // appends since the active segment was last fsynced
+	maintained time.Time // last retention and compaction pass
+}
+
+// OpenFileStore opens (or creates) a file store in dir, validating the index
+// against the segment files, repairing the active segment's tail and
+// applying retention and compaction.
+func OpenFileStore(dir string, opts FileStoreOptions) (*FileStore, error) {
+	if strings.TrimSpace(dir) == "" {
+		return nil, errors.New("filestore: empty directory")
+	}
+	if opts.SegmentBytes <= 0 {
+		opts.SegmentBytes = defaultSegmentBytes
+	}
+	if opts.MaintainInterval <= 0 {
+		opts.MaintainInterval = defaultMaintainInterval
+	}
+	if opts.SyncEvery <= 0 {
+		opts.SyncEvery = 1
+	}
+	if opts.Clock == nil {
+		opts.Clock = SystemClock()
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("filestore: mkdir %s: %w", dir, err)
+	}
+
+	s := &FileStore{dir: dir, opts: opts}
+	if err := s.loadSegments(); err != nil {
+		return nil, err
+	}
+	if err := s.openActive(); err != nil {
+		return nil, err
+	}
+	s.nextRecord = 1
+	for _, m := range s.segments {
+		s.nextRecord = max(s.nextRecord, m.LastRecord+1)
+	}
+	if err := s.maintain(); err != nil {
+		_ = s.active.Close()
+		return nil, err
+	}
+	if err := s.writeIndex(); err != nil {
+		_ = s.active.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// loadSegments lists segment files and reconciles them with the index.
+// Entries whose file size changed since the index was written are rescanned.
+func (s *FileStore) loadSegments() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("filestore: read dir %s: %w", s.dir, err)
+	}
+
+	indexed := make(map[string]segmentMeta)
+	if b, err := os.ReadFile(filepath.Join(s.dir, indexFile)); err == nil {
+		var metas []segmentMeta
+		if json.Unmarshal(b, &metas) == nil {
+			for _, m := range metas {
+				indexed[m.Name] = m
+			}
+		}
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		var seq uint64
+		if _, err := fmt.Sscanf(strings.TrimSuffix(name, segmentSuffix), segmentPrefix+"%d", &seq); err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return fmt.Errorf("filestore: stat %s: %w", name, err)
+		}
+
+		if m, ok := indexed[name]; ok && m.Size == info.Size() {
+			s.segments = append(s.segments, m)
+			continue
+		}
+		m, err := s.scanSegment(name, seq)
+		if err != nil {
+			return err
+		}
+		s.segments = append(s.segments, m)
+	}
+
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].Seq < s.segments[j].Seq })
+	return nil
+}
+
+// scanSegment rebuilds a segment's metadata, truncating a torn tail.
+func (s *FileStore) scanSegment(name string, seq uint64) (segmentMeta, error) {
+	m := segmentMeta{Name: name, Seq: seq}
+	path := filepath.Join(s.dir, name)
+
+	recs, valid, err := readSegment(path)
+	if err != nil {
+		return m, err
+	}
+	if info, err := os.Stat(path); err == nil && info.Size() > valid {
+		if err := os.Truncate(path, valid); err != nil {
+			return m, fmt.Errorf("filestore: truncate %s: %w", name, err)
+		}
+	}
+
+	m.Size = valid
+	m.Records = len(recs)
+	for _, r := range recs {
+		m.extend(r.seq, r.snap.Timestamp)
+	}
+	return m, nil
+}
+
+// openActive opens the last segment for appending, creating one if needed.
+func (s *FileStore) openActive() error {
+	if len(s.segments) == 0 || s.segments[len(s.segments)-1].Compacted {
+		return s.startSegment()
+	}
+
+	last := &s.segments[len(s.segments)-1]
+	// Always re-validate the active segment: the index may predate appends
+	// and the tail may be torn.
+	m, err := s.scanSegment(last.Name, last.Seq)
+	if err != nil {
+		return err
+	}
+	*last = m
+
+	f, err := os.OpenFile(filepath.Join(s.dir, last.Name), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("filestore: open %s: %w", last.Name, err)
+	}
+	s.active = f
+	return nil
+}
+
+func (s *FileStore) startSegment() error {
+	var seq uint64 = 1
+	if n := len(s.segments); n > 0 {
+		seq = s.segments[n-1].Seq + 1
+	}
+	name := fmt.Sprintf("%s%016d%s", segmentPrefix, seq, segmentSuffix)
+
+	f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("filestore: create %s: %w", name, err)
+	}
+	if err := syncDir(s.dir); err != nil {
+		_ = f.Close()
+		return err
+	}
+	s.active = f
+	s.segments = append(s.segments, segmentMeta{Name: name, Seq: seq})
+	return nil
+}
+
+// Append implements HistoryStore.
+func (s *FileStore) Append(snap ProfilerSnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil {
+		return errors.New("filestore: closed")
+	}
+
+	seq := s.nextRecord
+	rec, err := encodeRecord(seq, &snap)
+	if err != nil {
+		return err
+	}
+
+	cur := &s.segments[len(s.segments)-1]
+	if cur.Size > 0 && cur.Size+int64(len(rec)) > s.opts.SegmentBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+		cur = &s.segments[len(s.segments)-1]
+	}
+
+	if _, err := s.active.Write(rec); err != nil {
+		return fmt.Errorf("filestore: write %s: %w", cur.Name, err)
+	}
+	s.nextRecord++
+	s.unsynced++
+	if s.unsynced >= s.opts.SyncEvery {
+		if err := s.syncActive(); err != nil {
+			return err
+		}
+	}
+
+	cur.Size += int64(len(rec))
+	cur.Records++
+	cur.extend(seq, snap.Timestamp)
+
+	if s.opts.Clock.Now().Sub(s.maintained) < s.opts.MaintainInterval {
+		return nil
+	}
+	if err := s.maintain(); err != nil {
+		return err
+	}
+	return s.writeIndex()
+}
+
+// syncActive fsyncs pending appends to the active segment.
+func (s *FileStore) syncActive() error {
+	if s.unsynced == 0 {
+		return nil
+	}
+	if err := s.active.Sync(); err != nil {
+		return fmt.Errorf("filestore: sync %s: %w", s.segments[len(s.segments)-1].Name, err)
+	}
+	s.unsynced = 0
+	return nil
+}
+
+// rotate seals the active segment, starts a new one and runs retention and
+// compaction over sealed segments.
+func (s *FileStore) rotate() error {
+	if err := s.syncActive(); err != nil {
+		return err
+	}
+	if err := s.active.Close(); err != nil {
+		return fmt.Errorf("filestore: close segment: %w", err)
+	}
+	s.active = nil
+	if err := s.startSegment(); err != nil {
+		return err
+	}
+	if err := s.maintain(); err != nil {
+		return err
+	}
+	return s.writeIndex()
+}
+
+// maintain applies retention, then compacts old raw segments. Crashing
+// midway leaves either the old or the new file for every step; a segment
+// merged into its predecessor but not yet removed only yields records with
+// sequence numbers already seen, which Load skips.
+func (s *FileStore) maintain() error {
+	now := s.opts.Clock.Now()
+	s.maintained = now
+	sealed := len(s.segments) - 1
+
+	if s.opts.Retention > 0 {
+		cutoff := now.Add(-s.opts.Retention)
+		keep := s.segments[:0]
+		for i, m := range s.segments {
+			if i < sealed && m.Last.Before(cutoff) {
+				if err := os.Remove(filepath.Join(s.dir, m.Name)); err != nil && !os.IsNotExist(err) {
+					return fmt.Errorf("filestore: remove %s: %w", m.Name, err)
+				}
+				continue
+			}
+			keep = append(keep, m)
+		}
+		s.segments = keep
+		sealed = len(s.segments) - 1
+	}
+
+	if s.opts.CompactAfter <= 0 {
+		return nil
+	}
+	cutoff := now.Add(-s.opts.CompactAfter)
+	for i := 0; i < sealed; i++ {
+		m := s.segments[i]
+		if m.Compacted || !m.Last.Before(cutoff) {
+			continue
+		}
+		merged, err := s.compact(i)
+		if err != nil {
+			return err
+		}
+		if merged {
+			s.segments = append(s.segments[:i], s.segments[i+1:]...)
+			sealed--
+			i--
+		}
+	}
+	return nil
+}
+
+// compact thins segment i to one snapshot per minute. The result is merged
+// into the preceding segment when that one is compacted and has room, and
+// reports whether segment i was removed.
+func (s *FileStore) compact(i int) (bool, error) {
+	m := s.segments[i]
+	recs, _, err := readSegment(filepath.Join(s.dir, m.Name))
+	if err != nil {
+		return false, err
+	}
+	thin := thinSnapshots(recs, compactStep)
+
+	target := i
+	var prefix []storedSnapshot
+	if i > 0 && s.segments[i-1].Compacted && s.segments[i-1].Size < s.opts.SegmentBytes {
+		prev, _, err := readSegment(filepath.Join(s.dir, s.segments[i-1].Name))
+		if err != nil {
+			return false, err
+		}
+		target = i - 1
+		prefix = prev
+	}
+
+	out := append(prefix, thin...)
+	meta, err := s.rewriteSegment(s.segments[target], out)
+	if err != nil {
+		return false, err
+	}
+	s.segments[target] = meta
+
+	if target == i {
+		return false, nil
+	}
+	if err := os.Remove(filepath.Join(s.dir, m.Name)); err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("filestore: remove %s: %w", m.Name, err)
+	}
+	return true, nil
+}
+
+// rewriteSegment atomically replaces a segment's contents, keeping each
+// record's sequence number.
+func (s *FileStore) rewriteSegment(m segmentMeta, recs []storedSnapshot) (segmentMeta, error) {
+	path := filepath.Join(s.dir, m.Name)
+	tmp := path + ".tmp"
+
+	var size int64
+	err := writeFileSync(tmp, func(w io.Writer) error {
+		for i := range recs {
+			rec, err := encodeRecord(recs[i].seq, &recs[i].snap)
+			if err != nil {
+				return err
+			}
+			n, err := w.Write(rec)
+			size += int64(n)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = os.Remove(tmp)
+		return m, fmt.Errorf("filestore: compact %s: %w", m.Name, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return m, fmt.Errorf("filestore: rename %s: %w", tmp, err)
+	}
+	if err := syncDir(s.dir); err != nil {
+		return m, err
+	}
+
+	m.Size = size
+	m.Records = len(recs)
+	m.Compacted = true
+	m.First, m.Last, m.LastRecord = time.Time{}, time.Time{}, 0
+	for _, r := range recs {
+		m.extend(r.seq, r.snap.Timestamp)
+	}
+	return m, nil
+}
+
+// writeIndex atomically replaces index.json.
+func (s *FileStore) writeIndex() error {
+	b, err := json.Marshal(s.segments)
+	if err != nil {
+		return fmt.Errorf("filestore: encode index: %w", err)
+	}
+	return s.replaceFile(indexFile, "index", b)
+}
+
+// replaceFile atomically replaces name in the store directory with b; what
+// names the file in errors.
+func (s *FileStore) replaceFile(name, what string, b []byte) error {
+	path := filepath.Join(s.dir, name)
+	tmp := path + ".tmp"
+	if err := writeFileSync(tmp, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	}); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("filestore: write %s: %w", what, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("filestore: rename %s: %w", what, err)
+	}
+	return syncDir(s.dir)
+}
+
+// SaveState implements StateStore by atomically replacing state.json.
+func (s *FileStore) SaveState(state ProfilerState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("filestore: encode state: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.replaceFile(stateFile, "state", b)
+}
+
+// LoadState implements StateStore.
+func (s *FileStore) LoadState() (ProfilerState, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := os.ReadFile(filepath.Join(s.dir, stateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return ProfilerState{}, false, nil
+	}
+	if err != nil {
+		return ProfilerState{}, false, fmt.Errorf("filestore: read state: %w", err)
+	}
+	var state ProfilerState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return ProfilerState{}, false, fmt.Errorf("filestore: decode state: %w", err)
+	}
+	return state, true, nil
+}
+
+// Load implements HistoryStore. Snapshots come back in append order, even
+// across a backwards clock step. Segments entirely older than since are
+// skipped via the index; a record whose sequence number was already loaded
+// is a copy left by an interrupted compaction and is dropped. Distinct
+// snapshots sharing a timestamp are all kept.
+func (s *FileStore) Load(since time.Time) ([]ProfilerSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []ProfilerSnapshot
+	seen := make(map[uint64]struct{})
+	for _, m := range s.segments {
+		if m.Records == 0 || m.Last.Before(since) {
+			continue
+		}
+		recs, _, err := readSegment(filepath.Join(s.dir, m.Name))
+		if err != nil {
+			return out, err
+		}
+		for _, r := range recs {
+			if r.snap.Timestamp.Before(since) {
+				continue
+			}
+			if _, dup := seen[r.seq]; dup {
+				continue
+			}
+			seen[r.seq] = struct{}{}
+			out = append(out, r.snap)
+		}
+	}
+	return out, nil
+}
+
+// Close implements HistoryStore.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil {
+		return nil
+	}
+	err := s.syncActive()
+	if cerr := s.active.Close(); err == nil {
+		err = cerr
+	}
+	s.active = nil
+	if ierr := s.writeIndex(); err == nil {
+		err = ierr
+	}
+	return err
+}
+
+func encodeRecord(seq uint64, snap *ProfilerSnapshot) ([]byte, error) {
+	payload, err := json.Marshal(snap)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: encode snapshot: %w", err)
+	}
+	rec := make([]byte, recordHeader+len(payload))
+	binary.LittleEndian.PutUint32(rec[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint64(rec[8:16], seq)
+	copy(rec[recordHeader:], payload)
+	binary.LittleEndian.PutUint32(rec[4:8], recordChecksum(rec[8:16], payload))
+	return rec, nil
+}
+
+// recordChecksum covers the sequence number as well as the payload, so a
+// damaged number is not mistaken for a distinct record.
+func recordChecksum(seq, payload []byte) uint32 {
+	return crc32.Update(crc32.ChecksumIEEE(seq), crc32.IEEETable, payload)
+}
+
+// readSegment decodes records until EOF or the first damaged record, and
+// returns them plus the length of the intact prefix.
+func readSegment(path string) ([]storedSnapshot, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("filestore: open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var (
+		recs  []storedSnapshot
+		valid int64
+		hdr   [recordHeader]byte
+	)
+	for {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			break
+		}
+		n := binary.LittleEndian.Uint32(hdr[0:4])
+		sum := binary.LittleEndian.Uint32(hdr[4:8])
+		if n == 0 || n > maxRecordBytes {
+			break
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(r, payload); err != nil || recordChecksum(hdr[8:16], payload) != sum {
+			break
+		}
+		var snap ProfilerSnapshot
+		if err := json.Unmarshal(payload, &snap); err != nil {
+			break
+		}
+		recs = append(recs, storedSnapshot{seq: binary.LittleEndian.Uint64(hdr[8:16]), snap: snap})
+		valid += int64(recordHeader + n)
+	}
+	return recs, valid, nil
+}
+
+// thinSnapshots keeps the last snapshot of every step and drops the
+// per-type top lists, which dominate record size.
+func thinSnapshots(recs []storedSnapshot, step time.Duration) []storedSnapshot {
+	out := make([]storedSnapshot, 0, len(recs)/10+1)
+	for i, r := range recs {
+		if i+1 < len(recs) && recs[i+1].snap.Timestamp.Truncate(step).Equal(r.snap.Timestamp.Truncate(step)) {
+			continue
+		}
+		r.snap.TopAllocations = nil
+		r.snap.TopRetentions = nil
+		out = append(out, r)
+	}
+	return out
+}
+
+func writeFileSync(path string, write func(io.Writer) error) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir makes renames and new files in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("filestore: open dir %s: %w", dir, err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("filestore: sync dir %s: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package profiler
+
+import (
+	"math"
+	"time"
+)
+
+// Sources of OOMForecast.LimitBytes.
+const (
+	LimitSourceGOMemLimit = "gomemlimit"
+	LimitSourceCgroup     = "cgroup"
+)
+
+// maxForecast is the horizon beyond which no OOM time is predicted.
+const maxForecast = 365 * 24 * time.Hour
+
+// forecastWindow is the heap history the forecast fits when leak detection
+// is disabled; forecastMinSpan is the least history it fits at all.
+const (
+	forecastWindow  = time.Hour
+	forecastMinSpan = 5 * time.Minute
+)
+
+// OOMForecast predicts when memory use reaches the effective limit, the lower
+// of GOMEMLIMIT and the cgroup memory limit. Usage is the memory the Go
+// runtime holds from the OS or, against the cgroup limit, the cgroup working
+// set when that is larger; it is extrapolated with the post-GC heap trend
+// over LeakWindowSec (the last hour when leak detection is off), refitted
+// with the leak trends once 5 minutes of history exist. SecondsToLimit is -1
+// when no limit is known, the heap is not growing with LeakMinConfidence, or
+// the limit is more than a year away.
+type OOMForecast struct {
+	LimitBytes         uint64     `json:"limit_bytes"`
+	LimitSource        string     `json:"limit_source,omitempty"`
+	UsageBytes         uint64     `json:"usage_bytes"`
+	GrowthBytesPerHour float64    `json:"growth_bytes_per_hour"`
+	Confidence         float64    `json:"confidence"`
+	SecondsToLimit     float64    `json:"seconds_to_limit"`
+	PredictedAt        *time.Time `json:"predicted_at,omitempty"`
+}
+
+// Forecast returns the OOM forecast for the latest snapshot using the latest
+// heap trend.
+func (p *Profiler) Forecast() OOMForecast {
+	snap := p.LatestSnapshot()
+
+	p.rlockMu()
+	defer p.mu.RUnlock()
+	return p.forecastLocked(&snap)
+}
+
+// forecastLocked builds the forecast for snap. Caller must hold p.mu.
+func (p *Profiler) forecastLocked(snap *ProfilerSnapshot) OOMForecast {
+	f := OOMForecast{SecondsToLimit: -1}
+	if l := snap.GOMemLimitBytes; l > 0 && l < math.MaxInt64 {
+		f.LimitBytes, f.LimitSource = l, LimitSourceGOMemLimit
+	}
+	if l := snap.CgroupLimitBytes; l > 0 && (f.LimitBytes == 0 || l < f.LimitBytes) {
+		f.LimitBytes, f.LimitSource = l, LimitSourceCgroup
+	}
+	if snap.RuntimeTotalBytes > snap.HeapReleased {
+		f.UsageBytes = snap.RuntimeTotalBytes - snap.HeapReleased
+	}
+	if f.LimitSource == LimitSourceCgroup {
+		f.UsageBytes = max(f.UsageBytes, snap.CgroupWorkingSetBytes)
+	}
+	if h := p.growth; h != nil {
+		f.GrowthBytesPerHour, f.Confidence = h.GrowthBytesPerHour, h.Confidence
+	}
+
+	if f.LimitBytes == 0 || f.GrowthBytesPerHour <= 0 || f.Confidence < p.cfg.LeakMinConfidence {
+		return f
+	}
+	headroom := max(float64(f.LimitBytes)-float64(f.UsageBytes), 0)
+	secs := headroom / (f.GrowthBytesPerHour / 3600)
+	if secs > maxForecast.Seconds() {
+		return f
+	}
+	f.SecondsToLimit = secs
+	at := snap.Timestamp.Add(time.Duration(secs * float64(time.Second)))
+	f.PredictedAt = &at
+	return f
+}
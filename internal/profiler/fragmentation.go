@@ -0,0 +1,144 @@
+package profiler
+
+import (
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"time"
+)
+
+// smallObjectMaxBytes is the largest object size counted as a small-object
+// allocation.
+const smallObjectMaxBytes = 128
+
+// minIdleBytes keeps small heaps from being flagged for idle memory.
+const minIdleBytes = 64 << 20
+
+// FragmentationStats interprets the heap's span accounting.
+type FragmentationStats struct {
+	// IdleNotReleasedBytes is free heap memory the runtime still holds from
+	// the OS because the scavenger has not returned it yet.
+	IdleNotReleasedBytes uint64 `json:"idle_not_released_bytes"`
+
+	// UnusedInSpanBytes is memory in in-use spans that holds no objects.
+	UnusedInSpanBytes uint64 `json:"unused_in_span_bytes"`
+
+	// InuseToAllocRatio is HeapInuse over HeapAlloc; 1 means spans are
+	// fully packed.
+	InuseToAllocRatio float64 `json:"inuse_to_alloc_ratio"`
+
+	// SpanOverheadPercent is span metadata as a share of HeapInuse.
+	SpanOverheadPercent float64 `json:"span_overhead_percent"`
+
+	// SmallAllocsPerSec is the rate of heap allocations of at most 128 bytes
+	// since the previous sample. Tiny allocations combined into one 16-byte
+	// block count once.
+	SmallAllocsPerSec float64 `json:"small_allocs_per_sec"`
+}
+
+// SizeClassStat is the allocation count of one heap size class, as in
+// runtime.MemStats.BySize. Size is the largest object size in the class, or 0
+// for objects larger than every size class.
+type SizeClassStat struct {
+	Size         uint64  `json:"size"`
+	Mallocs      uint64  `json:"mallocs"`
+	Frees        uint64  `json:"frees"`
+	LiveObjects  uint64  `json:"live_objects"`
+	LiveBytes    uint64  `json:"live_bytes"` // upper bound; 0 for large objects
+	AllocsPerSec float64 `json:"allocs_per_sec"`
+}
+
+// sizeClassCount is a cumulative size class reading in memSample.
+type sizeClassCount struct {
+	size    uint64
+	mallocs uint64
+	frees   uint64
+}
+
+// fragmentation computes FragmentationStats for ms, with the small-object
+// allocation rate from the latest size class stats.
+func fragmentation(ms *memSample, classes []SizeClassStat) FragmentationStats {
+	f := FragmentationStats{}
+	if ms.heapIdle > ms.heapReleased {
+		f.IdleNotReleasedBytes = ms.heapIdle - ms.heapReleased
+	}
+	if ms.heapInuse > ms.heapAlloc {
+		f.UnusedInSpanBytes = ms.heapInuse - ms.heapAlloc
+	}
+	if ms.heapAlloc > 0 {
+		f.InuseToAllocRatio = float64(ms.heapInuse) / float64(ms.heapAlloc)
+	}
+	if ms.heapInuse > 0 {
+		f.SpanOverheadPercent = float64(ms.mspanInuse) / float64(ms.heapInuse) * 100
+	}
+	for _, c := range classes {
+		if c.Size > 0 && c.Size <= smallObjectMaxBytes {
+			f.SmallAllocsPerSec += c.AllocsPerSec
+		}
+	}
+	return f
+}
+
+// SizeClasses returns the per size class allocation stats of the latest
+// sample, smallest class first.
+func (p *Profiler) SizeClasses() []SizeClassStat {
+	p.rlockMu()
+	defer p.mu.RUnlock()
+	return append([]SizeClassStat{}, p.sizeClasses...)
+}
+
+// updateSizeClassesLocked replaces the size class stats with those of ms,
+// deriving allocation rates from the previous sample. Caller must hold p.mu.
+func (p *Profiler) updateSizeClassesLocked(ms *memSample, now time.Time) {
+	prev := p.sizeClasses
+	dt := now.Sub(p.sizeClassesAt).Seconds()
+
+	out := make([]SizeClassStat, 0, len(ms.sizeClasses))
+	for i, c := range ms.sizeClasses {
+		st := SizeClassStat{Size: c.size, Mallocs: c.mallocs, Frees: c.frees}
+		if c.mallocs > c.frees {
+			st.LiveObjects = c.mallocs - c.frees
+			st.LiveBytes = st.LiveObjects * c.size
+		}
+		if len(prev) == len(ms.sizeClasses) && prev[i].Size == c.size && dt > 0 && c.mallocs >= prev[i].Mallocs {
+			st.AllocsPerSec = float64(c.mallocs-prev[i].Mallocs) / dt
+		}
+		out = append(out, st)
+	}
+	p.sizeClasses, p.sizeClassesAt = out, now
+}
+
+// sizeClassesFromMetrics reads size classes from the allocs-by-size and
+// frees-by-size histograms, which count the same as MemStats.BySize without
+// stopping the world. Bucket i holds objects in [Buckets[i], Buckets[i+1]).
+func sizeClassesFromMetrics(allocs, frees metrics.Sample) []sizeClassCount {
+	if allocs.Value.Kind() != metrics.KindFloat64Histogram || frees.Value.Kind() != metrics.KindFloat64Histogram {
+		return nil
+	}
+	a, f := allocs.Value.Float64Histogram(), frees.Value.Float64Histogram()
+	if len(a.Counts) != len(f.Counts) {
+		return nil
+	}
+	out := make([]sizeClassCount, len(a.Counts))
+	for i := range a.Counts {
+		size := uint64(0)
+		if upper := a.Buckets[i+1]; !math.IsInf(upper, 1) {
+			size = uint64(upper) - 1
+		}
+		out[i] = sizeClassCount{size: size, mallocs: a.Counts[i], frees: f.Counts[i]}
+	}
+	return out
+}
+
+// sizeClassesFromMemStats reads size classes from MemStats.BySize, skipping
+// the unused class 0.
+func sizeClassesFromMemStats(ms *runtime.MemStats) []sizeClassCount {
+	out := make([]sizeClassCount, 0, len(ms.BySize))
+	for _, c := range ms.BySize {
+		if c.Size == 0 {
+			continue
+		}
+		out = append(out, sizeClassCount{size: uint64(c.Size), mallocs: c.Mallocs, frees: c.Frees})
+	}
+	return out
+}
@@ -0,0 +1,219 @@
+package profiler
+
+import (
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"strings"
+	"time"
+)
+
+// maxGCAdjustments bounds the audit log kept by the GC controller.
+const maxGCAdjustments = 256
+
+// GCAdjustment is one change of GOGC or the memory limit made by the GC
+// controller, or the restore done by its kill switch. Memory limits are 0
+// when unset.
+type GCAdjustment struct {
+	At                   time.Time `json:"at"`
+	Reason               string    `json:"reason"`
+	FromGOGCPercent      int64     `json:"from_gogc_percent"`
+	ToGOGCPercent        int64     `json:"to_gogc_percent"`
+	FromMemoryLimitBytes uint64    `json:"from_memory_limit_bytes"`
+	ToMemoryLimitBytes   uint64    `json:"to_memory_limit_bytes"`
+}
+
+// GCControllerStatus reports the GC controller and the settings currently in
+// effect. Active is false when the controller is not enabled or was killed.
+type GCControllerStatus struct {
+	Enabled          bool           `json:"enabled"`
+	Killed           bool           `json:"killed"`
+	Active           bool           `json:"active"`
+	GOGCPercent      int64          `json:"gogc_percent"`
+	MemoryLimitBytes uint64         `json:"memory_limit_bytes"`
+	AdjustmentsTotal uint64         `json:"adjustments_total"`
+	Adjustments      []GCAdjustment `json:"adjustments"`
+}
+
+// gcController is the state of the GC controller, guarded by
+// Profiler.ctlMu.
+type gcController struct {
+	killed bool
+	last   time.Time
+
+	// saved records the settings before the first adjustment, which the
+	// kill switch restores.
+	saved     bool
+	origGOGC  int64
+	origLimit uint64
+
+	log   []GCAdjustment
+	total uint64
+}
+
+func (c *gcController) record(a GCAdjustment) {
+	if len(c.log) == maxGCAdjustments {
+		copy(c.log, c.log[1:])
+		c.log = c.log[:len(c.log)-1]
+	}
+	c.log = append(c.log, a)
+	c.total++
+}
+
+// GCController returns the GC controller status with its audit log, oldest
+// adjustment first.
+func (p *Profiler) GCController() GCControllerStatus {
+	p.ctlMu.Lock()
+	defer p.ctlMu.Unlock()
+
+	gogc, limit := readGCSettings()
+	return GCControllerStatus{
+		Enabled:          p.cfg.GCControllerEnabled,
+		Killed:           p.ctl.killed,
+		Active:           p.cfg.GCControllerEnabled && !p.ctl.killed,
+		GOGCPercent:      gogc,
+		MemoryLimitBytes: limit,
+		AdjustmentsTotal: p.ctl.total,
+		Adjustments:      append([]GCAdjustment{}, p.ctl.log...),
+	}
+}
+
+// KillGCController stops the GC controller for the life of the process and
+// restores the GOGC and memory limit in effect before its first adjustment.
+func (p *Profiler) KillGCController() GCControllerStatus {
+	p.ctlMu.Lock()
+	if !p.ctl.killed {
+		p.ctl.killed = true
+		if p.ctl.saved {
+			gogc, limit := readGCSettings()
+			applyGCSettings(p.ctl.origGOGC, p.ctl.origLimit)
+			p.ctl.record(GCAdjustment{
+				At:                   p.clock.Now().UTC(),
+				Reason:               "kill switch: restored settings from before the controller",
+				FromGOGCPercent:      gogc,
+				ToGOGCPercent:        p.ctl.origGOGC,
+				FromMemoryLimitBytes: limit,
+				ToMemoryLimitBytes:   p.ctl.origLimit,
+			})
+		}
+		p.logger.Warn("gc controller killed", "restored", p.ctl.saved)
+	}
+	p.ctlMu.Unlock()
+
+	return p.GCController()
+}
+
+// AdjustGC runs one step of the GC controller: it moves the memory limit
+// toward GCMemoryHeadroomPercent under the cgroup limit and GOGC toward the
+// latest GC tuning advice, each by at most GCControllerMaxStepPercent and
+// within the configured bounds. The memory limit is never set within a
+// quarter of the live heap. It does nothing when the controller is disabled
+// or killed, or when the last adjustment is more recent than
+// GCControllerMinIntervalSec, and reports whether settings changed.
+func (p *Profiler) AdjustGC() (GCAdjustment, bool) {
+	if !p.cfg.GCControllerEnabled {
+		return GCAdjustment{}, false
+	}
+	p.ctlMu.Lock()
+	defer p.ctlMu.Unlock()
+
+	now := p.clock.Now().UTC()
+	interval := time.Duration(p.cfg.GCControllerMinIntervalSec) * time.Second
+	if p.ctl.killed || (!p.ctl.last.IsZero() && now.Sub(p.ctl.last) < interval) {
+		return GCAdjustment{}, false
+	}
+	latest := p.LatestSnapshot()
+	if latest.Timestamp.IsZero() {
+		return GCAdjustment{}, false
+	}
+
+	curGOGC, curLimit := readGCSettings()
+	step := p.cfg.GCControllerMaxStepPercent / 100
+	gogc, limit := curGOGC, curLimit
+	var reasons []string
+
+	if ceiling := p.memoryLimitCeiling(&latest); ceiling > 0 {
+		live := uint64(heapBaseline(float64(latest.HeapLiveBytes), float64(latest.HeapAllocBytes)))
+		floor := max(uint64(p.cfg.GCControllerMinMemoryLimitBytes), live*5/4+nonHeapBytes(&latest))
+		target := min(max(ceiling, floor), latest.CgroupLimitBytes)
+		if curLimit == 0 {
+			limit = target
+		} else {
+			limit = uint64(stepToward(float64(curLimit), float64(target), step)) &^ (1<<20 - 1)
+		}
+		if diff := int64(limit) - int64(curLimit); curLimit != 0 && diff > -1<<20 && diff < 1<<20 {
+			limit = curLimit
+		}
+		if limit != curLimit {
+			reasons = append(reasons, "memory limit toward "+formatBytes(float64(target))+" ("+formatFloat(p.cfg.GCMemoryHeadroomPercent, 1)+"% headroom under the "+formatBytes(float64(latest.CgroupLimitBytes))+" cgroup limit)")
+		}
+	}
+
+	if curGOGC >= 0 {
+		target := curGOGC
+		p.rlockMu()
+		if len(p.gcTuning.Suggestions) > 0 {
+			target = p.gcTuning.Recommended.GOGCPercent
+		}
+		p.mu.RUnlock()
+		target = min(max(target, int64(p.cfg.GCControllerMinGOGC)), int64(p.cfg.GCControllerMaxGOGC))
+		gogc = int64(math.Round(stepToward(float64(curGOGC), float64(target), step)))
+		if gogc != curGOGC {
+			reasons = append(reasons, "GOGC toward "+itoa(target)+" (GC tuning advice within ["+itoa(int64(p.cfg.GCControllerMinGOGC))+", "+itoa(int64(p.cfg.GCControllerMaxGOGC))+"])")
+		}
+	}
+
+	if len(reasons) == 0 {
+		return GCAdjustment{}, false
+	}
+	if !p.ctl.saved {
+		p.ctl.saved, p.ctl.origGOGC, p.ctl.origLimit = true, curGOGC, curLimit
+	}
+	applyGCSettings(gogc, limit)
+
+	adj := GCAdjustment{
+		At:                   now,
+		Reason:               strings.Join(reasons, "; "),
+		FromGOGCPercent:      curGOGC,
+		ToGOGCPercent:        gogc,
+		FromMemoryLimitBytes: curLimit,
+		ToMemoryLimitBytes:   limit,
+	}
+	p.ctl.last = now
+	p.ctl.record(adj)
+	p.logger.Info("gc controller adjusted settings",
+		"gogc", gogc, "memory_limit_bytes", limit, "reason", adj.Reason)
+	return adj, true
+}
+
+// stepToward moves cur toward target by at most maxStep of cur.
+func stepToward(cur, target, maxStep float64) float64 {
+	return min(max(target, cur*(1-maxStep)), cur*(1+maxStep))
+}
+
+// readGCSettings returns the GOGC and memory limit in effect. GOGC is -1 when
+// GC is off and the limit 0 when unset.
+func readGCSettings() (gogc int64, limit uint64) {
+	s := []metrics.Sample{{Name: mGOGC}, {Name: mGOMemLimit}}
+	metrics.Read(s)
+	gogc = 100
+	if s[0].Value.Kind() == metrics.KindUint64 {
+		// The runtime reports GOGC=off as -1 converted to uint64.
+		gogc = int64(int32(s[0].Value.Uint64()))
+	}
+	if s[1].Value.Kind() == metrics.KindUint64 {
+		if v := s[1].Value.Uint64(); v < math.MaxInt64 {
+			limit = v
+		}
+	}
+	return gogc, limit
+}
+
+func applyGCSettings(gogc int64, limit uint64) {
+	debug.SetGCPercent(int(gogc))
+	if limit == 0 {
+		debug.SetMemoryLimit(math.MaxInt64)
+	} else {
+		debug.SetMemoryLimit(int64(limit))
+	}
+}
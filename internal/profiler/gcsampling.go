@@ -0,0 +1,85 @@
+package profiler
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// Sampling modes recorded in ProfilerSnapshot.SamplingMode.
+const (
+	SamplingModeInterval = "interval"
+	SamplingModeGC       = "gc"
+)
+
+// gcNotifier signals c after every GC cycle. It keeps one unreachable
+// sentinel object whose finalizer runs once the GC has found it dead, sends
+// on c without blocking and arms a new sentinel for the next cycle.
+type gcNotifier struct {
+	c       chan struct{}
+	stopped atomic.Bool
+}
+
+// gcSentinel holds a pointer so it is not placed in the tiny allocator, where
+// finalizers may never run.
+type gcSentinel struct {
+	n *gcNotifier
+}
+
+func newGCNotifier() *gcNotifier {
+	n := &gcNotifier{c: make(chan struct{}, 1)}
+	n.arm()
+	return n
+}
+
+func (n *gcNotifier) arm() {
+	runtime.SetFinalizer(&gcSentinel{n: n}, gcCompleted)
+}
+
+func gcCompleted(s *gcSentinel) {
+	n := s.n
+	if n.stopped.Load() {
+		return
+	}
+	select {
+	case n.c <- struct{}{}:
+	default:
+	}
+	n.arm()
+}
+
+// stop disarms the notifier after the next GC cycle.
+func (n *gcNotifier) stop() {
+	n.stopped.Store(true)
+}
+
+// runGCSamplingLoop samples after each GC cycle, at most once per sampling
+// interval. Without a GC for two intervals it takes an interval sample
+// instead.
+func (p *Profiler) runGCSamplingLoop(ctx context.Context) {
+	n := newGCNotifier()
+	defer n.stop()
+
+	fallback := p.clock.NewTicker(2 * p.SamplingInterval())
+	defer fallback.Stop()
+
+	var last time.Time
+	for {
+		mode := SamplingModeGC
+		select {
+		case <-ctx.Done():
+			p.logger.Info("profiler: stopping sampling loop", "reason", "context_cancelled")
+			return
+		case <-n.c:
+			if p.clock.Now().Sub(last) < p.SamplingInterval() {
+				continue
+			}
+		case <-fallback.C():
+			mode = SamplingModeInterval
+		}
+		p.sampleOnce(mode)
+		last = p.clock.Now()
+		fallback.Reset(2 * p.SamplingInterval())
+	}
+}
@@ -0,0 +1,372 @@
+package profiler
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// gcTuningEvalInterval throttles the GC tuning advisor in the sampling loop.
+const gcTuningEvalInterval = time.Minute
+
+// Bounds of the GOGC values the advisor recommends, which are rounded to
+// gogcStep.
+const (
+	minAdvisedGOGC = 50
+	maxAdvisedGOGC = 800
+	gogcStep       = 25
+)
+
+// minGCCycles is the number of GC cycles the advisor needs to see before it
+// recommends anything.
+const minGCCycles = 3
+
+// minHeapGoal is the smallest heap goal of the Go pacer at GOGC=100.
+const minHeapGoal = 4 << 20
+
+// GCSettings is a GOGC/GOMEMLIMIT configuration and how the GC behaves under
+// it: observed for the current settings, predicted for recommended ones.
+type GCSettings struct {
+	GOGCPercent       int64   `json:"gogc_percent"`     // -1 when GC is off
+	GOMemLimitBytes   uint64  `json:"gomemlimit_bytes"` // 0 when unset
+	GCCyclesPerMinute float64 `json:"gc_cycles_per_minute"`
+	GCCPUFraction     float64 `json:"gc_cpu_fraction"`
+	PeakHeapBytes     uint64  `json:"peak_heap_bytes"`
+}
+
+// GCTuningReport is the result of AdviseGCTuning. Current is what the GC did
+// over [From, To], which are nil before enough GC cycles were observed; Recommended is the advised configuration with the
+// behavior a model of the GC pacer, calibrated on Current, predicts for it.
+// Both are equal when nothing is advised.
+type GCTuningReport struct {
+	EvaluatedAt         time.Time                `json:"evaluated_at"`
+	Tier                string                   `json:"tier,omitempty"`
+	From                *time.Time               `json:"from,omitempty"`
+	To                  *time.Time               `json:"to,omitempty"`
+	AllocBytesPerSec    float64                  `json:"alloc_bytes_per_sec"`
+	LiveHeapBytes       uint64                   `json:"live_heap_bytes"`
+	NonHeapBytes        uint64                   `json:"non_heap_bytes"`
+	ContainerLimitBytes uint64                   `json:"container_limit_bytes"`
+	Current             GCSettings               `json:"current"`
+	Recommended         GCSettings               `json:"recommended"`
+	Suggestions         []OptimizationSuggestion `json:"suggestions"`
+}
+
+// gcObservation summarizes GC activity over a stretch of history.
+type gcObservation struct {
+	from, to    time.Time
+	cycles      float64
+	allocBytes  float64
+	liveMax     float64
+	liveAvg     float64
+	goalMax     float64
+	cpuFraction float64
+}
+
+func (o *gcObservation) minutes() float64 {
+	return o.to.Sub(o.from).Minutes()
+}
+
+// GCTuning returns the latest GC tuning report computed by the sampling
+// loop.
+func (p *Profiler) GCTuning() GCTuningReport {
+	p.rlockMu()
+	defer p.mu.RUnlock()
+
+	rep := p.gcTuning
+	rep.Suggestions = append([]OptimizationSuggestion(nil), rep.Suggestions...)
+	return rep
+}
+
+// maybeAdviseGCTuning runs AdviseGCTuning at most every
+// gcTuningEvalInterval.
+func (p *Profiler) maybeAdviseGCTuning(now time.Time) {
+	p.rlockMu()
+	last := p.gcTuning.EvaluatedAt
+	p.mu.RUnlock()
+
+	if !last.IsZero() && now.Sub(last) < gcTuningEvalInterval {
+		return
+	}
+	p.AdviseGCTuning()
+}
+
+// AdviseGCTuning reads GC frequency, GC CPU, the live heap against its goal
+// and the container limit over the last GCTuningWindowSec of history, and
+// keeps the resulting advice for GCTuning and the suggestions generated by
+// the next sample.
+func (p *Profiler) AdviseGCTuning() GCTuningReport {
+	rep := p.adviseGCTuning()
+
+	p.lockMu()
+	p.gcTuning = rep
+	p.mu.Unlock()
+	return rep
+}
+
+func (p *Profiler) adviseGCTuning() GCTuningReport {
+	latest := p.LatestSnapshot()
+	window := time.Duration(p.cfg.GCTuningWindowSec) * time.Second
+	rep := GCTuningReport{EvaluatedAt: latest.Timestamp, Suggestions: []OptimizationSuggestion{}}
+	if window <= 0 || latest.Timestamp.IsZero() {
+		return rep
+	}
+	from := latest.Timestamp.Add(-window)
+
+	p.rlockMu()
+	rawRetention := p.rawRetentionLocked()
+	p.mu.RUnlock()
+
+	var h HistoryResult
+	if window <= rawRetention {
+		h = HistoryResult{Tier: TierRaw, Snapshots: p.SnapshotsRange(from, time.Time{}, 0)}
+	} else {
+		h = p.History(from, time.Time{}, 0, 0)
+	}
+	rep.Tier = h.Tier
+	obs, ok := observeGC(&h, latest.Timestamp)
+	if !ok {
+		return rep
+	}
+
+	nonHeap := float64(nonHeapBytes(&latest))
+	curLimit := uint64(0)
+	if l := latest.GOMemLimitBytes; l > 0 && l < math.MaxInt64 {
+		curLimit = l
+	}
+
+	rep.From, rep.To = &obs.from, &obs.to
+	rep.AllocBytesPerSec = obs.allocBytes / obs.to.Sub(obs.from).Seconds()
+	rep.LiveHeapBytes = uint64(obs.liveMax)
+	rep.NonHeapBytes = uint64(nonHeap)
+	rep.ContainerLimitBytes = latest.CgroupLimitBytes
+	rep.Current = GCSettings{
+		GOGCPercent:       latest.GOGCPercent,
+		GOMemLimitBytes:   curLimit,
+		GCCyclesPerMinute: obs.cycles / obs.minutes(),
+		GCCPUFraction:     obs.cpuFraction,
+		PeakHeapBytes:     uint64(obs.goalMax),
+	}
+	rep.Recommended = rep.Current
+
+	if obs.cycles < minGCCycles || obs.to.Sub(obs.from) < window/4 {
+		return rep
+	}
+
+	var reasons []string
+	severity := "info"
+	rec := rep.Current
+	target := p.cfg.GCTargetCPUPercent / 100
+
+	ceiling := p.memoryLimitCeiling(&latest)
+	limitBound := curLimit > 0 &&
+		heapGoal(obs.liveMax, rec.GOGCPercent, float64(curLimit), nonHeap) < heapGoal(obs.liveMax, rec.GOGCPercent, 0, 0)
+	switch {
+	case ceiling == 0:
+	case curLimit == 0:
+		rec.GOMemLimitBytes = ceiling
+		severity = "warning"
+		reasons = append(reasons, "No GOMEMLIMIT is set, so the GC ignores the "+formatBytes(float64(latest.CgroupLimitBytes))+" container limit.")
+	case float64(curLimit) > float64(ceiling)*1.05:
+		rec.GOMemLimitBytes = ceiling
+		severity = "warning"
+		reasons = append(reasons, "GOMEMLIMIT leaves too little of the "+formatBytes(float64(latest.CgroupLimitBytes))+" container limit for non-Go memory and headroom.")
+	case float64(curLimit) < float64(ceiling)*0.8 && limitBound:
+		rec.GOMemLimitBytes = ceiling
+		reasons = append(reasons, "The heap runs into GOMEMLIMIT while the container has room for more.")
+	}
+
+	// Move GOGC toward the GC CPU target. Above it, the live heap must still
+	// fit under the recommended limit at the new GOGC.
+	switch cpu, gogc := obs.cpuFraction, rec.GOGCPercent; {
+	case gogc >= 0 && cpu > 2*target:
+		want := float64(max(gogc, 1)) * min(cpu/target, 4)
+		if rec.GOMemLimitBytes > 0 && obs.liveMax > 0 {
+			want = min(want, ((float64(rec.GOMemLimitBytes)-nonHeap)/obs.liveMax-1)*100)
+		}
+		if g := roundGOGC(want); g > gogc {
+			rec.GOGCPercent = g
+			reasons = append(reasons, "The GC uses "+formatFloat(cpu*100, 1)+"% of CPU, more than twice the "+formatFloat(target*100, 1)+"% target.")
+		}
+	case gogc > 100 && cpu < target/4:
+		if g := max(roundGOGC(float64(gogc)*cpu/target), 100); g < gogc {
+			rec.GOGCPercent = g
+			reasons = append(reasons, "The GC uses only "+formatFloat(cpu*100, 1)+"% of CPU; a lower GOGC trades some of the "+formatFloat(target*100, 1)+"% target for a smaller heap.")
+		}
+	}
+
+	// A live heap close to what the limit leaves for it makes the GC run
+	// almost continuously; no setting fixes that.
+	if l := rec.GOMemLimitBytes; l > 0 && obs.liveMax > 0.9*(float64(l)-nonHeap) {
+		severity = "warning"
+		reasons = append(reasons, "The live heap ("+formatBytes(obs.liveMax)+") nearly fills what GOMEMLIMIT leaves for the heap, so the GC will run almost continuously; reduce the live heap or give the container more memory.")
+	}
+	if len(reasons) == 0 {
+		return rep
+	}
+
+	// Predict the recommended settings with the pacer model, scaled by how
+	// far the model is off for the current ones.
+	model := func(s GCSettings) float64 {
+		goal := heapGoal(obs.liveAvg, s.GOGCPercent, float64(s.GOMemLimitBytes), nonHeap)
+		return rep.AllocBytesPerSec * 60 / max(goal-obs.liveAvg, 1<<20)
+	}
+	if m := model(rep.Current); m > 0 && rep.Current.GCCyclesPerMinute > 0 {
+		rec.GCCyclesPerMinute = model(rec) * rep.Current.GCCyclesPerMinute / m
+		rec.GCCPUFraction = rep.Current.GCCPUFraction * rec.GCCyclesPerMinute / rep.Current.GCCyclesPerMinute
+	}
+	if peak := heapGoal(obs.liveMax, rec.GOGCPercent, float64(rec.GOMemLimitBytes), nonHeap); !math.IsInf(peak, 1) {
+		rec.PeakHeapBytes = uint64(peak)
+	}
+	rep.Recommended = rec
+
+	rep.Suggestions = append(rep.Suggestions, OptimizationSuggestion{
+		ID:        nextID("suggestion"),
+		Kind:      SuggestionGCTuning,
+		Severity:  severity,
+		Message:   buildGCTuningMessage(&rep, reasons),
+		CreatedAt: latest.Timestamp,
+	})
+	return rep
+}
+
+// observeGC summarizes h up to the latest snapshot at latest. Rollups give
+// counters at bucket granularity and the live heap as bucket maximums.
+func observeGC(h *HistoryResult, latest time.Time) (gcObservation, bool) {
+	var o gcObservation
+	var live, cpu []float64
+	switch {
+	case len(h.Snapshots) >= 2:
+		first, last := &h.Snapshots[0], &h.Snapshots[len(h.Snapshots)-1]
+		o.from, o.to = first.Timestamp, last.Timestamp
+		o.cycles = float64(last.NumGC - first.NumGC)
+		if last.TotalAllocBytes > first.TotalAllocBytes {
+			o.allocBytes = float64(last.TotalAllocBytes - first.TotalAllocBytes)
+		}
+		for i := range h.Snapshots {
+			s := &h.Snapshots[i]
+			live = append(live, heapBaseline(float64(s.HeapLiveBytes), float64(s.HeapAllocBytes)))
+			cpu = append(cpu, s.GCCPUFraction)
+			o.goalMax = max(o.goalMax, float64(s.NextGCBytes))
+		}
+	case len(h.Rollups) >= 2:
+		first, last := h.Rollups[0].Fields, h.Rollups[len(h.Rollups)-1].Fields
+		o.from, o.to = h.Rollups[0].Start, latest
+		o.cycles = max(last["num_gc"].Last-first["num_gc"].Min, 0)
+		o.allocBytes = max(last["total_alloc_bytes"].Last-first["total_alloc_bytes"].Min, 0)
+		for _, pt := range h.Rollups {
+			live = append(live, heapBaseline(pt.Fields["heap_live_bytes"].Max, pt.Fields["heap_alloc_bytes"].Min))
+			cpu = append(cpu, pt.Fields["gc_cpu_fraction"].Avg)
+			o.goalMax = max(o.goalMax, pt.Fields["next_gc_bytes"].Max)
+		}
+	default:
+		return o, false
+	}
+	if !o.to.After(o.from) {
+		return o, false
+	}
+
+	for i := range live {
+		o.liveMax = max(o.liveMax, live[i])
+		o.liveAvg += live[i] / float64(len(live))
+		o.cpuFraction += cpu[i] / float64(len(cpu))
+	}
+	return o, true
+}
+
+// memoryLimitCeiling is the GOMEMLIMIT that leaves GCMemoryHeadroomPercent
+// of the cgroup limit free after non-Go memory, rounded down to MiB. It is 0
+// without a cgroup limit.
+func (p *Profiler) memoryLimitCeiling(snap *ProfilerSnapshot) uint64 {
+	c, nonGo := snap.CgroupLimitBytes, snap.NonGoBytes
+	if c <= nonGo {
+		return 0
+	}
+	return uint64(float64(c-nonGo)*(1-p.cfg.GCMemoryHeadroomPercent/100)) &^ (1<<20 - 1)
+}
+
+// nonHeapBytes is memory the Go runtime holds outside the heap: stacks and
+// runtime metadata.
+func nonHeapBytes(snap *ProfilerSnapshot) uint64 {
+	if heap := snap.HeapInuseBytes + snap.HeapIdleBytes; snap.RuntimeTotalBytes > heap {
+		return snap.RuntimeTotalBytes - heap
+	}
+	return 0
+}
+
+// heapGoal models the pacer: the heap grows to live*(1+GOGC/100) between
+// cycles, but no further than a memory limit leaves beside non-heap memory.
+// It is +Inf with GC off and no limit.
+func heapGoal(live float64, gogc int64, limit, nonHeap float64) float64 {
+	goal := math.Inf(1)
+	if gogc >= 0 {
+		goal = max(live*(1+float64(gogc)/100), minHeapGoal*float64(gogc)/100)
+	}
+	if limit > 0 {
+		goal = min(goal, limit-nonHeap)
+	}
+	return max(goal, live)
+}
+
+func roundGOGC(v float64) int64 {
+	g := int64(math.Round(v/gogcStep)) * gogcStep
+	return min(max(g, minAdvisedGOGC), maxAdvisedGOGC)
+}
+
+// gcTuningSuggestionsLocked returns the suggestions of the latest GC tuning
+// report. Caller must hold p.mu.
+func (p *Profiler) gcTuningSuggestionsLocked() []OptimizationSuggestion {
+	return append([]OptimizationSuggestion(nil), p.gcTuning.Suggestions...)
+}
+
+func buildGCTuningMessage(rep *GCTuningReport, reasons []string) string {
+	cur, rec := &rep.Current, &rep.Recommended
+
+	b := strings.Builder{}
+	var set []string
+	if rec.GOMemLimitBytes != cur.GOMemLimitBytes {
+		set = append(set, "GOMEMLIMIT="+itoa(int64(rec.GOMemLimitBytes>>20))+"MiB")
+	}
+	if rec.GOGCPercent != cur.GOGCPercent {
+		set = append(set, "GOGC="+formatGOGC(rec.GOGCPercent))
+	}
+	if len(set) > 0 {
+		b.WriteString("Set ")
+		b.WriteString(strings.Join(set, ", "))
+		b.WriteString(" (now GOMEMLIMIT=")
+		if cur.GOMemLimitBytes == 0 {
+			b.WriteString("unset")
+		} else {
+			b.WriteString(itoa(int64(cur.GOMemLimitBytes>>20)) + "MiB")
+		}
+		b.WriteString(", GOGC=")
+		b.WriteString(formatGOGC(cur.GOGCPercent))
+		b.WriteString("): GC cycles/min ")
+		b.WriteString(formatFloat(cur.GCCyclesPerMinute, 1))
+		b.WriteString(" -> ")
+		b.WriteString(formatFloat(rec.GCCyclesPerMinute, 1))
+		b.WriteString(", GC CPU ")
+		b.WriteString(formatFloat(cur.GCCPUFraction*100, 1))
+		b.WriteString("% -> ")
+		b.WriteString(formatFloat(rec.GCCPUFraction*100, 1))
+		b.WriteString("%, peak heap ")
+		b.WriteString(formatBytes(float64(cur.PeakHeapBytes)))
+		b.WriteString(" -> ")
+		b.WriteString(formatBytes(float64(rec.PeakHeapBytes)))
+		b.WriteString(".")
+	}
+	for _, r := range reasons {
+		if b.Len() > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(r)
+	}
+	return b.String()
+}
+
+func formatGOGC(g int64) string {
+	if g < 0 {
+		return "off"
+	}
+	return itoa(g)
+}
@@ -0,0 +1,297 @@
+package profiler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+)
+
+// History store kinds for ProfilerConfig.HistoryStore.
+const (
+	HistoryStoreMemory = "memory"
+	HistoryStoreFile   = "file"
+)
+
+// HistoryStore persists snapshots so history survives restarts. Appends come
+// from the sampling goroutine in timestamp order; Load is used once at
+// construction to replay history.
+type HistoryStore interface {
+	// Append records one snapshot.
+	Append(snap ProfilerSnapshot) error
+	// Load returns stored snapshots taken at or after since, oldest first.
+	Load(since time.Time) ([]ProfilerSnapshot, error)
+	// Close flushes and releases the store.
+	Close() error
+}
+
+// stateSaveInterval is how often the sampling loop saves ProfilerState to a
+// StateStore; Close saves it once more.
+const stateSaveInterval = time.Minute
+
+// ProfilerState is the aggregated state a StateStore keeps across restarts:
+// the allocation series and the latest suggestions. Rate windows are not
+// kept because they cover at most MaxRateWindow, and retentions are not kept
+// because the objects they count die with the process.
+type ProfilerState struct {
+	SavedAt     time.Time                `json:"saved_at"`
+	LiveSince   time.Time                `json:"live_since"`
+	Allocations []PersistedAllocation    `json:"allocations"`
+	Suggestions []OptimizationSuggestion `json:"suggestions"`
+}
+
+// PersistedAllocation is an AllocationStat plus the variances and activity
+// times its error bounds and eviction order are derived from.
+type PersistedAllocation struct {
+	AllocationStat
+	CountVar  float64   `json:"count_var"`
+	BytesVar  float64   `json:"bytes_var"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Overflow  bool      `json:"overflow,omitempty"`
+}
+
+// StateStore is implemented by history stores that also keep ProfilerState,
+// so allocation totals and suggestions survive restarts along with history.
+type StateStore interface {
+	// SaveState replaces the saved state.
+	SaveState(state ProfilerState) error
+	// LoadState returns the last saved state; ok is false if there is none.
+	LoadState() (state ProfilerState, ok bool, err error)
+}
+
+// MemoryStore keeps snapshots in process memory. It does not survive process
+// restarts, but lets a new Profiler pick up where a previous one in the same
+// process stopped.
+type MemoryStore struct {
+	mu        sync.Mutex
+	retention time.Duration
+	snaps     []ProfilerSnapshot
+	state     *ProfilerState
+}
+
+// NewMemoryStore returns a MemoryStore that drops snapshots older than
+// retention (0 keeps everything).
+func NewMemoryStore(retention time.Duration) *MemoryStore {
+	return &MemoryStore{retention: retention}
+}
+
+// Append implements HistoryStore.
+func (m *MemoryStore) Append(snap ProfilerSnapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.snaps = append(m.snaps, snap)
+	if m.retention > 0 {
+		cutoff := snap.Timestamp.Add(-m.retention)
+		drop := 0
+		for drop < len(m.snaps) && m.snaps[drop].Timestamp.Before(cutoff) {
+			drop++
+		}
+		m.snaps = append(m.snaps[:0], m.snaps[drop:]...)
+	}
+	return nil
+}
+
+// Load implements HistoryStore.
+func (m *MemoryStore) Load(since time.Time) ([]ProfilerSnapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]ProfilerSnapshot, 0, len(m.snaps))
+	for _, s := range m.snaps {
+		if !s.Timestamp.Before(since) {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// SaveState implements StateStore.
+func (m *MemoryStore) SaveState(state ProfilerState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state = &state
+	return nil
+}
+
+// LoadState implements StateStore.
+func (m *MemoryStore) LoadState() (ProfilerState, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.state == nil {
+		return ProfilerState{}, false, nil
+	}
+	return *m.state, true, nil
+}
+
+// Close implements HistoryStore.
+func (m *MemoryStore) Close() error { return nil }
+
+// openHistoryStore builds the store selected by cfg. The in-memory default
+// needs no store since the snapshot ring already keeps history.
+func openHistoryStore(cfg config.ProfilerConfig) (HistoryStore, error) {
+	switch cfg.HistoryStore {
+	case "", HistoryStoreMemory:
+		return nil, nil
+	case HistoryStoreFile:
+		s, err := OpenFileStore(cfg.HistoryStoreDir, FileStoreOptions{
+			SegmentBytes: int64(cfg.HistoryStoreSegmentBytes),
+			Retention:    time.Duration(cfg.HistoryStoreRetentionSec) * time.Second,
+			CompactAfter: time.Duration(cfg.RetentionWindowSec) * time.Second,
+			SyncEvery:    cfg.HistoryStoreSyncEvery,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown history store %q", cfg.HistoryStore)
+	}
+}
+
+// replayHistory loads persisted snapshots into the ring and rollup tiers and,
+// if the store keeps it, the saved ProfilerState. It runs during
+// construction, before the sampling loop starts.
+func (p *Profiler) replayHistory() {
+	if p.store == nil {
+		return
+	}
+
+	p.lockMu()
+	defer p.mu.Unlock()
+
+	horizon := p.rawRetentionLocked()
+	for _, t := range rollupTiers {
+		horizon = max(horizon, t.retention)
+	}
+	snaps, err := p.store.Load(p.clock.Now().UTC().Add(-horizon))
+	if err != nil {
+		p.logger.Warn("history replay failed", "error", err)
+		return
+	}
+
+	for _, s := range snaps {
+		p.appendSnapshotLocked(s)
+	}
+	if len(snaps) > 0 {
+		p.logger.Info("history replayed", "snapshots", len(snaps), "since", snaps[0].Timestamp)
+	}
+	p.restoreStateLocked()
+}
+
+// restoreStateLocked loads the saved allocation series and suggestions.
+// Series are admitted against MaxAllocSeries like new ones. Caller must hold
+// p.mu.
+func (p *Profiler) restoreStateLocked() {
+	ss, ok := p.store.(StateStore)
+	if !ok {
+		return
+	}
+	st, ok, err := ss.LoadState()
+	if err != nil {
+		p.logger.Warn("state restore failed", "error", err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	restored := 0
+	if p.sketch == nil {
+		for _, a := range st.Allocations {
+			key := a.TypeName + "|" + a.Tag
+			if a.Overflow {
+				key = a.TypeName + overflowKeySuffix
+			} else if !p.admitSeries(key) {
+				continue
+			}
+			stat := a.AllocationStat
+			stat.countVar, stat.bytesVar = a.CountVar, a.BytesVar
+			stat.firstSeen, stat.lastSeen = a.FirstSeen, a.LastSeen
+			stat.overflow = a.Overflow
+			p.allocs[key] = &stat
+			restored++
+		}
+		if !st.LiveSince.IsZero() && st.LiveSince.Before(p.liveSince) {
+			p.liveSince = st.LiveSince
+		}
+	}
+	p.suggestions = append(p.suggestions[:0], st.Suggestions...)
+	p.logger.Info("state restored", "series", restored, "suggestions", len(st.Suggestions), "saved_at", st.SavedAt)
+}
+
+// persistSnapshot appends snap to the history store outside p.mu, so slow
+// disks never block readers, and saves ProfilerState every
+// stateSaveInterval.
+func (p *Profiler) persistSnapshot(snap *ProfilerSnapshot) {
+	p.storeMu.Lock()
+	defer p.storeMu.Unlock()
+
+	if p.store == nil {
+		return
+	}
+	if err := p.store.Append(*snap); err != nil {
+		p.logger.Warn("history store append failed", "error", err)
+	}
+	if snap.Timestamp.Sub(p.stateSavedAt) >= stateSaveInterval {
+		p.persistState(snap.Timestamp)
+	}
+}
+
+// persistState saves the allocation series and suggestions if the store is
+// a StateStore. Caller must hold p.storeMu.
+func (p *Profiler) persistState(at time.Time) {
+	ss, ok := p.store.(StateStore)
+	if !ok {
+		return
+	}
+
+	p.rlockMu()
+	st := ProfilerState{
+		SavedAt:     at,
+		LiveSince:   p.liveSince,
+		Allocations: make([]PersistedAllocation, 0, len(p.allocs)),
+		Suggestions: append([]OptimizationSuggestion(nil), p.suggestions...),
+	}
+	for _, a := range p.allocs {
+		st.Allocations = append(st.Allocations, PersistedAllocation{
+			AllocationStat: *a,
+			CountVar:       a.countVar,
+			BytesVar:       a.bytesVar,
+			FirstSeen:      a.firstSeen,
+			LastSeen:       a.lastSeen,
+			Overflow:       a.overflow,
+		})
+	}
+	p.mu.RUnlock()
+
+	if err := ss.SaveState(st); err != nil {
+		p.logger.Warn("state save failed", "error", err)
+		return
+	}
+	p.stateSavedAt = at
+}
+
+// Close saves ProfilerState to a StateStore, then flushes and closes the
+// history store and the trace recorder. Snapshots sampled afterwards are kept in memory only. It is safe to call
+// Close more than once.
+func (p *Profiler) Close() error {
+	p.storeMu.Lock()
+	defer p.storeMu.Unlock()
+
+	var err error
+	if p.recorder != nil {
+		err = p.recorder.Close()
+	}
+	if p.store == nil {
+		return err
+	}
+	p.persistState(p.clock.Now().UTC())
+	if serr := p.store.Close(); err == nil {
+		err = serr
+	}
+	p.store = nil
+	return err
+}
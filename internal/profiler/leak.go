@@ -0,0 +1,373 @@
+package profiler
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// leakEvalInterval throttles leak detection in the sampling loop.
+const leakEvalInterval = 30 * time.Second
+
+// maxTrendPoints bounds the points a trend is fitted on, since Theil-Sen
+// looks at every pair of points.
+const maxTrendPoints = 240
+
+// LeakTrend is a robust linear fit of one series over history. Growth is the
+// Theil-Sen slope; Lower and Upper bound its 95% confidence interval, and
+// Confidence is the probability (0-1) of an upward trend according to the
+// Mann-Kendall test.
+type LeakTrend struct {
+	TypeName                string    `json:"type_name,omitempty"`
+	Tag                     string    `json:"tag,omitempty"`
+	From                    time.Time `json:"from"`
+	To                      time.Time `json:"to"`
+	Samples                 int       `json:"samples"`
+	BaselineBytes           float64   `json:"baseline_bytes"`
+	GrowthBytesPerHour      float64   `json:"growth_bytes_per_hour"`
+	GrowthLowerBytesPerHour float64   `json:"growth_lower_bytes_per_hour"`
+	GrowthUpperBytesPerHour float64   `json:"growth_upper_bytes_per_hour"`
+	Confidence              float64   `json:"confidence"`
+	Leaking                 bool      `json:"leaking"`
+}
+
+// LeakReport is the result of DetectLeaks. Heap fits the post-GC heap
+// baseline over the tier named by Tier; Retentions fit the retained bytes of
+// the series recorded in raw snapshots, fastest growing first.
+type LeakReport struct {
+	EvaluatedAt time.Time   `json:"evaluated_at"`
+	Tier        string      `json:"tier,omitempty"`
+	Heap        *LeakTrend  `json:"heap,omitempty"`
+	Retentions  []LeakTrend `json:"retentions"`
+}
+
+type trendPoint struct {
+	at time.Time
+	v  float64
+}
+
+// Leaks returns the latest leak report computed by the sampling loop.
+func (p *Profiler) Leaks() LeakReport {
+	p.rlockMu()
+	defer p.mu.RUnlock()
+
+	rep := p.leaks
+	rep.Retentions = append([]LeakTrend(nil), rep.Retentions...)
+	return rep
+}
+
+// maybeDetectLeaks runs DetectLeaks at most every leakEvalInterval.
+func (p *Profiler) maybeDetectLeaks(now time.Time) {
+	p.rlockMu()
+	last := p.leaks.EvaluatedAt
+	p.mu.RUnlock()
+
+	if !last.IsZero() && now.Sub(last) < leakEvalInterval {
+		return
+	}
+	p.DetectLeaks()
+}
+
+// DetectLeaks fits growth trends over the last LeakWindowSec of history,
+// ending at the latest snapshot, and keeps the result for Leaks and the
+// suggestions generated by the next sample. It also refits the heap trend
+// behind the OOM forecast, which does not depend on leak detection.
+func (p *Profiler) DetectLeaks() LeakReport {
+	rep, growth := p.detectLeaks()
+
+	p.lockMu()
+	p.leaks = rep
+	p.growth = growth
+	p.mu.Unlock()
+	return rep
+}
+
+func (p *Profiler) detectLeaks() (LeakReport, *LeakTrend) {
+	latest := p.LatestSnapshot()
+	window := time.Duration(p.cfg.LeakWindowSec) * time.Second
+	rep := LeakReport{EvaluatedAt: latest.Timestamp, Retentions: []LeakTrend{}}
+	if latest.Timestamp.IsZero() {
+		return rep, nil
+	}
+	// Without leak detection the heap is still fitted for the forecast.
+	heapWindow := window
+	if heapWindow <= 0 {
+		heapWindow = forecastWindow
+	}
+	from := latest.Timestamp.Add(-heapWindow)
+
+	p.rlockMu()
+	rawRetention := p.rawRetentionLocked()
+	p.mu.RUnlock()
+
+	// The heap baseline comes from rollups when the window outlives the raw
+	// ring; a bucket's minimum is then the closest thing to post-GC.
+	var h HistoryResult
+	if heapWindow <= rawRetention {
+		h = HistoryResult{Tier: TierRaw, Snapshots: p.SnapshotsRange(from, time.Time{}, 0)}
+	} else {
+		h = p.History(from, time.Time{}, 0, 0)
+	}
+	var heap []trendPoint
+	if h.Tier == TierRaw {
+		for i, s := range h.Snapshots {
+			// Live heap only changes at GC, so take one point per cycle.
+			if i > 0 && s.NumGC == h.Snapshots[i-1].NumGC {
+				continue
+			}
+			heap = append(heap, trendPoint{s.Timestamp, heapBaseline(float64(s.HeapLiveBytes), float64(s.HeapAllocBytes))})
+		}
+	} else {
+		for _, pt := range h.Rollups {
+			heap = append(heap, trendPoint{pt.Start, heapBaseline(pt.Fields["heap_live_bytes"].Min, pt.Fields["heap_alloc_bytes"].Min)})
+		}
+	}
+	// The forecast accepts a shorter span than leak detection, so it does
+	// not wait out a long leak window before predicting anything.
+	var growth *LeakTrend
+	if t, ok := p.fitLeakTrend(heap, min(heapWindow/4, forecastMinSpan)); ok {
+		growth = &t
+	}
+	if window <= 0 {
+		return rep, growth
+	}
+	rep.Tier = h.Tier
+	if growth != nil && heap[len(heap)-1].at.Sub(heap[0].at) >= window/4 {
+		t := *growth
+		rep.Heap = &t
+	}
+
+	// Per-series retention is only recorded in raw snapshots.
+	var snaps []ProfilerSnapshot
+	if h.Tier == TierRaw {
+		snaps = h.Snapshots
+	} else {
+		snaps = p.SnapshotsRange(from, time.Time{}, 0)
+	}
+	series := make(map[SeriesKey][]trendPoint)
+	for _, s := range snaps {
+		for _, r := range s.TopRetentions {
+			k := SeriesKey{r.TypeName, r.Tag}
+			series[k] = append(series[k], trendPoint{s.Timestamp, float64(r.RetainedBytes)})
+		}
+	}
+	for k, pts := range series {
+		if t, ok := p.fitLeakTrend(pts, min(window, rawRetention)/4); ok {
+			t.TypeName, t.Tag = k.TypeName, k.Tag
+			rep.Retentions = append(rep.Retentions, t)
+		}
+	}
+	sort.Slice(rep.Retentions, func(i, j int) bool {
+		a, b := &rep.Retentions[i], &rep.Retentions[j]
+		if a.GrowthBytesPerHour != b.GrowthBytesPerHour {
+			return a.GrowthBytesPerHour > b.GrowthBytesPerHour
+		}
+		if a.TypeName != b.TypeName {
+			return a.TypeName < b.TypeName
+		}
+		return a.Tag < b.Tag
+	})
+	return rep, growth
+}
+
+// heapBaseline prefers the live heap, which older snapshots may lack.
+func heapBaseline(live, alloc float64) float64 {
+	if live > 0 {
+		return live
+	}
+	return alloc
+}
+
+// fitLeakTrend fits pts, which must be in time order, if there are enough of
+// them over at least minSpan.
+func (p *Profiler) fitLeakTrend(pts []trendPoint, minSpan time.Duration) (LeakTrend, bool) {
+	if len(pts) < max(p.cfg.LeakMinSamples, 2) {
+		return LeakTrend{}, false
+	}
+	span := pts[len(pts)-1].at.Sub(pts[0].at)
+	if span <= 0 || span < minSpan {
+		return LeakTrend{}, false
+	}
+
+	pts = thinTrendPoints(pts)
+	fit := theilSen(pts)
+	from, to := pts[0].at, pts[len(pts)-1].at
+	t := LeakTrend{
+		From:                    from,
+		To:                      to,
+		Samples:                 len(pts),
+		BaselineBytes:           fit.intercept + fit.slope*to.Sub(from).Seconds(),
+		GrowthBytesPerHour:      fit.slope * 3600,
+		GrowthLowerBytesPerHour: fit.lower * 3600,
+		GrowthUpperBytesPerHour: fit.upper * 3600,
+		Confidence:              fit.confidence,
+	}
+	t.Leaking = t.GrowthBytesPerHour >= p.cfg.LeakMinGrowthBytesPerHour && t.Confidence >= p.cfg.LeakMinConfidence
+	return t, true
+}
+
+// thinTrendPoints reduces pts to maxTrendPoints buckets, keeping each
+// bucket's minimum: the floor is what a leak keeps raising.
+func thinTrendPoints(pts []trendPoint) []trendPoint {
+	if len(pts) <= maxTrendPoints {
+		return pts
+	}
+	out := make([]trendPoint, 0, maxTrendPoints)
+	for b := 0; b < maxTrendPoints; b++ {
+		lo, hi := b*len(pts)/maxTrendPoints, (b+1)*len(pts)/maxTrendPoints
+		best := pts[lo]
+		for _, pt := range pts[lo+1 : hi] {
+			if pt.v < best.v {
+				best = pt
+			}
+		}
+		out = append(out, best)
+	}
+	return out
+}
+
+// trendFit holds per-second slopes and the value at the first point.
+type trendFit struct {
+	slope      float64
+	lower      float64
+	upper      float64
+	intercept  float64
+	confidence float64
+}
+
+// theilSen fits a line through pts as the median of pairwise slopes, which
+// tolerates up to ~29% outliers. The 95% interval follows Sen (1968) and the
+// confidence is the one-sided Mann-Kendall probability of an upward trend.
+func theilSen(pts []trendPoint) trendFit {
+	n := len(pts)
+	slopes := make([]float64, 0, n*(n-1)/2)
+	s := 0
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			dv := pts[j].v - pts[i].v
+			switch {
+			case dv > 0:
+				s++
+			case dv < 0:
+				s--
+			}
+			if dt := pts[j].at.Sub(pts[i].at).Seconds(); dt > 0 {
+				slopes = append(slopes, dv/dt)
+			}
+		}
+	}
+	if len(slopes) == 0 {
+		return trendFit{confidence: 0.5}
+	}
+	sort.Float64s(slopes)
+
+	var fit trendFit
+	fit.slope = median(slopes)
+
+	// Standard deviation of the Mann-Kendall S statistic, ignoring ties.
+	nf := float64(n)
+	sd := math.Sqrt(nf * (nf - 1) * (2*nf + 5) / 18)
+
+	m := float64(len(slopes))
+	c := 1.96 * sd
+	lo := int(math.Floor((m - c) / 2))
+	hi := int(math.Ceil((m + c) / 2))
+	fit.lower = slopes[min(max(lo, 0), len(slopes)-1)]
+	fit.upper = slopes[min(max(hi, 0), len(slopes)-1)]
+
+	var z float64
+	switch {
+	case s > 0:
+		z = float64(s-1) / sd
+	case s < 0:
+		z = float64(s+1) / sd
+	}
+	fit.confidence = 0.5 * math.Erfc(-z/math.Sqrt2)
+
+	t0 := pts[0].at
+	offsets := make([]float64, n)
+	for i, pt := range pts {
+		offsets[i] = pt.v - fit.slope*pt.at.Sub(t0).Seconds()
+	}
+	sort.Float64s(offsets)
+	fit.intercept = median(offsets)
+	return fit
+}
+
+// median returns the median of sorted, which must not be empty.
+func median(sorted []float64) float64 {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// leakSuggestionsLocked turns leaking trends of the latest report into
+// suggestions. A trend is critical when even the lower bound of its growth
+// exceeds LeakMinGrowthBytesPerHour. Caller must hold p.mu.
+func (p *Profiler) leakSuggestionsLocked(now time.Time) []OptimizationSuggestion {
+	var out []OptimizationSuggestion
+	add := func(t *LeakTrend) {
+		if !t.Leaking {
+			return
+		}
+		severity := "warning"
+		if t.GrowthLowerBytesPerHour >= p.cfg.LeakMinGrowthBytesPerHour {
+			severity = "critical"
+		}
+		out = append(out, OptimizationSuggestion{
+			ID:        nextID("suggestion"),
+			Kind:      SuggestionLeak,
+			TypeName:  t.TypeName,
+			Tag:       t.Tag,
+			Severity:  severity,
+			Message:   buildLeakMessage(t),
+			CreatedAt: now,
+		})
+	}
+
+	if p.leaks.Heap != nil {
+		add(p.leaks.Heap)
+	}
+	for i := range p.leaks.Retentions {
+		add(&p.leaks.Retentions[i])
+	}
+	return out
+}
+
+func buildLeakMessage(t *LeakTrend) string {
+	b := strings.Builder{}
+	if t.TypeName == "" && t.Tag == "" {
+		b.WriteString("Post-GC heap baseline")
+	} else {
+		b.WriteString("Retained memory of ")
+		b.WriteString(t.TypeName)
+		if t.Tag != "" {
+			b.WriteString(" (tag=")
+			b.WriteString(t.Tag)
+			b.WriteString(")")
+		}
+	}
+	b.WriteString(" is growing ~")
+	b.WriteString(formatBytes(t.GrowthBytesPerHour))
+	b.WriteString("/h (95% interval ")
+	b.WriteString(formatBytes(t.GrowthLowerBytesPerHour))
+	b.WriteString(" to ")
+	b.WriteString(formatBytes(t.GrowthUpperBytesPerHour))
+	b.WriteString("/h, confidence ")
+	b.WriteString(formatFloat(t.Confidence*100, 1))
+	b.WriteString("%) over the last ")
+	b.WriteString(t.To.Sub(t.From).Round(time.Second).String())
+	b.WriteString(", now ~")
+	b.WriteString(formatBytes(t.BaselineBytes))
+	b.WriteString(".")
+
+	if t.TypeName == "" && t.Tag == "" {
+		b.WriteString(" This looks like a memory leak; compare heap profiles taken some time apart to find what keeps growing.")
+	} else {
+		b.WriteString(" Look for caches, maps, queues or goroutines that keep these objects alive without bound.")
+	}
+	return b.String()
+}
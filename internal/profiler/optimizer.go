@@ -1,14 +1,38 @@
 package profiler
 
 import (
+	"math"
 	"runtime"
 	"strings"
 	"time"
 )
 
+// Suggestion kinds reported in OptimizationSuggestion.Kind.
+const (
+	SuggestionRetention   = "retention"
+	SuggestionLeak        = "leak"
+	SuggestionNonGoMemory = "non_go_memory"
+	SuggestionGCTuning    = "gc_tuning"
+	SuggestionHeapIdle    = "heap_idle"
+	SuggestionAllocChurn  = "alloc_churn"
+)
+
 // generateSuggestionsLocked produces heuristic optimization suggestions based
-// on current retention stats and config thresholds. Caller must hold p.mu.
-func (p *Profiler) generateSuggestionsLocked(ms *runtime.MemStats, now time.Time) []OptimizationSuggestion {
+// on current retention stats, heap fragmentation, leak trends, container
+// memory, GC tuning advice and config thresholds. Caller must hold p.mu.
+func (p *Profiler) generateSuggestionsLocked(ms *memSample, now time.Time) []OptimizationSuggestion {
+	out := make([]OptimizationSuggestion, 0)
+	out = append(out, p.retentionSuggestionsLocked(ms, now)...)
+	out = append(out, p.fragmentationSuggestionsLocked(ms, now)...)
+	out = append(out, p.leakSuggestionsLocked(now)...)
+	out = append(out, p.nonGoSuggestionsLocked(ms, now)...)
+	out = append(out, p.gcTuningSuggestionsLocked()...)
+	return out
+}
+
+// retentionSuggestionsLocked flags series retaining more of the heap than
+// HighRetentionThresholdPercent. Caller must hold p.mu.
+func (p *Profiler) retentionSuggestionsLocked(ms *memSample, now time.Time) []OptimizationSuggestion {
 	out := make([]OptimizationSuggestion, 0)
 
 	threshold := p.cfg.HighRetentionThresholdPercent
@@ -31,6 +55,7 @@ func (p *Profiler) generateSuggestionsLocked(ms *runtime.MemStats, now time.Time
 
 		out = append(out, OptimizationSuggestion{
 			ID:        nextID("suggestion"),
+			Kind:      SuggestionRetention,
 			TypeName:  rs.TypeName,
 			Tag:       rs.Tag,
 			Severity:  severity,
@@ -42,7 +67,7 @@ func (p *Profiler) generateSuggestionsLocked(ms *runtime.MemStats, now time.Time
 	return out
 }
 
-func buildSuggestionMessage(rs *RetentionStat, ms *runtime.MemStats, threshold float64) string {
+func buildSuggestionMessage(rs *RetentionStat, ms *memSample, threshold float64) string {
 	base := strings.Builder{}
 	base.WriteString("High memory retention detected for ")
 	base.WriteString(rs.TypeName)
@@ -76,13 +101,91 @@ func buildSuggestionMessage(rs *RetentionStat, ms *runtime.MemStats, threshold f
 	}
 
 	// If heap is very large, add a hint.
-	if ms.HeapAlloc > 512*1024*1024 { // 512MB
+	if ms.heapAlloc > 512*1024*1024 { // 512MB
 		base.WriteString(" Overall heap is quite large; consider reducing retention to mitigate GC pressure.")
 	}
 
 	return base.String()
 }
 
+// fragmentationSuggestionsLocked flags free heap memory the runtime keeps
+// from the OS above HeapIdleThresholdPercent of the heap in use, and churn of
+// small objects above SmallObjectChurnPerSec. Caller must hold p.mu.
+func (p *Profiler) fragmentationSuggestionsLocked(ms *memSample, now time.Time) []OptimizationSuggestion {
+	var out []OptimizationSuggestion
+	frag := fragmentation(ms, p.sizeClasses)
+
+	idlePct := float64(0)
+	if ms.heapInuse > 0 {
+		idlePct = float64(frag.IdleNotReleasedBytes) / float64(ms.heapInuse) * 100
+	}
+	if t := p.cfg.HeapIdleThresholdPercent; t > 0 && frag.IdleNotReleasedBytes >= minIdleBytes && idlePct >= t {
+		b := strings.Builder{}
+		b.WriteString("The runtime holds ~")
+		b.WriteString(formatBytes(float64(frag.IdleNotReleasedBytes)))
+		b.WriteString(" of free heap that is not returned to the OS (")
+		b.WriteString(formatFloat(idlePct, 1))
+		b.WriteString("% of the ")
+		b.WriteString(formatBytes(float64(ms.heapInuse)))
+		b.WriteString(" heap in use).")
+		if unused := frag.UnusedInSpanBytes; unused*4 > ms.heapInuse {
+			b.WriteString(" Another ")
+			b.WriteString(formatBytes(float64(unused)))
+			b.WriteString(" of in-use spans holds no objects, a sign of mixed object lifetimes.")
+		}
+		b.WriteString(" Call debug.FreeOSMemory after large one-off bursts")
+		if ms.memLimit == 0 || ms.memLimit >= math.MaxInt64 {
+			b.WriteString(", or set GOMEMLIMIT so the scavenger returns memory above it.")
+		} else {
+			b.WriteString(", or lower GOMEMLIMIT (now ")
+			b.WriteString(formatBytes(float64(ms.memLimit)))
+			b.WriteString(") so the scavenger returns more of it.")
+		}
+		out = append(out, OptimizationSuggestion{
+			ID:        nextID("suggestion"),
+			Kind:      SuggestionHeapIdle,
+			Severity:  "warning",
+			Message:   b.String(),
+			CreatedAt: now,
+		})
+	}
+
+	if t := p.cfg.SmallObjectChurnPerSec; t > 0 && frag.SmallAllocsPerSec >= t {
+		var top SizeClassStat
+		for _, c := range p.sizeClasses {
+			if c.Size > 0 && c.Size <= smallObjectMaxBytes && c.AllocsPerSec > top.AllocsPerSec {
+				top = c
+			}
+		}
+		severity := "info"
+		cpu := ms.gcCPUFraction()
+		if cpu*100 > 2*p.cfg.GCTargetCPUPercent {
+			severity = "warning"
+		}
+
+		b := strings.Builder{}
+		b.WriteString("~")
+		b.WriteString(formatFloat(frag.SmallAllocsPerSec, 0))
+		b.WriteString(" objects of at most ")
+		b.WriteString(itoa(smallObjectMaxBytes))
+		b.WriteString(" B are allocated per second, most in the ")
+		b.WriteString(itoa(int64(top.Size)))
+		b.WriteString(" B size class (")
+		b.WriteString(formatFloat(top.AllocsPerSec, 0))
+		b.WriteString("/s); the GC uses ")
+		b.WriteString(formatFloat(cpu*100, 1))
+		b.WriteString("% of CPU. Reuse objects with sync.Pool, preallocate slices and maps, and avoid boxing values into interfaces and building strings in hot paths.")
+		out = append(out, OptimizationSuggestion{
+			ID:        nextID("suggestion"),
+			Kind:      SuggestionAllocChurn,
+			Severity:  severity,
+			Message:   b.String(),
+			CreatedAt: now,
+		})
+	}
+	return out
+}
+
 // formatFloat is a tiny helper to avoid pulling in fmt inside hot paths here.
 func formatFloat(v float64, decimals int) string {
 	// Simple fixed-point formatter for small decimal counts.
@@ -111,6 +214,17 @@ func formatFloat(v float64, decimals int) string {
 	return sign + itoa(intPart) + "." + padLeft(itoa(fracPart), decimals, '0')
 }
 
+// formatBytes renders n with a binary unit, e.g. "50.0 MiB".
+func formatBytes(n float64) string {
+	units := [...]string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for (n >= 1024 || n <= -1024) && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	return formatFloat(n, 1) + " " + units[i]
+}
+
 func itoa(n int64) string {
 	if n == 0 {
 		return "0"
@@ -145,5 +259,6 @@ func padLeft(s string, width int, ch rune) string {
 
 // GenerateSuggestionsTest exposes generateSuggestionsLocked for tests.
 func (p *Profiler) GenerateSuggestionsTest(ms *runtime.MemStats, now time.Time) []OptimizationSuggestion {
-	return p.generateSuggestionsLocked(ms, now)
+	s := memSampleFromMemStats(ms)
+	return p.generateSuggestionsLocked(&s, now)
 }
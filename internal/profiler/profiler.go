@@ -2,9 +2,10 @@ package profiler
 
 import (
 	"context"
-	"runtime"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
@@ -13,12 +14,40 @@ import (
 )
 
 // AllocationStat represents aggregated allocation info for a (type, tag) pair.
+// When sampling is enabled, AllocCount and TotalAllocBytes are unbiased
+// estimates and the *Error fields give their 95% confidence half-width. In
+// sketch accounting mode they are upper bounds instead, and the *Error fields
+// bound the overestimate.
 type AllocationStat struct {
 	TypeName          string `json:"type_name"`
 	Tag               string `json:"tag"`
 	AllocCount        uint64 `json:"alloc_count"`
 	TotalAllocBytes   uint64 `json:"total_alloc_bytes"`
 	AverageAllocBytes uint64 `json:"average_alloc_bytes"`
+
+	// SizeSource is one of "reflection", "sizer" or "registered".
+	SizeSource string `json:"size_source"`
+
+	// AccountingMode is "exact" or "sketch".
+	AccountingMode string `json:"accounting_mode"`
+
+	// Rates over RateWindow, the trailing window requested from
+	// TopAllocationsWindow (DefaultRateWindow otherwise).
+	RateWindow   string  `json:"rate_window"`
+	BytesPerSec  float64 `json:"bytes_per_sec"`
+	AllocsPerSec float64 `json:"allocs_per_sec"`
+
+	SampleRate           uint64 `json:"sample_rate"`
+	SampleMode           string `json:"sample_mode"`
+	SampledCount         uint64 `json:"sampled_count"`
+	AllocCountError      uint64 `json:"alloc_count_error"`
+	TotalAllocBytesError uint64 `json:"total_alloc_bytes_error"`
+
+	countVar  float64
+	bytesVar  float64
+	firstSeen time.Time
+	lastSeen  time.Time
+	overflow  bool
 }
 
 // containsIgnoreCase checks if s is in list, case-insensitive.
@@ -33,22 +62,25 @@ func containsIgnoreCase(list []string, s string) bool {
 
 // CaptureCount returns the total number of automatic profile captures performed.
 func (p *Profiler) CaptureCount() uint64 {
-	p.mu.RLock()
+	p.rlockMu()
 	defer p.mu.RUnlock()
 	return p.autoCaptureCount
 }
 
-// RetentionStat represents an estimate of how much heap a (type, tag) retains.
+// RetentionStat represents how much heap a (type, tag) retains. RetainedBytes
+// and LiveObjects only cover tracked objects that the GC has not reclaimed yet.
 type RetentionStat struct {
 	TypeName        string  `json:"type_name"`
 	Tag             string  `json:"tag"`
 	RetainedBytes   uint64  `json:"retained_bytes"`
+	LiveObjects     uint64  `json:"live_objects"`
 	RetainedPercent float64 `json:"retained_percent"`
 }
 
 // OptimizationSuggestion is a heuristic recommendation for improving memory.
 type OptimizationSuggestion struct {
 	ID        string    `json:"id"`
+	Kind      string    `json:"kind"` // SuggestionRetention, SuggestionLeak, ...
 	TypeName  string    `json:"type_name"`
 	Tag       string    `json:"tag"`
 	Severity  string    `json:"severity"` // "info", "warning", "critical"
@@ -61,6 +93,18 @@ type OptimizationSuggestion struct {
 type ProfilerSnapshot struct {
 	Timestamp time.Time `json:"timestamp"`
 
+	// SamplingMode is SamplingModeInterval or SamplingModeGC, for a sample
+	// taken right after a GC cycle.
+	SamplingMode string `json:"sampling_mode"`
+
+	// SamplingIntervalMs is the sampling interval in effect when the
+	// snapshot was taken; it varies with adaptive sampling.
+	SamplingIntervalMs int64 `json:"sampling_interval_ms"`
+
+	// SelfOverheadPercent is the profiler's own overhead, see
+	// SelfStats.OverheadPercent.
+	SelfOverheadPercent float64 `json:"self_overhead_percent"`
+
 	HeapAllocBytes  uint64 `json:"heap_alloc_bytes"`
 	HeapInuseBytes  uint64 `json:"heap_inuse_bytes"`
 	HeapIdleBytes   uint64 `json:"heap_idle_bytes"`
@@ -70,14 +114,55 @@ type ProfilerSnapshot struct {
 	NextGCBytes     uint64 `json:"next_gc_bytes"`
 	TotalAllocBytes uint64 `json:"total_alloc_bytes"`
 
+	// Fields below come from runtime/metrics.
+	HeapLiveBytes     uint64         `json:"heap_live_bytes"`
+	HeapObjects       uint64         `json:"heap_objects"`
+	StackBytes        uint64         `json:"stack_bytes"`
+	MSpanInuseBytes   uint64         `json:"mspan_inuse_bytes"`
+	MCacheInuseBytes  uint64         `json:"mcache_inuse_bytes"`
+	RuntimeTotalBytes uint64         `json:"runtime_total_bytes"`
+	Goroutines        uint64         `json:"goroutines"`
+	GOGCPercent       int64          `json:"gogc_percent"`
+	GOMemLimitBytes   uint64         `json:"gomemlimit_bytes"`
+	GCCPUSeconds      float64        `json:"gc_cpu_seconds"`
+	GCCPUFraction     float64        `json:"gc_cpu_fraction"`
+	GCPauses          LatencySummary `json:"gc_pauses"`
+	SchedLatency      LatencySummary `json:"sched_latency"`
+
+	// Fragmentation interprets the heap fields above.
+	Fragmentation FragmentationStats `json:"fragmentation"`
+
+	// AllocSeries reports the profiler's own allocation map size.
+	AllocSeries SeriesStats `json:"alloc_series"`
+
+	// Fields below come from procfs and the cgroup filesystem and are zero
+	// when unavailable. NonGoBytes is RSS minus memory the Go runtime holds
+	// from the OS (cgo, mmap, or runtime memory not yet released).
+	RSSBytes              uint64 `json:"rss_bytes"`
+	RSSAnonBytes          uint64 `json:"rss_anon_bytes"`
+	RSSFileBytes          uint64 `json:"rss_file_bytes"`
+	RSSShmemBytes         uint64 `json:"rss_shmem_bytes"`
+	PSSBytes              uint64 `json:"pss_bytes"`
+	SwapBytes             uint64 `json:"swap_bytes"`
+	NonGoBytes            uint64 `json:"non_go_bytes"`
+	CgroupVersion         int    `json:"cgroup_version"`
+	CgroupUsageBytes      uint64 `json:"cgroup_usage_bytes"`
+	CgroupWorkingSetBytes uint64 `json:"cgroup_working_set_bytes"`
+	CgroupLimitBytes      uint64 `json:"cgroup_limit_bytes"`
+	CgroupOOMEvents       uint64 `json:"cgroup_oom_events"`
+	CgroupOOMKills        uint64 `json:"cgroup_oom_kills"`
+
+	// Forecast predicts when memory reaches GOMEMLIMIT or the cgroup limit.
+	Forecast OOMForecast `json:"forecast"`
+
 	TopAllocations []AllocationStat `json:"top_allocations"`
 	TopRetentions  []RetentionStat  `json:"top_retentions"`
 }
 
 // Profiler is the central component of this service. It:
-//   - periodically samples runtime.MemStats
+//   - periodically samples runtime/metrics
 //   - aggregates allocation stats via TrackAllocation()
-//   - estimates retention per (type, tag)
+//   - tracks live (not yet collected) objects per (type, tag)
 //   - produces suggestions based on heuristics
 //   - stores a bounded history of snapshots
 type Profiler struct {
@@ -87,13 +172,82 @@ type Profiler struct {
 	mu sync.RWMutex
 
 	// history is a fixed-size ring buffer.
-	history     []ProfilerSnapshot
-	histStart   int
-	histCount   int
+	history   []ProfilerSnapshot
+	histStart int
+	histCount int
+	tiers     []*rollupTier
+
+	// store persists snapshots when configured; storeMu serializes appends
+	// with Close so disk I/O stays outside mu. stateSavedAt is when
+	// ProfilerState was last saved, guarded by storeMu.
+	storeMu      sync.Mutex
+	store        HistoryStore
+	stateSavedAt time.Time
+
 	allocs      map[string]*AllocationStat
+	live        map[string]liveCount
+	rates       map[string]*rateWindow
 	retentions  map[string]*RetentionStat
 	suggestions []OptimizationSuggestion
 
+	// liveSince is when allocs started filling; series seen only in
+	// persisted history before it are unknown to the live maps.
+	liveSince time.Time
+
+	// sizeClasses are the size class stats of the latest sample.
+	sizeClasses   []SizeClassStat
+	sizeClassesAt time.Time
+
+	// leaks is the latest leak detection result and growth the heap trend
+	// the OOM forecast extrapolates; see DetectLeaks.
+	leaks  LeakReport
+	growth *LeakTrend
+
+	// gcTuning is the latest GC tuning advice; see AdviseGCTuning.
+	gcTuning GCTuningReport
+
+	// ctlMu serializes GC controller steps with its kill switch and guards
+	// ctl, so runtime settings change outside mu.
+	ctlMu sync.Mutex
+	ctl   gcController
+
+	// shards receive TrackAllocation updates without taking mu; they are
+	// merged into allocs on every sample and read.
+	shards  []allocShard
+	sampler allocSampler
+
+	// series registers admitted keys for the MaxAllocSeries limit.
+	series        sync.Map // key -> struct{}
+	seriesCount   atomic.Int64
+	foldedCalls   atomic.Uint64
+	seriesEvicted atomic.Uint64
+
+	// sketch replaces shards and allocs in sketch accounting mode.
+	sketch *allocSketch
+
+	deep deepSizer
+
+	// reader and container are only used by sampleOnce, which sampleMu
+	// serializes; memStats replaces reader when set.
+	sampleMu  sync.Mutex
+	reader    *metricsReader
+	container *containerReader
+	memStats  MemStatsSource
+	clock     Clock
+
+	// recorder, if set, receives every raw sample; traceAllocs collects the
+	// allocation deltas merged for the next record. Guarded by mu.
+	recorder    *TraceRecorder
+	traceAllocs map[string]*TraceAlloc
+
+	// interval is the sampling interval in effect, in nanoseconds; adapt
+	// moves it with adaptive sampling and is only used by sampleOnce.
+	interval atomic.Int64
+	adapt    adaptiveSampler
+
+	// self accounts the profiler's own costs; see SelfStats.
+	self selfCounters
+
 	lastHeapAlloc uint64
 	lastSampleAt  time.Time
 
@@ -105,26 +259,93 @@ type Profiler struct {
 }
 
 // NewProfiler constructs a new Profiler instance. It does not start sampling
-// until Start is invoked.
+// until Start is invoked. With a file history store configured, persisted
+// history is replayed; if the store cannot be opened, history stays in memory.
 func NewProfiler(cfg config.ProfilerConfig, logger logging.Logger) *Profiler {
 	if logger == nil {
 		logger = logging.Noop()
 	}
 
+	store, err := openHistoryStore(cfg)
+	if err != nil {
+		logger.Warn("history store unavailable, keeping history in memory", "error", err)
+	}
+	recorder, err := openTraceRecorder(cfg)
+	if err != nil {
+		logger.Warn("trace recorder unavailable, not recording", "error", err)
+	}
+	return NewProfilerWithOptions(cfg, logger, Options{Store: store, Recorder: recorder})
+}
+
+// NewProfilerWithStore is like NewProfiler but persists history to store,
+// which may be nil, and replays it before returning.
+func NewProfilerWithStore(cfg config.ProfilerConfig, logger logging.Logger, store HistoryStore) *Profiler {
+	return NewProfilerWithOptions(cfg, logger, Options{Store: store})
+}
+
+// Options configures NewProfilerWithOptions. Zero fields select the
+// defaults: no history store, the system clock, runtime/metrics and no trace
+// recording. The profiler closes Store and Recorder in Close.
+type Options struct {
+	Store    HistoryStore
+	Clock    Clock
+	MemStats MemStatsSource
+	Recorder *TraceRecorder
+}
+
+// NewProfilerWithOptions is like NewProfilerWithStore but also lets callers
+// supply the clock and memory statistics, e.g. a ManualClock and a scripted
+// heap trace for deterministic tests.
+func NewProfilerWithOptions(cfg config.ProfilerConfig, logger logging.Logger, opts Options) *Profiler {
+	if logger == nil {
+		logger = logging.Noop()
+	}
+	if opts.Clock == nil {
+		opts.Clock = SystemClock()
+	}
+
+	var sketch *allocSketch
+	if cfg.AllocAccountingMode == AccountingSketch && cfg.SketchCapacity > 0 {
+		sketch = newAllocSketch(cfg.SketchCapacity)
+	}
+
 	var hist []ProfilerSnapshot
 	if cfg.MaxHistorySamples > 0 {
 		hist = make([]ProfilerSnapshot, cfg.MaxHistorySamples)
 	}
-	return &Profiler{
+	p := &Profiler{
 		cfg:         cfg,
 		logger:      logger.With("component", "profiler"),
 		history:     hist,
 		histStart:   0,
 		histCount:   0,
+		tiers:       newRollupTiers(),
 		allocs:      make(map[string]*AllocationStat),
+		live:        make(map[string]liveCount),
+		rates:       make(map[string]*rateWindow),
+		shards:      newAllocShards(),
+		sketch:      sketch,
+		reader:      newMetricsReader(),
+		sampler:     newAllocSampler(cfg),
+		deep: deepSizer{
+			enabled:  cfg.DeepSizeEnabled,
+			maxNodes: cfg.DeepSizeMaxNodes,
+			maxBytes: uint64(max(cfg.DeepSizeMaxBytes, 0)),
+		},
 		retentions:  make(map[string]*RetentionStat),
 		suggestions: make([]OptimizationSuggestion, 0),
+		store:       opts.Store,
+		container:   newContainerReader(cfg.CgroupRoot, cfg.ProcRoot),
+		clock:       opts.Clock,
+		liveSince:   opts.Clock.Now().UTC(),
+		memStats:    opts.MemStats,
+		recorder:    opts.Recorder,
+		traceAllocs: make(map[string]*TraceAlloc),
 	}
+	p.interval.Store(int64(time.Duration(cfg.SamplingIntervalMs) * time.Millisecond))
+	p.self.windowStart = time.Now()
+	p.replayHistory()
+	return p
 }
 
 // Start launches the sampling loop in a background goroutine. It is safe to
@@ -134,15 +355,21 @@ func NewProfiler(cfg config.ProfilerConfig, logger logging.Logger) *Profiler {
 func (p *Profiler) Start(ctx context.Context) {
 	p.startOnce.Do(func() {
 		p.logger.Info("profiler: starting sampling loop",
-			"sampling_interval_ms", p.cfg.SamplingIntervalMs)
+			"sampling_interval_ms", p.cfg.SamplingIntervalMs,
+			"sampling_mode", p.cfg.SamplingMode,
+			"adaptive_sampling", p.cfg.AdaptiveSamplingEnabled)
 
 		go p.runSamplingLoop(ctx)
 	})
 }
 
 func (p *Profiler) runSamplingLoop(ctx context.Context) {
-	interval := time.Duration(p.cfg.SamplingIntervalMs) * time.Millisecond
-	ticker := time.NewTicker(interval)
+	if p.cfg.SamplingMode == SamplingModeGC {
+		p.runGCSamplingLoop(ctx)
+		return
+	}
+	interval := p.SamplingInterval()
+	ticker := p.clock.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
@@ -150,31 +377,90 @@ func (p *Profiler) runSamplingLoop(ctx context.Context) {
 		case <-ctx.Done():
 			p.logger.Info("profiler: stopping sampling loop", "reason", "context_cancelled")
 			return
-		case <-ticker.C:
-			p.sampleOnce()
+		case <-ticker.C():
+			p.sampleOnce(SamplingModeInterval)
+			if next := p.SamplingInterval(); next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
 		}
 	}
 }
 
-// sampleOnce reads runtime.MemStats and updates internal state (history,
-// retention, suggestions).
-func (p *Profiler) sampleOnce() {
-	var ms runtime.MemStats
-	runtime.ReadMemStats(&ms)
+// sampleOnce reads runtime/metrics, updates internal state (history,
+// retention, suggestions), persists the new snapshot and adapts the sampling
+// interval to its cost, which is accounted in SelfStats. mode is the
+// sampling mode that triggered the sample.
+func (p *Profiler) sampleOnce(mode string) ProfilerSnapshot {
+	p.sampleMu.Lock()
+	defer p.sampleMu.Unlock()
+
+	start := time.Now()
+	ms := p.readMemSample()
+	ms.mode = mode
+	now := p.clock.Now().UTC()
+	ms.container = p.container.read(now)
+	return p.processSample(&ms, now, start)
+}
 
-	now := time.Now().UTC()
+// processSample folds ms, taken at now, into the profiler's state and runs
+// the per-sample analyses. start is when taking the sample began. Caller
+// must hold p.sampleMu.
+func (p *Profiler) processSample(ms *memSample, now, start time.Time) ProfilerSnapshot {
+	snap, rec := p.collectSample(ms, now)
+	p.maybeDetectLeaks(snap.Timestamp)
+	p.maybeAdviseGCTuning(snap.Timestamp)
+	p.AdjustGC()
+	p.persistSnapshot(&snap)
+	if rec != nil {
+		p.recordTrace(rec)
+	}
+
+	cost := time.Since(start)
+	p.self.recordSample(cost, time.Now())
+	p.adaptInterval(&snap, cost)
+	return snap
+}
 
-	p.mu.Lock()
+// SampleNow takes one sample immediately, in addition to those of the
+// sampling loop, and returns its snapshot. With a ManualClock and a scripted
+// MemStatsSource it drives the profiler deterministically without Start.
+func (p *Profiler) SampleNow() ProfilerSnapshot {
+	return p.sampleOnce(SamplingModeInterval)
+}
+
+// collectSample updates state from ms and returns the new snapshot and, when
+// recording, its trace record.
+func (p *Profiler) collectSample(ms *memSample, now time.Time) (ProfilerSnapshot, *TraceRecord) {
+	p.lockMu()
 	defer p.mu.Unlock()
 
+	// Fold pending TrackAllocation updates into p.allocs. A replayed sample
+	// brings its own live totals.
+	p.mergeAllocsLocked(now)
+	if ms.replay != nil {
+		for _, a := range ms.replay.Allocs {
+			if a.LiveObjects > 0 || a.LiveBytes > 0 {
+				p.live[a.TypeName+"|"+a.Tag] = liveCount{bytes: a.LiveBytes, objects: a.LiveObjects}
+			}
+		}
+	}
+	var rec *TraceRecord
+	if p.recorder != nil {
+		rec = p.traceRecordLocked(ms, now)
+	}
+	// Keep the map within MaxAllocSeries.
+	p.evictColdSeriesLocked()
+
 	// Update retention estimates based on latest heap.
-	p.updateRetentionsLocked(&ms)
+	p.updateRetentionsLocked(ms)
+	p.updateSizeClassesLocked(ms, now)
 
 	// Generate suggestions heuristically.
-	p.suggestions = p.generateSuggestionsLocked(&ms, now)
+	p.suggestions = p.generateSuggestionsLocked(ms, now)
 
 	// Maintain snapshot history.
-	snap := p.buildSnapshotLocked(&ms, now)
+	snap := p.buildSnapshotLocked(ms, now)
 	p.appendSnapshotLocked(snap)
 
 	// Background: evaluate alerts and auto-capture heap profile if enabled.
@@ -211,24 +497,32 @@ func (p *Profiler) sampleOnce() {
 		}
 	}
 
-	p.lastHeapAlloc = ms.HeapAlloc
+	p.lastHeapAlloc = ms.heapAlloc
 	p.lastSampleAt = now
+	return snap, rec
 }
 
 // TrackAllocation should be called by instrumented application code to
-// attribute allocations to semantic tags. It is concurrency-safe and designed
-// to be reasonably cheap, but should still be used judiciously in hot paths.
+// attribute allocations to semantic tags. It is concurrency-safe and does not
+// take the profiler lock; updates go to sharded atomic counters.
+//
+// Pointers, slices, maps and channels are additionally watched until the GC
+// reclaims them, so retention reflects objects that are still alive. Values
+// passed by copy, such as structs and strings, only count towards allocation
+// totals and never show up as retained. Live tracking is not keyed by
+// address: tracking the same pointer twice counts it as two live objects
+// until the GC reclaims it.
 func (p *Profiler) TrackAllocation(obj any, tag string) {
 	if obj == nil {
 		return
 	}
-	trackAllocation(p, obj, tag)
+	p.timedTrackAllocation(obj, tag)
 }
 
 // LatestSnapshot returns the most recent snapshot, or a zero-value snapshot
 // if none exist yet.
 func (p *Profiler) LatestSnapshot() ProfilerSnapshot {
-	p.mu.RLock()
+	p.rlockMu()
 	defer p.mu.RUnlock()
 
 	if len(p.history) == 0 || p.histCount == 0 {
@@ -243,38 +537,77 @@ func (p *Profiler) LatestSnapshot() ProfilerSnapshot {
 // all snapshots are returned. The returned slice is a copy and safe for
 // callers to modify.
 func (p *Profiler) Snapshots(limit int) []ProfilerSnapshot {
-	p.mu.RLock()
+	return p.SnapshotsRange(time.Time{}, time.Time{}, limit)
+}
+
+// SnapshotsRange is like Snapshots but only considers snapshots taken in
+// [since, until]. A zero since or until leaves that end open. With limit > 0,
+// the most recent limit snapshots in the range are returned.
+func (p *Profiler) SnapshotsRange(since, until time.Time, limit int) []ProfilerSnapshot {
+	p.rlockMu()
 	defer p.mu.RUnlock()
 
 	if len(p.history) == 0 || p.histCount == 0 {
 		return nil
 	}
-	n := p.histCount
+
+	// History is ordered by time, so the range is a contiguous run.
+	size := len(p.history)
+	at := func(i int) *ProfilerSnapshot { return &p.history[(p.histStart+i)%size] }
+	lo := sort.Search(p.histCount, func(i int) bool {
+		return since.IsZero() || !at(i).Timestamp.Before(since)
+	})
+	hi := sort.Search(p.histCount, func(i int) bool {
+		return !until.IsZero() && at(i).Timestamp.After(until)
+	})
+	n := hi - lo
+	if n <= 0 {
+		return nil
+	}
 	if limit <= 0 || limit > n {
 		limit = n
 	}
 	out := make([]ProfilerSnapshot, limit)
-	size := len(p.history)
-	start := (p.histStart + (n - limit)) % size
 	for i := 0; i < limit; i++ {
-		out[i] = p.history[(start+i)%size]
+		out[i] = *at(hi - limit + i)
 	}
 	return out
 }
 
 // TopAllocations returns the top-N allocation stats based on TotalAllocBytes.
 // If limit <= 0, all entries are returned (bounded by internal map size).
+// Pending updates are merged first so results include every tracked call.
 func (p *Profiler) TopAllocations(limit int) []AllocationStat {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
+	return p.TopAllocationsWindow(limit, DefaultRateWindow, SortByBytes)
+}
+
+// TopAllocationsWindow is like TopAllocations but reports per-second rates
+// over the trailing window (capped at MaxRateWindow) and orders results by
+// sortBy, one of SortByBytes, SortByCount, SortByBytesRate or
+// SortByAllocsRate.
+func (p *Profiler) TopAllocationsWindow(limit int, window time.Duration, sortBy string) []AllocationStat {
+	now := p.clock.Now().UTC()
+
+	p.lockMu()
+	defer p.mu.Unlock()
+
+	p.mergeAllocsLocked(now)
+	return p.topAllocationsLocked(limit, window, sortBy, now)
+}
 
-	return p.topAllocationsLocked(limit)
+// AccountingMode reports whether allocations are aggregated exactly or with
+// the bounded-memory sketch.
+func (p *Profiler) AccountingMode() string {
+	if p.sketch != nil {
+		return AccountingSketch
+	}
+	return AccountingExact
 }
 
 // TopRetentions returns the top-N retention stats based on RetainedBytes.
 // If limit <= 0, all entries are returned.
 func (p *Profiler) TopRetentions(limit int) []RetentionStat {
-	p.mu.RLock()
+	p.rlockMu()
 	defer p.mu.RUnlock()
 
 	return p.topRetentionsLocked(limit)
@@ -283,7 +616,7 @@ func (p *Profiler) TopRetentions(limit int) []RetentionStat {
 // Suggestions returns the current list of optimization suggestions.
 // The slice is a copy and safe for callers to modify.
 func (p *Profiler) Suggestions() []OptimizationSuggestion {
-	p.mu.RLock()
+	p.rlockMu()
 	defer p.mu.RUnlock()
 
 	out := make([]OptimizationSuggestion, len(p.suggestions))
@@ -294,7 +627,7 @@ func (p *Profiler) Suggestions() []OptimizationSuggestion {
 // LastSampleTime returns the time of the last successful sample, or zero
 // if sampling has never occurred.
 func (p *Profiler) LastSampleTime() time.Time {
-	p.mu.RLock()
+	p.rlockMu()
 	defer p.mu.RUnlock()
 	return p.lastSampleAt
 }
@@ -0,0 +1,237 @@
+package profiler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Aggregations accepted by Query, besides percentiles written as "p<N>"
+// (e.g. "p95", "p99.9").
+const (
+	AggMin   = "min"
+	AggMax   = "max"
+	AggAvg   = "avg"
+	AggDelta = "delta"
+	AggRate  = "rate"
+)
+
+// ErrInvalidQuery is wrapped by errors caused by bad Query parameters.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Query aggregates one numeric snapshot field over [From, To]. With Step > 0
+// the range is also split into Step-aligned buckets.
+type Query struct {
+	Field string
+	Agg   string
+	From  time.Time
+	To    time.Time
+	Step  time.Duration
+}
+
+// QueryPoint is the aggregate of one bucket.
+type QueryPoint struct {
+	Start   time.Time `json:"start"`
+	Value   float64   `json:"value"`
+	Samples int       `json:"samples"`
+}
+
+// QueryResult holds the aggregate over the whole range and, when a step was
+// given, per-bucket values. Tier names the history tier the data came from.
+type QueryResult struct {
+	Field   string       `json:"field"`
+	Agg     string       `json:"agg"`
+	Tier    string       `json:"tier"`
+	Step    string       `json:"step,omitempty"`
+	Value   float64      `json:"value"`
+	Samples int          `json:"samples"`
+	Points  []QueryPoint `json:"points,omitempty"`
+}
+
+// QueryFields lists the field names accepted by Query.
+func QueryFields() []string {
+	names := make([]string, len(snapshotFields))
+	for i, f := range snapshotFields {
+		names[i] = f.name
+	}
+	return names
+}
+
+// querySample is one observation: a raw snapshot value, or a rollup whose
+// min/max/avg/last are kept so aggregates stay as exact as the tier allows.
+type querySample struct {
+	at   time.Time
+	n    int
+	min  float64
+	max  float64
+	avg  float64
+	last float64
+}
+
+// Query evaluates q over the history tier that fits the range and step, the
+// same way History picks one. On rollup tiers, percentiles are taken over
+// bucket averages and delta/rate over bucket last values, so they are
+// approximate.
+func (p *Profiler) Query(q Query) (QueryResult, error) {
+	idx := -1
+	for i, f := range snapshotFields {
+		if f.name == q.Field {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return QueryResult{}, fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, q.Field)
+	}
+	agg, err := parseAgg(q.Agg)
+	if err != nil {
+		return QueryResult{}, err
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && q.To.Before(q.From) {
+		return QueryResult{}, fmt.Errorf("%w: to is before from", ErrInvalidQuery)
+	}
+	if q.Step < 0 {
+		return QueryResult{}, fmt.Errorf("%w: negative step", ErrInvalidQuery)
+	}
+
+	h := p.History(q.From, q.To, q.Step, 0)
+
+	var samples []querySample
+	if h.Tier == TierRaw {
+		get := snapshotFields[idx].get
+		samples = make([]querySample, len(h.Snapshots))
+		for i := range h.Snapshots {
+			v := get(&h.Snapshots[i])
+			samples[i] = querySample{at: h.Snapshots[i].Timestamp, n: 1, min: v, max: v, avg: v, last: v}
+		}
+	} else {
+		samples = make([]querySample, len(h.Rollups))
+		for i, pt := range h.Rollups {
+			r := pt.Fields[q.Field]
+			samples[i] = querySample{at: pt.Start, n: pt.Samples, min: r.Min, max: r.Max, avg: r.Avg, last: r.Last}
+		}
+	}
+
+	res := QueryResult{Field: q.Field, Agg: q.Agg, Tier: h.Tier}
+	res.Value, res.Samples = agg(samples)
+
+	if q.Step > 0 {
+		res.Step = q.Step.String()
+		for start := 0; start < len(samples); {
+			bucket := samples[start].at.Truncate(q.Step)
+			end := start + 1
+			for end < len(samples) && samples[end].at.Truncate(q.Step).Equal(bucket) {
+				end++
+			}
+			v, n := agg(samples[start:end])
+			res.Points = append(res.Points, QueryPoint{Start: bucket, Value: v, Samples: n})
+			start = end
+		}
+	}
+	return res, nil
+}
+
+type aggFunc func([]querySample) (float64, int)
+
+func parseAgg(name string) (aggFunc, error) {
+	switch name {
+	case AggMin:
+		return aggregate(func(s []querySample) float64 {
+			v := math.Inf(1)
+			for _, x := range s {
+				v = min(v, x.min)
+			}
+			return v
+		}), nil
+	case AggMax:
+		return aggregate(func(s []querySample) float64 {
+			v := math.Inf(-1)
+			for _, x := range s {
+				v = max(v, x.max)
+			}
+			return v
+		}), nil
+	case AggAvg:
+		return aggregate(func(s []querySample) float64 {
+			sum, n := 0.0, 0
+			for _, x := range s {
+				sum += x.avg * float64(x.n)
+				n += x.n
+			}
+			return sum / float64(n)
+		}), nil
+	case AggDelta:
+		return aggregate(func(s []querySample) float64 {
+			return s[len(s)-1].last - s[0].last
+		}), nil
+	case AggRate:
+		return aggregate(func(s []querySample) float64 {
+			secs := s[len(s)-1].at.Sub(s[0].at).Seconds()
+			if secs <= 0 {
+				return 0
+			}
+			return (s[len(s)-1].last - s[0].last) / secs
+		}), nil
+	}
+
+	if rest, ok := strings.CutPrefix(name, "p"); ok {
+		q, err := strconv.ParseFloat(rest, 64)
+		if err == nil && q > 0 && q <= 100 {
+			return aggregate(func(s []querySample) float64 {
+				return percentile(s, q/100)
+			}), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: agg must be one of [min, max, avg, delta, rate, p<N>] (got %q)", ErrInvalidQuery, name)
+}
+
+// aggregate wraps fn so empty inputs yield 0 and the sample count is
+// reported alongside.
+func aggregate(fn func([]querySample) float64) aggFunc {
+	return func(s []querySample) (float64, int) {
+		n := 0
+		for _, x := range s {
+			n += x.n
+		}
+		if len(s) == 0 || n == 0 {
+			return 0, 0
+		}
+		return fn(s), n
+	}
+}
+
+// percentile returns the q-quantile (0 < q <= 1) of sample averages,
+// weighting each by its sample count and interpolating linearly.
+func percentile(s []querySample, q float64) float64 {
+	sorted := make([]querySample, len(s))
+	copy(sorted, s)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].avg < sorted[j].avg })
+
+	total := 0
+	for _, x := range sorted {
+		total += x.n
+	}
+	// valueAt returns the value at a 0-based position in the sorted
+	// sequence where each sample is repeated n times.
+	valueAt := func(pos int) float64 {
+		for _, x := range sorted {
+			if pos < x.n {
+				return x.avg
+			}
+			pos -= x.n
+		}
+		return sorted[len(sorted)-1].avg
+	}
+
+	rank := q * float64(total-1)
+	lo := int(rank)
+	v := valueAt(lo)
+	if frac := rank - float64(lo); frac > 0 && lo+1 < total {
+		v += frac * (valueAt(lo+1) - v)
+	}
+	return v
+}
@@ -0,0 +1,117 @@
+package profiler
+
+import (
+	"time"
+)
+
+const (
+	// rateBucketWidth is the resolution of the sliding windows.
+	rateBucketWidth = 15 * time.Second
+	// rateBuckets covers MaxRateWindow.
+	rateBuckets = 60
+
+	// MaxRateWindow is the longest window TopAllocationsWindow accepts.
+	MaxRateWindow = rateBucketWidth * rateBuckets
+	// DefaultRateWindow is used when no window is requested.
+	DefaultRateWindow = time.Minute
+)
+
+// Orderings accepted by TopAllocationsWindow.
+const (
+	SortByBytes      = "bytes"
+	SortByCount      = "count"
+	SortByBytesRate  = "bytes_rate"
+	SortByAllocsRate = "allocs_rate"
+)
+
+// ValidAllocationSort reports whether s is a known ordering.
+func ValidAllocationSort(s string) bool {
+	switch s {
+	case SortByBytes, SortByCount, SortByBytesRate, SortByAllocsRate:
+		return true
+	}
+	return false
+}
+
+// rateWindow is a ring of fixed-width time buckets holding allocation counts
+// and bytes for one (type, tag). Rates over any window up to MaxRateWindow
+// are derived by summing the newest buckets.
+type rateWindow struct {
+	counts [rateBuckets]uint64
+	bytes  [rateBuckets]uint64
+	head   int64     // absolute bucket number of the newest bucket
+	since  time.Time // first observation, bounds the effective window
+}
+
+func bucketOf(t time.Time) int64 {
+	return t.UnixNano() / int64(rateBucketWidth)
+}
+
+// advance moves head forward to now, zeroing buckets that were skipped.
+func (w *rateWindow) advance(now time.Time) {
+	b := bucketOf(now)
+	if b <= w.head {
+		return
+	}
+	if b-w.head >= rateBuckets {
+		w.counts = [rateBuckets]uint64{}
+		w.bytes = [rateBuckets]uint64{}
+	} else {
+		for i := w.head + 1; i <= b; i++ {
+			idx := i % rateBuckets
+			w.counts[idx] = 0
+			w.bytes[idx] = 0
+		}
+	}
+	w.head = b
+}
+
+func (w *rateWindow) add(now time.Time, count, bytes uint64) {
+	if w.since.IsZero() {
+		w.since = now
+		w.head = bucketOf(now)
+	}
+	w.advance(now)
+	idx := w.head % rateBuckets
+	w.counts[idx] += count
+	w.bytes[idx] += bytes
+}
+
+// rate returns bytes and allocations per second over the trailing window.
+// The window is rounded up to whole buckets and shortened to the entry's
+// lifetime so new entries are not diluted. It does not modify w, so a read
+// lock on p.mu is enough; buckets newer than head count as empty.
+func (w *rateWindow) rate(now time.Time, window time.Duration) (bytesPerSec, allocsPerSec float64) {
+	if w == nil || w.since.IsZero() {
+		return 0, 0
+	}
+	head := max(bucketOf(now), w.head)
+
+	k := int64((window + rateBucketWidth - 1) / rateBucketWidth)
+	if k < 1 {
+		k = 1
+	}
+	if k > rateBuckets {
+		k = rateBuckets
+	}
+
+	var count, bytes uint64
+	for i := max(head-k+1, w.head-rateBuckets+1); i <= w.head; i++ {
+		idx := i % rateBuckets
+		count += w.counts[idx]
+		bytes += w.bytes[idx]
+	}
+
+	headStart := time.Unix(0, head*int64(rateBucketWidth))
+	span := time.Duration(k-1)*rateBucketWidth + now.Sub(headStart)
+	if life := now.Sub(w.since); life < span {
+		span = life
+	}
+	if span < time.Second {
+		// Avoid inflating rates for entries seen only moments ago.
+		span = time.Second
+	}
+
+	secs := span.Seconds()
+	return float64(bytes) / secs, float64(count) / secs
+}
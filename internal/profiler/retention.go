@@ -1,13 +1,95 @@
 package profiler
 
 import (
+	"reflect"
 	"runtime"
+	"sync/atomic"
+	"unsafe"
 )
 
-// updateRetentionsLocked recomputes retention estimates based on the current
-// allocation statistics and heap stats. Caller must hold p.mu.
-func (p *Profiler) updateRetentionsLocked(ms *runtime.MemStats) {
-	totalHeap := ms.HeapAlloc
+// liveStat counts objects attributed to a (type, tag) pair within one shard
+// that have not yet been reclaimed by the GC. Counters are decremented from
+// runtime cleanups, which run on their own goroutine, so they are atomic
+// rather than guarded by p.mu.
+type liveStat struct {
+	objects atomic.Int64
+	bytes   atomic.Int64
+}
+
+// liveCount is a liveStat summed across shards at merge time.
+type liveCount struct {
+	bytes   uint64
+	objects uint64
+}
+
+// liveRelease is the argument handed to a cleanup. It must not reference the
+// tracked object itself, otherwise the object would never become unreachable.
+type liveRelease struct {
+	stat    *liveStat
+	objects int64
+	bytes   int64
+}
+
+// watchLive registers obj with the runtime so that the objects and bytes it
+// stands for (more than one when sampling) are subtracted from stat once the
+// GC reclaims it. Only reference kinds
+// (pointers, slices, maps, channels) have an observable lifetime; values
+// passed by copy are not tracked since the boxed copy is garbage as soon as
+// TrackAllocation returns.
+//
+// Objects are not deduplicated by address; each call raises the counters and
+// attaches its own cleanup, so a pointer tracked twice is live twice. An
+// address index would need a lock on the TrackAllocation hot path.
+//
+// Package-level variables never get a cleanup run and therefore stay live,
+// which matches reality. Memory the GC does not manage at all, such as mmap'd
+// buffers or C memory behind unsafe.Slice, cannot be watched and is not
+// counted as live. Tiny pointer-free objects may be batched by the allocator
+// and released late.
+func watchLive(obj any, typ reflect.Type, stat *liveStat, objects, bytes uint64) {
+	v := reflect.ValueOf(obj)
+
+	var ptr unsafe.Pointer
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Chan:
+		if v.IsNil() {
+			return
+		}
+		ptr = v.UnsafePointer()
+	case reflect.Slice:
+		if v.Cap() == 0 {
+			return
+		}
+		ptr = v.UnsafePointer()
+	default:
+		return
+	}
+
+	r := liveRelease{stat: stat, objects: int64(objects), bytes: int64(bytes)}
+	if !attachCleanup(ptr, r) {
+		return
+	}
+	// obj is reachable until KeepAlive, so the cleanup cannot run before the
+	// counters are raised.
+	stat.objects.Add(r.objects)
+	stat.bytes.Add(r.bytes)
+	runtime.KeepAlive(obj)
+}
+
+func releaseLive(r liveRelease) {
+	r.stat.objects.Add(-r.objects)
+	r.stat.bytes.Add(-r.bytes)
+}
+
+// updateRetentionsLocked recomputes retention estimates from the live object
+// counters and heap stats. Samples taken right after a GC measure retention
+// against the live heap it marked; others against HeapAlloc, which includes
+// garbage not yet collected. Caller must hold p.mu.
+func (p *Profiler) updateRetentionsLocked(ms *memSample) {
+	totalHeap := ms.heapAlloc
+	if ms.mode == SamplingModeGC && ms.heapLive > 0 {
+		totalHeap = ms.heapLive
+	}
 	if totalHeap == 0 {
 		// Avoid division by zero; nothing to retain.
 		p.retentions = make(map[string]*RetentionStat)
@@ -19,9 +101,8 @@ func (p *Profiler) updateRetentionsLocked(ms *runtime.MemStats) {
 	}
 
 	for key, alloc := range p.allocs {
-		// Heuristic: assume a proportional fraction of TotalAllocBytes still
-		// retained. This is intentionally conservative and relative.
-		retained := alloc.TotalAllocBytes
+		lc := p.live[key]
+		retained := lc.bytes
 		if retained == 0 {
 			delete(p.retentions, key)
 			continue
@@ -42,6 +123,18 @@ func (p *Profiler) updateRetentionsLocked(ms *runtime.MemStats) {
 		}
 
 		rs.RetainedBytes = retained
+		rs.LiveObjects = lc.objects
 		rs.RetainedPercent = percent
 	}
 }
+
+// load returns the current live bytes and object count, clamped at zero.
+func (s *liveStat) load() (bytes, objects uint64) {
+	if b := s.bytes.Load(); b > 0 {
+		bytes = uint64(b)
+	}
+	if n := s.objects.Load(); n > 0 {
+		objects = uint64(n)
+	}
+	return bytes, objects
+}
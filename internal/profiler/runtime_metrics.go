@@ -0,0 +1,303 @@
+package profiler
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"time"
+)
+
+// Metric names read on every sample. Unlike runtime.ReadMemStats, reading
+// these does not stop the world.
+const (
+	mHeapObjectsBytes    = "/memory/classes/heap/objects:bytes"
+	mHeapUnusedBytes     = "/memory/classes/heap/unused:bytes"
+	mHeapFreeBytes       = "/memory/classes/heap/free:bytes"
+	mHeapReleasedBytes   = "/memory/classes/heap/released:bytes"
+	mHeapStacksBytes     = "/memory/classes/heap/stacks:bytes"
+	mOSStacksBytes       = "/memory/classes/os-stacks:bytes"
+	mMSpanInuseBytes     = "/memory/classes/metadata/mspan/inuse:bytes"
+	mMCacheInuseBytes    = "/memory/classes/metadata/mcache/inuse:bytes"
+	mTotalBytes          = "/memory/classes/total:bytes"
+	mHeapLiveBytes       = "/gc/heap/live:bytes"
+	mHeapGoalBytes       = "/gc/heap/goal:bytes"
+	mHeapAllocsBytes     = "/gc/heap/allocs:bytes"
+	mHeapObjects         = "/gc/heap/objects:objects"
+	mGCCycles            = "/gc/cycles/total:gc-cycles"
+	mGOGC                = "/gc/gogc:percent"
+	mGOMemLimit          = "/gc/gomemlimit:bytes"
+	mGoroutines          = "/sched/goroutines:goroutines"
+	mGCCPUSeconds        = "/cpu/classes/gc/total:cpu-seconds"
+	mTotalCPUSeconds     = "/cpu/classes/total:cpu-seconds"
+	mGCPausesSeconds     = "/sched/pauses/total/gc:seconds"
+	mSchedLatencySeconds = "/sched/latencies:seconds"
+	mAllocsBySize        = "/gc/heap/allocs-by-size:bytes"
+	mFreesBySize         = "/gc/heap/frees-by-size:bytes"
+)
+
+var sampledMetrics = []string{
+	mHeapObjectsBytes,
+	mHeapUnusedBytes,
+	mHeapFreeBytes,
+	mHeapReleasedBytes,
+	mHeapStacksBytes,
+	mOSStacksBytes,
+	mMSpanInuseBytes,
+	mMCacheInuseBytes,
+	mTotalBytes,
+	mHeapLiveBytes,
+	mHeapGoalBytes,
+	mHeapAllocsBytes,
+	mHeapObjects,
+	mGCCycles,
+	mGOGC,
+	mGOMemLimit,
+	mGoroutines,
+	mGCCPUSeconds,
+	mTotalCPUSeconds,
+	mGCPausesSeconds,
+	mSchedLatencySeconds,
+	mAllocsBySize,
+	mFreesBySize,
+}
+
+// LatencySummary summarizes a cumulative runtime/metrics duration histogram.
+// Quantiles are bucket upper bounds, so they are accurate to the runtime's
+// bucket resolution.
+type LatencySummary struct {
+	Count      uint64  `json:"count"`
+	P50Seconds float64 `json:"p50_seconds"`
+	P90Seconds float64 `json:"p90_seconds"`
+	P99Seconds float64 `json:"p99_seconds"`
+	MaxSeconds float64 `json:"max_seconds"`
+}
+
+// memSample is one reading of the runtime's memory, GC and scheduler state.
+// Field meanings follow runtime.MemStats where an equivalent exists.
+type memSample struct {
+	heapAlloc    uint64
+	heapInuse    uint64
+	heapIdle     uint64
+	heapReleased uint64
+	heapLive     uint64
+	heapObjects  uint64
+	nextGC       uint64
+	totalAlloc   uint64
+	numGC        uint32
+	lastGC       time.Time
+
+	stackBytes   uint64
+	mspanInuse   uint64
+	mcacheInuse  uint64
+	runtimeTotal uint64
+	goroutines   uint64
+
+	gogc         int64
+	memLimit     uint64
+	gcCPU        float64
+	totalCPU     float64
+	gcPauses     LatencySummary
+	schedLatency LatencySummary
+	sizeClasses  []sizeClassCount
+
+	// mode is the sampling mode that triggered the sample.
+	mode string
+
+	// container is read separately from procfs and cgroup files.
+	container containerSample
+
+	// replay is the trace record a replayed sample comes from.
+	replay *TraceRecord
+}
+
+// gcCPUFraction is the share of the process's CPU time spent in the GC.
+func (s *memSample) gcCPUFraction() float64 {
+	if s.totalCPU <= 0 {
+		return 0
+	}
+	return s.gcCPU / s.totalCPU
+}
+
+// metricsReader reads sampledMetrics, reusing its buffers between calls. It
+// is not safe for concurrent use.
+type metricsReader struct {
+	samples []metrics.Sample
+	gcStats debug.GCStats
+}
+
+func newMetricsReader() *metricsReader {
+	r := &metricsReader{samples: make([]metrics.Sample, len(sampledMetrics))}
+	for i, name := range sampledMetrics {
+		r.samples[i].Name = name
+	}
+	return r
+}
+
+// read takes a new sample. Metrics unsupported by the running toolchain are
+// reported as zero.
+func (r *metricsReader) read() memSample {
+	metrics.Read(r.samples)
+
+	var s memSample
+	var allocsBySize, freesBySize metrics.Sample
+	for _, m := range r.samples {
+		switch m.Name {
+		case mHeapObjectsBytes:
+			s.heapAlloc = u64(m)
+			s.heapInuse += u64(m)
+		case mHeapUnusedBytes:
+			s.heapInuse += u64(m)
+		case mHeapFreeBytes:
+			s.heapIdle += u64(m)
+		case mHeapReleasedBytes:
+			s.heapReleased = u64(m)
+			s.heapIdle += u64(m)
+		case mHeapStacksBytes, mOSStacksBytes:
+			s.stackBytes += u64(m)
+		case mMSpanInuseBytes:
+			s.mspanInuse = u64(m)
+		case mMCacheInuseBytes:
+			s.mcacheInuse = u64(m)
+		case mTotalBytes:
+			s.runtimeTotal = u64(m)
+		case mHeapLiveBytes:
+			s.heapLive = u64(m)
+		case mHeapGoalBytes:
+			s.nextGC = u64(m)
+		case mHeapAllocsBytes:
+			s.totalAlloc = u64(m)
+		case mHeapObjects:
+			s.heapObjects = u64(m)
+		case mGCCycles:
+			s.numGC = uint32(u64(m))
+		case mGOGC:
+			s.gogc = int64(u64(m))
+		case mGOMemLimit:
+			s.memLimit = u64(m)
+		case mGoroutines:
+			s.goroutines = u64(m)
+		case mGCCPUSeconds:
+			s.gcCPU = f64(m)
+		case mTotalCPUSeconds:
+			s.totalCPU = f64(m)
+		case mGCPausesSeconds:
+			s.gcPauses = summarize(m)
+		case mSchedLatencySeconds:
+			s.schedLatency = summarize(m)
+		case mAllocsBySize:
+			allocsBySize = m
+		case mFreesBySize:
+			freesBySize = m
+		}
+	}
+	s.sizeClasses = sizeClassesFromMetrics(allocsBySize, freesBySize)
+
+	// The last GC time is not exported by runtime/metrics. ReadGCStats takes
+	// the heap lock but does not stop the world; the Pause slice is reused.
+	debug.ReadGCStats(&r.gcStats)
+	s.lastGC = r.gcStats.LastGC
+
+	return s
+}
+
+func u64(m metrics.Sample) uint64 {
+	if m.Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return m.Value.Uint64()
+}
+
+func f64(m metrics.Sample) float64 {
+	if m.Value.Kind() != metrics.KindFloat64 {
+		return 0
+	}
+	return m.Value.Float64()
+}
+
+// summarize reduces a Float64Histogram to a LatencySummary.
+func summarize(m metrics.Sample) LatencySummary {
+	if m.Value.Kind() != metrics.KindFloat64Histogram {
+		return LatencySummary{}
+	}
+	h := m.Value.Float64Histogram()
+
+	var total uint64
+	for _, c := range h.Counts {
+		total += c
+	}
+	if total == 0 {
+		return LatencySummary{}
+	}
+
+	out := LatencySummary{
+		Count:      total,
+		P50Seconds: histQuantile(h, total, 0.50),
+		P90Seconds: histQuantile(h, total, 0.90),
+		P99Seconds: histQuantile(h, total, 0.99),
+	}
+	for i := len(h.Counts) - 1; i >= 0; i-- {
+		if h.Counts[i] > 0 {
+			out.MaxSeconds = bucketBound(h, i)
+			break
+		}
+	}
+	return out
+}
+
+func histQuantile(h *metrics.Float64Histogram, total uint64, q float64) float64 {
+	want := uint64(math.Ceil(q * float64(total)))
+	var seen uint64
+	for i, c := range h.Counts {
+		seen += c
+		if seen >= want {
+			return bucketBound(h, i)
+		}
+	}
+	return bucketBound(h, len(h.Counts)-1)
+}
+
+// bucketBound returns the upper bound of bucket i, falling back to the lower
+// bound for the open-ended last bucket.
+func bucketBound(h *metrics.Float64Histogram, i int) float64 {
+	upper := h.Buckets[i+1]
+	if math.IsInf(upper, 1) {
+		return h.Buckets[i]
+	}
+	return upper
+}
+
+// readMemSample reads the memory statistics of a sample from memStats when
+// set, otherwise from runtime/metrics.
+func (p *Profiler) readMemSample() memSample {
+	if p.memStats == nil {
+		return p.reader.read()
+	}
+	var ms runtime.MemStats
+	p.memStats.ReadMemStats(&ms)
+	return memSampleFromMemStats(&ms)
+}
+
+// memSampleFromMemStats adapts a runtime.MemStats reading to memSample. It
+// only fills fields MemStats provides.
+func memSampleFromMemStats(ms *runtime.MemStats) memSample {
+	s := memSample{
+		heapAlloc:    ms.HeapAlloc,
+		heapInuse:    ms.HeapInuse,
+		heapIdle:     ms.HeapIdle,
+		heapReleased: ms.HeapReleased,
+		heapObjects:  ms.HeapObjects,
+		nextGC:       ms.NextGC,
+		totalAlloc:   ms.TotalAlloc,
+		numGC:        ms.NumGC,
+		stackBytes:   ms.StackSys,
+		mspanInuse:   ms.MSpanInuse,
+		mcacheInuse:  ms.MCacheInuse,
+		runtimeTotal: ms.Sys,
+		sizeClasses:  sizeClassesFromMemStats(ms),
+	}
+	if ms.LastGC != 0 {
+		s.lastGC = time.Unix(0, int64(ms.LastGC))
+	}
+	return s
+}
@@ -0,0 +1,105 @@
+package profiler
+
+import (
+	"math"
+	"math/rand/v2"
+	"sync/atomic"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+)
+
+const (
+	sampleModeCalls = "calls"
+	sampleModeBytes = "bytes"
+
+	// errorBoundZ is the z-score used for reported error bounds (95%).
+	errorBoundZ = 1.96
+)
+
+// allocSampler decides which TrackAllocation calls are recorded and how many
+// real events each recorded call stands for. Estimates are Horvitz-Thompson
+// style: a call sampled with probability p is weighted by 1/p.
+type allocSampler struct {
+	mode string
+	rate uint64
+}
+
+func newAllocSampler(cfg config.ProfilerConfig) allocSampler {
+	s := allocSampler{mode: cfg.AllocSampleMode, rate: 1}
+	if s.mode != sampleModeBytes {
+		s.mode = sampleModeCalls
+	}
+	if cfg.AllocSampleRate > 1 {
+		s.rate = uint64(cfg.AllocSampleRate)
+	}
+	return s
+}
+
+// skipCall reports whether a call can be dropped before any reflection is
+// done. Only "calls" mode can decide this early.
+func (s allocSampler) skipCall() bool {
+	return s.mode == sampleModeCalls && s.rate > 1 && rand.Uint64N(s.rate) != 0
+}
+
+// weight returns the inverse inclusion probability of an event of the given
+// size, or 0 if the event is not sampled. Callers must have consulted
+// skipCall first.
+func (s allocSampler) weight(size uint64) float64 {
+	if s.rate <= 1 {
+		return 1
+	}
+	if s.mode == sampleModeCalls {
+		return float64(s.rate)
+	}
+
+	// Bytes mode: an event of size bytes is sampled when at least one
+	// sampling point (Poisson process with mean spacing rate) falls into it.
+	p := -math.Expm1(-float64(size) / float64(s.rate))
+	if p <= 0 || rand.Float64() >= p {
+		return 0
+	}
+	return 1 / p
+}
+
+// roundStochastic rounds x to a neighbouring integer with probabilities that
+// keep the expected value equal to x, so scaled counters stay unbiased.
+func roundStochastic(x float64) uint64 {
+	f := math.Floor(x)
+	n := uint64(f)
+	if rand.Float64() < x-f {
+		n++
+	}
+	return n
+}
+
+// errorBound converts an accumulated variance into a 95% confidence
+// half-width.
+func errorBound(variance float64) uint64 {
+	if variance <= 0 {
+		return 0
+	}
+	return uint64(errorBoundZ*math.Sqrt(variance) + 0.5)
+}
+
+// atomicFloat is a float64 that can be updated concurrently.
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) Add(delta float64) {
+	for {
+		old := f.bits.Load()
+		next := math.Float64bits(math.Float64frombits(old) + delta)
+		if f.bits.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) Swap(v float64) float64 {
+	return math.Float64frombits(f.bits.Swap(math.Float64bits(v)))
+}
+
+func (f *atomicFloat) Load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
@@ -0,0 +1,208 @@
+package profiler
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// trackTimingEvery is how many TrackAllocation calls share one timed call;
+// timing every call would cost as much as the call itself.
+const trackTimingEvery = 64
+
+// lockTimingEvery is how many p.mu acquisitions share one timed wait, so
+// most acquisitions skip the two clock reads.
+const lockTimingEvery = 16
+
+// selfOverheadWindow is the period over which OverheadPercent is measured.
+const selfOverheadWindow = time.Minute
+
+// mapEntryOverhead approximates the per-entry cost of a Go map or sync.Map
+// beyond its key and value.
+const mapEntryOverhead = 48
+
+// SelfStats reports what the profiler itself costs. Times are wall-clock
+// time spent in the profiler. TrackAllocation calls are counted exactly and
+// their time is estimated from one in 64 calls; lock waits are estimated
+// from one in 16 acquisitions. Memory figures are approximate.
+type SelfStats struct {
+	SamplesTotal       uint64  `json:"samples_total"`
+	SampleSecondsTotal float64 `json:"sample_seconds_total"`
+	LastSampleSeconds  float64 `json:"last_sample_seconds"`
+
+	TrackAllocationCallsTotal   uint64  `json:"track_allocation_calls_total"`
+	TrackAllocationSecondsTotal float64 `json:"track_allocation_seconds_total"`
+
+	// LockWaitSecondsTotal is time spent acquiring the profiler's state
+	// lock, by the sampler, readers and HTTP handlers.
+	LockWaitSecondsTotal float64 `json:"lock_wait_seconds_total"`
+
+	HistoryBytes    uint64 `json:"history_bytes"`
+	AllocsBytes     uint64 `json:"allocs_bytes"`
+	RetentionsBytes uint64 `json:"retentions_bytes"`
+	Series          int    `json:"series"`
+
+	// OverheadPercent is time spent sampling and in TrackAllocation as a
+	// percentage of one CPU over the last minute (since start during the
+	// first minute).
+	OverheadPercent       float64 `json:"overhead_percent"`
+	OverheadBudgetPercent float64 `json:"overhead_budget_percent"`
+}
+
+// selfCounters accumulates SelfStats timings. The window fields are only
+// used by sampleOnce; the first window starts when the profiler is built.
+type selfCounters struct {
+	samples         atomic.Uint64
+	sampleNanos     atomic.Int64
+	lastSampleNanos atomic.Int64
+	trackCalls      atomic.Uint64
+	trackTimed      atomic.Uint64
+	trackNanos      atomic.Int64
+	lockWaitNanos   atomic.Int64 // timed acquisitions only
+	overhead        atomicFloat
+
+	windowStart time.Time
+	windowBase  int64
+	windowDone  bool
+}
+
+// costNanos is the estimated total time spent sampling and tracking.
+func (c *selfCounters) costNanos() int64 {
+	return c.sampleNanos.Load() + c.trackCostNanos()
+}
+
+// trackCostNanos estimates the time spent in TrackAllocation from the mean
+// of the timed calls and the exact call count.
+func (c *selfCounters) trackCostNanos() int64 {
+	timed := c.trackTimed.Load()
+	if timed == 0 {
+		return 0
+	}
+	return int64(float64(c.trackNanos.Load()) / float64(timed) * float64(c.trackCalls.Load()))
+}
+
+// recordSample accounts one sample that took cost and updates the overhead
+// over the current window.
+func (c *selfCounters) recordSample(cost time.Duration, now time.Time) {
+	c.samples.Add(1)
+	c.sampleNanos.Add(int64(cost))
+	c.lastSampleNanos.Store(int64(cost))
+
+	total := c.costNanos()
+	elapsed := now.Sub(c.windowStart)
+	if elapsed <= 0 {
+		return
+	}
+	if !c.windowDone || elapsed >= selfOverheadWindow {
+		c.overhead.Swap(float64(total-c.windowBase) / float64(elapsed) * 100)
+	}
+	if elapsed >= selfOverheadWindow {
+		c.windowStart, c.windowBase, c.windowDone = now, total, true
+	}
+}
+
+// lockMu acquires p.mu, timing the wait for one in lockTimingEvery
+// acquisitions.
+func (p *Profiler) lockMu() {
+	if rand.Uint32()%lockTimingEvery != 0 {
+		p.mu.Lock()
+		return
+	}
+	start := time.Now()
+	p.mu.Lock()
+	p.self.lockWaitNanos.Add(int64(time.Since(start)))
+}
+
+// rlockMu acquires p.mu for reading, timing the wait for one in
+// lockTimingEvery acquisitions.
+func (p *Profiler) rlockMu() {
+	if rand.Uint32()%lockTimingEvery != 0 {
+		p.mu.RLock()
+		return
+	}
+	start := time.Now()
+	p.mu.RLock()
+	p.self.lockWaitNanos.Add(int64(time.Since(start)))
+}
+
+// timedTrackAllocation runs trackAllocation, counting every call and timing
+// one in trackTimingEvery.
+func (p *Profiler) timedTrackAllocation(obj any, tag string) {
+	p.self.trackCalls.Add(1)
+	if rand.Uint32()%trackTimingEvery != 0 {
+		trackAllocation(p, obj, tag)
+		return
+	}
+	start := time.Now()
+	trackAllocation(p, obj, tag)
+	p.self.trackNanos.Add(int64(time.Since(start)))
+	p.self.trackTimed.Add(1)
+}
+
+// SelfStats returns the profiler's own costs.
+func (p *Profiler) SelfStats() SelfStats {
+	p.rlockMu()
+	defer p.mu.RUnlock()
+
+	c := &p.self
+	return SelfStats{
+		SamplesTotal:                c.samples.Load(),
+		SampleSecondsTotal:          time.Duration(c.sampleNanos.Load()).Seconds(),
+		LastSampleSeconds:           time.Duration(c.lastSampleNanos.Load()).Seconds(),
+		TrackAllocationCallsTotal:   c.trackCalls.Load(),
+		TrackAllocationSecondsTotal: time.Duration(c.trackCostNanos()).Seconds(),
+		LockWaitSecondsTotal:        time.Duration(c.lockWaitNanos.Load() * lockTimingEvery).Seconds(),
+		HistoryBytes:                p.historyBytesLocked(),
+		AllocsBytes:                 p.allocsBytesLocked(),
+		RetentionsBytes:             p.retentionsBytesLocked(),
+		Series:                      p.seriesStatsLocked().Series,
+		OverheadPercent:             c.overhead.Load(),
+		OverheadBudgetPercent:       p.cfg.SelfOverheadBudgetPercent,
+	}
+}
+
+// historyBytesLocked approximates the memory held by the snapshot ring and
+// rollup tiers. Caller must hold p.mu.
+func (p *Profiler) historyBytesLocked() uint64 {
+	n := uint64(len(p.history)) * uint64(unsafe.Sizeof(ProfilerSnapshot{}))
+	for i := range p.histCount {
+		s := &p.history[(p.histStart+i)%len(p.history)]
+		n += uint64(cap(s.TopAllocations)) * uint64(unsafe.Sizeof(AllocationStat{}))
+		n += uint64(cap(s.TopRetentions)) * uint64(unsafe.Sizeof(RetentionStat{}))
+	}
+	perPoint := uint64(unsafe.Sizeof(rollupAcc{})) + 4*8*uint64(len(snapshotFields))
+	for _, t := range p.tiers {
+		n += uint64(len(t.points)+1) * perPoint
+	}
+	return n
+}
+
+// allocsBytesLocked approximates the memory held by allocation series,
+// their rate windows and pending shard entries, or by the sketch in sketch
+// mode. Caller must hold p.mu.
+func (p *Profiler) allocsBytesLocked() uint64 {
+	if p.sketch != nil {
+		return uint64(len(p.sketch.shards))*uint64(unsafe.Sizeof(sketchShard{})) +
+			uint64(p.sketch.counters())*uint64(unsafe.Sizeof(ssCounter{})+mapEntryOverhead)
+	}
+	var n uint64
+	for key := range p.allocs {
+		n += uint64(unsafe.Sizeof(AllocationStat{})+mapEntryOverhead) + uint64(len(key))
+	}
+	n += uint64(len(p.rates)) * uint64(unsafe.Sizeof(rateWindow{})+mapEntryOverhead)
+	for i := range p.shards {
+		p.shards[i].entries.Range(func(_, _ any) bool {
+			n += uint64(unsafe.Sizeof(shardEntry{}) + mapEntryOverhead)
+			return true
+		})
+	}
+	return n
+}
+
+// retentionsBytesLocked approximates the memory held by retention stats and
+// live object totals. Caller must hold p.mu.
+func (p *Profiler) retentionsBytesLocked() uint64 {
+	return uint64(len(p.retentions))*uint64(unsafe.Sizeof(RetentionStat{})+mapEntryOverhead) +
+		uint64(len(p.live))*uint64(unsafe.Sizeof(liveCount{})+mapEntryOverhead)
+}
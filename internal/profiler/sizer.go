@@ -0,0 +1,64 @@
+package profiler
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Size sources recorded on AllocationStat.SizeSource.
+const (
+	SizeSourceReflection = "reflection"
+	SizeSourceSizer      = "sizer"
+	SizeSourceRegistered = "registered"
+)
+
+// Sizer is implemented by types that can report their own memory footprint,
+// e.g. ring buffers, arenas or wrappers around cgo memory where reflection
+// cannot see the real allocation. TrackAllocation prefers SizeBytes over
+// reflection-based estimates.
+type Sizer interface {
+	SizeBytes() uint64
+}
+
+// SizeFunc reports the memory footprint of a value of a registered type.
+type SizeFunc func(obj any) uint64
+
+var sizeFuncs sync.Map // reflect.Type -> SizeFunc
+
+// RegisterSizeFunc installs fn as the size function for values of typ. It is
+// meant for third-party types that cannot implement Sizer. A registered
+// function takes precedence over Sizer and reflection. Passing a nil fn
+// removes the registration.
+func RegisterSizeFunc(typ reflect.Type, fn SizeFunc) {
+	if typ == nil {
+		return
+	}
+	if fn == nil {
+		sizeFuncs.Delete(typ)
+		return
+	}
+	sizeFuncs.Store(typ, fn)
+}
+
+// sizeOf returns the size of obj and where that number came from: a
+// registered SizeFunc, the type's own Sizer, or reflection (deep or shallow
+// depending on configuration). A nil reference is 0 bytes: SizeFuncs and
+// Sizer methods with value receivers would panic dereferencing it.
+func (p *Profiler) sizeOf(obj any, typ reflect.Type) (uint64, string) {
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Chan, reflect.Func:
+		if reflect.ValueOf(obj).IsNil() {
+			return 0, SizeSourceReflection
+		}
+	}
+	if fn, ok := sizeFuncs.Load(typ); ok {
+		return fn.(SizeFunc)(obj), SizeSourceRegistered
+	}
+	if s, ok := obj.(Sizer); ok {
+		return s.SizeBytes(), SizeSourceSizer
+	}
+	if p.deep.enabled {
+		return p.deep.deepSize(obj, typ), SizeSourceReflection
+	}
+	return estimateSize(obj, typ), SizeSourceReflection
+}
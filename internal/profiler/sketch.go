@@ -0,0 +1,323 @@
+package profiler
+
+import (
+	"container/heap"
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+)
+
+// Accounting modes for AllocationStat.AccountingMode.
+const (
+	AccountingExact  = "exact"
+	AccountingSketch = "sketch"
+)
+
+const (
+	// Count-Min dimensions: estimates exceed the true count by at most
+	// e/cmsWidth of all counted calls with probability 1-e^-cmsDepth (~98%).
+	cmsDepth = 4
+	cmsWidth = 2048
+
+	// hllPrecision gives 4096 registers, a standard error of ~1.6%.
+	hllPrecision = 12
+	hllRegisters = 1 << hllPrecision
+)
+
+var sketchSeed = maphash.MakeSeed()
+
+func sketchHash(s string) uint64 {
+	return maphash.String(sketchSeed, s)
+}
+
+// allocSketch replaces the exact allocation map when the accounting mode is
+// "sketch". Heavy hitters by bytes are kept with Space-Saving, call counts
+// come from a Count-Min sketch and distinct tags from a HyperLogLog. Memory
+// is bounded by the shard count and capacity, whatever the tag cardinality.
+type allocSketch struct {
+	capacity int
+	shards   []sketchShard
+}
+
+// sketchShard is one independently locked partition. Like allocShard, a
+// shard is picked at random per call and shards are merged on read.
+type sketchShard struct {
+	mu    sync.Mutex
+	top   spaceSaving
+	cms   countMin
+	tags  hyperLogLog
+	calls uint64
+	_     [cacheLinePad]byte
+}
+
+func newAllocSketch(capacity int) *allocSketch {
+	n := 1
+	for n < runtime.GOMAXPROCS(0) {
+		n <<= 1
+	}
+	s := &allocSketch{capacity: capacity, shards: make([]sketchShard, n)}
+	for i := range s.shards {
+		s.shards[i].top = newSpaceSaving(capacity)
+	}
+	return s
+}
+
+func (s *allocSketch) add(key, typeName, tag, source string, count, bytes uint64) {
+	kh := sketchHash(key)
+	th := sketchHash(tag)
+
+	sh := &s.shards[rand.Uint32()&uint32(len(s.shards)-1)]
+	sh.mu.Lock()
+	sh.top.add(key, typeName, tag, source, bytes)
+	sh.cms.add(kh, count)
+	sh.tags.add(th)
+	sh.calls += count
+	sh.mu.Unlock()
+}
+
+// sketchResult is the merged view of all shards.
+type sketchResult struct {
+	stats    []AllocationStat
+	distinct uint64
+}
+
+// merge combines the shard summaries. A key missing from a full shard may
+// still have received up to that shard's minimum counter there, so that
+// minimum is added to both its estimate and its error, which keeps
+// TotalAllocBytes an upper bound with TotalAllocBytesError bounding the
+// overestimate.
+func (s *allocSketch) merge() sketchResult {
+	type acc struct {
+		stat       AllocationStat
+		presentMin uint64
+	}
+
+	var (
+		cms      countMin
+		tags     hyperLogLog
+		calls    uint64
+		totalMin uint64
+	)
+	byKey := make(map[string]*acc)
+
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+
+		floor := sh.top.min()
+		totalMin += floor
+		for _, c := range sh.top.heap {
+			a, ok := byKey[c.key]
+			if !ok {
+				a = &acc{stat: AllocationStat{
+					TypeName:       c.typeName,
+					Tag:            c.tag,
+					SizeSource:     c.source,
+					AccountingMode: AccountingSketch,
+				}}
+				byKey[c.key] = a
+			}
+			a.stat.TotalAllocBytes += c.bytes
+			a.stat.TotalAllocBytesError += c.err
+			a.stat.SampledCount += c.sampled
+			a.presentMin += floor
+		}
+		cms.merge(&sh.cms)
+		tags.merge(&sh.tags)
+		calls += sh.calls
+
+		sh.mu.Unlock()
+	}
+
+	countErr := uint64(math.Ceil(math.E / cmsWidth * float64(calls)))
+
+	stats := make([]AllocationStat, 0, len(byKey))
+	for key, a := range byKey {
+		st := a.stat
+		st.TotalAllocBytes += totalMin - a.presentMin
+		st.TotalAllocBytesError += totalMin - a.presentMin
+		st.AllocCount = cms.estimate(sketchHash(key))
+		st.AllocCountError = min(countErr, st.AllocCount)
+		if st.AllocCount > 0 {
+			st.AverageAllocBytes = st.TotalAllocBytes / st.AllocCount
+		}
+		stats = append(stats, st)
+	}
+
+	return sketchResult{stats: stats, distinct: tags.estimate()}
+}
+
+// counters returns the number of heavy-hitter counters in use.
+func (s *allocSketch) counters() int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		n += len(sh.top.heap)
+		sh.mu.Unlock()
+	}
+	return n
+}
+
+// ssCounter is a Space-Saving counter. err is the count inherited from the
+// evicted counter it replaced, i.e. how much bytes may overestimate.
+type ssCounter struct {
+	key      string
+	typeName string
+	tag      string
+	source   string
+	bytes    uint64
+	err      uint64
+	sampled  uint64
+	idx      int
+}
+
+// spaceSaving keeps the capacity heaviest keys by bytes (Metwally et al.).
+// When full, a new key replaces the minimum counter and inherits its value.
+type spaceSaving struct {
+	capacity int
+	index    map[string]*ssCounter
+	heap     ssHeap
+}
+
+func newSpaceSaving(capacity int) spaceSaving {
+	return spaceSaving{
+		capacity: capacity,
+		index:    make(map[string]*ssCounter, capacity),
+		heap:     make(ssHeap, 0, capacity),
+	}
+}
+
+func (s *spaceSaving) add(key, typeName, tag, source string, bytes uint64) {
+	if c, ok := s.index[key]; ok {
+		c.bytes += bytes
+		c.sampled++
+		heap.Fix(&s.heap, c.idx)
+		return
+	}
+
+	if len(s.heap) < s.capacity {
+		c := &ssCounter{key: key, typeName: typeName, tag: tag, source: source, bytes: bytes, sampled: 1}
+		s.index[key] = c
+		heap.Push(&s.heap, c)
+		return
+	}
+
+	c := s.heap[0]
+	delete(s.index, c.key)
+	*c = ssCounter{
+		key:      key,
+		typeName: typeName,
+		tag:      tag,
+		source:   source,
+		bytes:    c.bytes + bytes,
+		err:      c.bytes,
+		sampled:  1,
+		idx:      0,
+	}
+	s.index[key] = c
+	heap.Fix(&s.heap, 0)
+}
+
+// min returns the smallest counter once the summary is full, 0 otherwise.
+func (s *spaceSaving) min() uint64 {
+	if len(s.heap) < s.capacity || len(s.heap) == 0 {
+		return 0
+	}
+	return s.heap[0].bytes
+}
+
+// ssHeap is a min-heap of counters ordered by bytes.
+type ssHeap []*ssCounter
+
+func (h ssHeap) Len() int           { return len(h) }
+func (h ssHeap) Less(i, j int) bool { return h[i].bytes < h[j].bytes }
+func (h ssHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].idx = i
+	h[j].idx = j
+}
+
+func (h *ssHeap) Push(x any) {
+	c := x.(*ssCounter)
+	c.idx = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *ssHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// countMin is a Count-Min sketch. Rows are indexed by double hashing a
+// single 64-bit hash.
+type countMin [cmsDepth][cmsWidth]uint64
+
+func cmsIndex(h uint64, row int) int {
+	h1, h2 := uint32(h), uint32(h>>32)|1
+	return int((h1 + uint32(row)*h2) & (cmsWidth - 1))
+}
+
+func (c *countMin) add(h, n uint64) {
+	for r := range c {
+		c[r][cmsIndex(h, r)] += n
+	}
+}
+
+func (c *countMin) estimate(h uint64) uint64 {
+	est := uint64(math.MaxUint64)
+	for r := range c {
+		est = min(est, c[r][cmsIndex(h, r)])
+	}
+	return est
+}
+
+func (c *countMin) merge(o *countMin) {
+	for r := range c {
+		for i := range c[r] {
+			c[r][i] += o[r][i]
+		}
+	}
+}
+
+// hyperLogLog estimates the number of distinct hashed values.
+type hyperLogLog [hllRegisters]uint8
+
+func (h *hyperLogLog) add(x uint64) {
+	idx := x >> (64 - hllPrecision)
+	rho := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1))) + 1
+	if rho > h[idx] {
+		h[idx] = rho
+	}
+}
+
+func (h *hyperLogLog) merge(o *hyperLogLog) {
+	for i := range h {
+		h[i] = max(h[i], o[i])
+	}
+}
+
+func (h *hyperLogLog) estimate() uint64 {
+	const m = float64(hllRegisters)
+	alpha := 0.7213 / (1 + 1.079/m)
+
+	sum, zeros := 0.0, 0
+	for _, r := range h {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	e := alpha * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities.
+		e = m * math.Log(m/float64(zeros))
+	}
+	return uint64(e + 0.5)
+}
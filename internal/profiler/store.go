@@ -1,45 +1,87 @@
 package profiler
 
 import (
-	"runtime"
 	"sort"
 	"time"
 )
 
-// buildSnapshotLocked builds a snapshot from current memstats and top entries.
-// Caller must hold p.mu.
-func (p *Profiler) buildSnapshotLocked(ms *runtime.MemStats, now time.Time) ProfilerSnapshot {
-	const defaultTopN = 10
+// snapshotTopN is the length of the top lists stored in snapshots. A shorter
+// list holds every series there was.
+const snapshotTopN = 10
 
-	topAllocs := p.topAllocationsLocked(defaultTopN)
-	topRet := p.topRetentionsLocked(defaultTopN)
+// buildSnapshotLocked builds a snapshot from the current runtime sample and
+// top entries. Caller must hold p.mu.
+func (p *Profiler) buildSnapshotLocked(ms *memSample, now time.Time) ProfilerSnapshot {
+	topAllocs := p.topAllocationsLocked(snapshotTopN, DefaultRateWindow, SortByBytes, now)
+	topRet := p.topRetentionsLocked(snapshotTopN)
 
 	lastGC := int64(0)
-	if ms.LastGC != 0 {
-		// LastGC is in nanoseconds since epoch.
-		lastGC = int64(time.Unix(0, int64(ms.LastGC)).Unix())
+	if !ms.lastGC.IsZero() {
+		lastGC = ms.lastGC.Unix()
 	}
 
-	return ProfilerSnapshot{
-		Timestamp: now,
-
-		HeapAllocBytes:  ms.HeapAlloc,
-		HeapInuseBytes:  ms.HeapInuse,
-		HeapIdleBytes:   ms.HeapIdle,
-		HeapReleased:    ms.HeapReleased,
-		NumGC:           ms.NumGC,
+	snap := ProfilerSnapshot{
+		Timestamp:           now,
+		SamplingMode:        ms.mode,
+		SamplingIntervalMs:  p.SamplingInterval().Milliseconds(),
+		SelfOverheadPercent: p.selfOverhead(ms),
+
+		HeapAllocBytes:  ms.heapAlloc,
+		HeapInuseBytes:  ms.heapInuse,
+		HeapIdleBytes:   ms.heapIdle,
+		HeapReleased:    ms.heapReleased,
+		NumGC:           ms.numGC,
 		LastGCUnix:      lastGC,
-		NextGCBytes:     ms.NextGC,
-		TotalAllocBytes: ms.TotalAlloc,
+		NextGCBytes:     ms.nextGC,
+		TotalAllocBytes: ms.totalAlloc,
+
+		HeapLiveBytes:     ms.heapLive,
+		HeapObjects:       ms.heapObjects,
+		StackBytes:        ms.stackBytes,
+		MSpanInuseBytes:   ms.mspanInuse,
+		MCacheInuseBytes:  ms.mcacheInuse,
+		RuntimeTotalBytes: ms.runtimeTotal,
+		Goroutines:        ms.goroutines,
+		GOGCPercent:       ms.gogc,
+		GOMemLimitBytes:   ms.memLimit,
+		GCCPUSeconds:      ms.gcCPU,
+		GCCPUFraction:     ms.gcCPUFraction(),
+		GCPauses:          ms.gcPauses,
+		SchedLatency:      ms.schedLatency,
+
+		Fragmentation: fragmentation(ms, p.sizeClasses),
+
+		RSSBytes:              ms.container.rss,
+		RSSAnonBytes:          ms.container.rssAnon,
+		RSSFileBytes:          ms.container.rssFile,
+		RSSShmemBytes:         ms.container.rssShmem,
+		PSSBytes:              ms.container.pss,
+		SwapBytes:             ms.container.swap,
+		NonGoBytes:            ms.nonGoBytes(),
+		CgroupVersion:         ms.container.cgroupVersion,
+		CgroupUsageBytes:      ms.container.cgroupUsage,
+		CgroupWorkingSetBytes: ms.container.cgroupWorkingSet(),
+		CgroupLimitBytes:      ms.container.cgroupLimit,
+		CgroupOOMEvents:       ms.container.cgroupOOMEvents,
+		CgroupOOMKills:        ms.container.cgroupOOMKills,
+
+		AllocSeries: p.seriesStatsLocked(),
 
 		TopAllocations: topAllocs,
 		TopRetentions:  topRet,
 	}
+	snap.Forecast = p.forecastLocked(&snap)
+	return snap
 }
 
 // appendSnapshotLocked appends a snapshot to history and enforces the
-// MaxHistorySamples bound. Caller must hold p.mu.
+// MaxHistorySamples and RetentionWindowSec bounds. Rollup tiers are fed even
+// when the raw ring is disabled. Caller must hold p.mu.
 func (p *Profiler) appendSnapshotLocked(snap ProfilerSnapshot) {
+	for _, t := range p.tiers {
+		t.add(&snap)
+	}
+
 	if p.cfg.MaxHistorySamples <= 0 || len(p.history) == 0 {
 		// History disabled
 		p.histStart = 0
@@ -53,28 +95,86 @@ func (p *Profiler) appendSnapshotLocked(snap ProfilerSnapshot) {
 		idx := (p.histStart + p.histCount) % size
 		p.history[idx] = snap
 		p.histCount++
+	} else {
+		// Buffer full: overwrite oldest and advance start
+		p.history[p.histStart] = snap
+		p.histStart = (p.histStart + 1) % size
+	}
+
+	p.pruneHistoryLocked(snap.Timestamp)
+}
+
+// pruneHistoryLocked drops snapshots older than RetentionWindowSec before
+// now, even when the ring is not full. Caller must hold p.mu.
+func (p *Profiler) pruneHistoryLocked(now time.Time) {
+	if p.cfg.RetentionWindowSec <= 0 {
 		return
 	}
+	cutoff := now.Add(-time.Duration(p.cfg.RetentionWindowSec) * time.Second)
 
-	// Buffer full: overwrite oldest and advance start
-	p.history[p.histStart] = snap
-	p.histStart = (p.histStart + 1) % size
+	size := len(p.history)
+	for p.histCount > 0 && p.history[p.histStart].Timestamp.Before(cutoff) {
+		// Release the dropped snapshot's slices.
+		p.history[p.histStart] = ProfilerSnapshot{}
+		p.histStart = (p.histStart + 1) % size
+		p.histCount--
+	}
 }
 
-// topAllocationsLocked returns top-N allocation stats sorted descending by
-// TotalAllocBytes. Caller must hold p.mu.
-func (p *Profiler) topAllocationsLocked(limit int) []AllocationStat {
+// topAllocationsLocked returns top-N allocation stats with rates over window,
+// hold p.mu; a read lock is enough.
+// hold p.mu; a read lock is enough outside sketch mode.
+func (p *Profiler) topAllocationsLocked(limit int, window time.Duration, sortBy string, now time.Time) []AllocationStat {
+	if p.sketch != nil {
+		// The sketch keeps no time buckets, so rate orderings degrade to
+		// bytes and rates are left empty.
+		tmp := p.sketch.merge().stats
+		if sortBy == SortByBytesRate || sortBy == SortByAllocsRate {
+			sortBy = SortByBytes
+		}
+		return sortAllocations(tmp, limit, sortBy)
+	}
+
 	if len(p.allocs) == 0 {
 		return nil
 	}
 
+	if window <= 0 {
+		window = DefaultRateWindow
+	}
+	if window > MaxRateWindow {
+		window = MaxRateWindow
+	}
+
 	tmp := make([]AllocationStat, 0, len(p.allocs))
-	for _, v := range p.allocs {
-		tmp = append(tmp, *v)
+	for key, v := range p.allocs {
+		st := *v
+		st.RateWindow = window.String()
+		st.BytesPerSec, st.AllocsPerSec = p.rates[key].rate(now, window)
+		tmp = append(tmp, st)
 	}
+	return sortAllocations(tmp, limit, sortBy)
+}
 
+// sortAllocations orders tmp descending by sortBy and trims it to limit.
+func sortAllocations(tmp []AllocationStat, limit int, sortBy string) []AllocationStat {
+	if len(tmp) == 0 {
+		return nil
+	}
+
+	var less func(a, b *AllocationStat) bool
+	switch sortBy {
+	case SortByCount:
+		less = func(a, b *AllocationStat) bool { return a.AllocCount > b.AllocCount }
+	case SortByBytesRate:
+		less = func(a, b *AllocationStat) bool { return a.BytesPerSec > b.BytesPerSec }
+	case SortByAllocsRate:
+		less = func(a, b *AllocationStat) bool { return a.AllocsPerSec > b.AllocsPerSec }
+	default:
+		less = func(a, b *AllocationStat) bool { return a.TotalAllocBytes > b.TotalAllocBytes }
+	}
 	sort.Slice(tmp, func(i, j int) bool {
-		return tmp[i].TotalAllocBytes > tmp[j].TotalAllocBytes
+		return less(&tmp[i], &tmp[j])
 	})
 
 	if limit <= 0 || limit > len(tmp) {
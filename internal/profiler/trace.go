@@ -0,0 +1,514 @@
+package profiler
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+)
+
+const (
+	traceFormat  = "goprof-trace"
+	traceVersion = 1
+)
+
+// TraceHeader is the first entry of a trace file.
+type TraceHeader struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	GoVersion string    `json:"go_version"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// TraceRecord is one raw sample of a trace: the memory statistics the
+// profiler read and the allocations tracked since the previous sample.
+type TraceRecord struct {
+	At     time.Time    `json:"at"`
+	Mode   string       `json:"mode"`
+	Mem    TraceMem     `json:"mem"`
+	Allocs []TraceAlloc `json:"allocs,omitempty"`
+
+	// SelfOverheadPercent is the recording profiler's own overhead, which
+	// replays report instead of their own.
+	SelfOverheadPercent float64 `json:"self_overhead_percent,omitempty"`
+}
+
+// TraceMem holds the runtime/metrics (or MemStats) and container values of
+// a sample. Field meanings follow runtime.MemStats where an equivalent
+// exists.
+type TraceMem struct {
+	HeapAlloc    uint64    `json:"heap_alloc"`
+	HeapInuse    uint64    `json:"heap_inuse"`
+	HeapIdle     uint64    `json:"heap_idle"`
+	HeapReleased uint64    `json:"heap_released"`
+	HeapLive     uint64    `json:"heap_live,omitempty"`
+	HeapObjects  uint64    `json:"heap_objects"`
+	NextGC       uint64    `json:"next_gc"`
+	TotalAlloc   uint64    `json:"total_alloc"`
+	NumGC        uint32    `json:"num_gc"`
+	LastGC       time.Time `json:"last_gc"`
+
+	StackBytes   uint64 `json:"stack_bytes"`
+	MSpanInuse   uint64 `json:"mspan_inuse"`
+	MCacheInuse  uint64 `json:"mcache_inuse"`
+	RuntimeTotal uint64 `json:"runtime_total"`
+	Goroutines   uint64 `json:"goroutines,omitempty"`
+
+	GOGC         int64            `json:"gogc,omitempty"`
+	MemLimit     uint64           `json:"mem_limit,omitempty"`
+	GCCPU        float64          `json:"gc_cpu_seconds,omitempty"`
+	TotalCPU     float64          `json:"total_cpu_seconds,omitempty"`
+	GCPauses     LatencySummary   `json:"gc_pauses"`
+	SchedLatency LatencySummary   `json:"sched_latency"`
+	SizeClasses  []TraceSizeClass `json:"size_classes,omitempty"`
+
+	Container TraceContainer `json:"container"`
+}
+
+// TraceSizeClass is the cumulative mallocs and frees of one size class.
+type TraceSizeClass struct {
+	Size    uint64 `json:"size"`
+	Mallocs uint64 `json:"mallocs"`
+	Frees   uint64 `json:"frees"`
+}
+
+// TraceContainer holds the procfs and cgroup values of a sample.
+type TraceContainer struct {
+	RSS      uint64 `json:"rss,omitempty"`
+	RSSAnon  uint64 `json:"rss_anon,omitempty"`
+	RSSFile  uint64 `json:"rss_file,omitempty"`
+	RSSShmem uint64 `json:"rss_shmem,omitempty"`
+	PSS      uint64 `json:"pss,omitempty"`
+	Swap     uint64 `json:"swap,omitempty"`
+
+	CgroupVersion      int    `json:"cgroup_version,omitempty"`
+	CgroupUsage        uint64 `json:"cgroup_usage,omitempty"`
+	CgroupLimit        uint64 `json:"cgroup_limit,omitempty"`
+	CgroupInactiveFile uint64 `json:"cgroup_inactive_file,omitempty"`
+	CgroupOOMEvents    uint64 `json:"cgroup_oom_events,omitempty"`
+	CgroupOOMKills     uint64 `json:"cgroup_oom_kills,omitempty"`
+}
+
+// TraceAlloc is one allocation series of a sample: the count and bytes
+// tracked since the previous sample, and the live totals at the sample.
+type TraceAlloc struct {
+	TypeName    string `json:"type"`
+	Tag         string `json:"tag"`
+	SizeSource  string `json:"size_source,omitempty"`
+	Count       uint64 `json:"count,omitempty"`
+	Bytes       uint64 `json:"bytes,omitempty"`
+	Sampled     uint64 `json:"sampled,omitempty"`
+	LiveObjects uint64 `json:"live_objects,omitempty"`
+	LiveBytes   uint64 `json:"live_bytes,omitempty"`
+}
+
+func traceMemFromSample(ms *memSample) TraceMem {
+	t := TraceMem{
+		HeapAlloc:    ms.heapAlloc,
+		HeapInuse:    ms.heapInuse,
+		HeapIdle:     ms.heapIdle,
+		HeapReleased: ms.heapReleased,
+		HeapLive:     ms.heapLive,
+		HeapObjects:  ms.heapObjects,
+		NextGC:       ms.nextGC,
+		TotalAlloc:   ms.totalAlloc,
+		NumGC:        ms.numGC,
+		LastGC:       ms.lastGC,
+		StackBytes:   ms.stackBytes,
+		MSpanInuse:   ms.mspanInuse,
+		MCacheInuse:  ms.mcacheInuse,
+		RuntimeTotal: ms.runtimeTotal,
+		Goroutines:   ms.goroutines,
+		GOGC:         ms.gogc,
+		MemLimit:     ms.memLimit,
+		GCCPU:        ms.gcCPU,
+		TotalCPU:     ms.totalCPU,
+		GCPauses:     ms.gcPauses,
+		SchedLatency: ms.schedLatency,
+		Container: TraceContainer{
+			RSS:                ms.container.rss,
+			RSSAnon:            ms.container.rssAnon,
+			RSSFile:            ms.container.rssFile,
+			RSSShmem:           ms.container.rssShmem,
+			PSS:                ms.container.pss,
+			Swap:               ms.container.swap,
+			CgroupVersion:      ms.container.cgroupVersion,
+			CgroupUsage:        ms.container.cgroupUsage,
+			CgroupLimit:        ms.container.cgroupLimit,
+			CgroupInactiveFile: ms.container.cgroupInactiveFile,
+			CgroupOOMEvents:    ms.container.cgroupOOMEvents,
+			CgroupOOMKills:     ms.container.cgroupOOMKills,
+		},
+	}
+	for _, c := range ms.sizeClasses {
+		t.SizeClasses = append(t.SizeClasses, TraceSizeClass{Size: c.size, Mallocs: c.mallocs, Frees: c.frees})
+	}
+	return t
+}
+
+func (t *TraceMem) memSample() memSample {
+	ms := memSample{
+		heapAlloc:    t.HeapAlloc,
+		heapInuse:    t.HeapInuse,
+		heapIdle:     t.HeapIdle,
+		heapReleased: t.HeapReleased,
+		heapLive:     t.HeapLive,
+		heapObjects:  t.HeapObjects,
+		nextGC:       t.NextGC,
+		totalAlloc:   t.TotalAlloc,
+		numGC:        t.NumGC,
+		lastGC:       t.LastGC,
+		stackBytes:   t.StackBytes,
+		mspanInuse:   t.MSpanInuse,
+		mcacheInuse:  t.MCacheInuse,
+		runtimeTotal: t.RuntimeTotal,
+		goroutines:   t.Goroutines,
+		gogc:         t.GOGC,
+		memLimit:     t.MemLimit,
+		gcCPU:        t.GCCPU,
+		totalCPU:     t.TotalCPU,
+		gcPauses:     t.GCPauses,
+		schedLatency: t.SchedLatency,
+		container: containerSample{
+			rss:                t.Container.RSS,
+			rssAnon:            t.Container.RSSAnon,
+			rssFile:            t.Container.RSSFile,
+			rssShmem:           t.Container.RSSShmem,
+			pss:                t.Container.PSS,
+			swap:               t.Container.Swap,
+			cgroupVersion:      t.Container.CgroupVersion,
+			cgroupUsage:        t.Container.CgroupUsage,
+			cgroupLimit:        t.Container.CgroupLimit,
+			cgroupInactiveFile: t.Container.CgroupInactiveFile,
+			cgroupOOMEvents:    t.Container.CgroupOOMEvents,
+			cgroupOOMKills:     t.Container.CgroupOOMKills,
+		},
+	}
+	for _, c := range t.SizeClasses {
+		ms.sizeClasses = append(ms.sizeClasses, sizeClassCount{size: c.Size, mallocs: c.Mallocs, frees: c.Frees})
+	}
+	return ms
+}
+
+// TraceRecorder streams trace records to a gzip-compressed file of JSON
+// lines, a TraceHeader followed by one TraceRecord per sample. The stream is
+// flushed after every record, so a crash loses at most the sample being
+// written. It is safe for concurrent use.
+type TraceRecorder struct {
+	mu  sync.Mutex
+	c   io.Closer
+	zw  *gzip.Writer
+	enc *json.Encoder
+}
+
+// CreateTraceRecorder creates (or truncates) the trace file at path.
+func CreateTraceRecorder(path string) (*TraceRecorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create trace: %w", err)
+	}
+	r, err := NewTraceRecorder(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.c = f
+	return r, nil
+}
+
+// NewTraceRecorder writes a trace to w. Close does not close w.
+func NewTraceRecorder(w io.Writer) (*TraceRecorder, error) {
+	zw := gzip.NewWriter(w)
+	r := &TraceRecorder{zw: zw, enc: json.NewEncoder(zw)}
+	h := TraceHeader{
+		Format:    traceFormat,
+		Version:   traceVersion,
+		GoVersion: runtime.Version(),
+		StartedAt: time.Now().UTC(),
+	}
+	if err := r.write(h); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Record appends rec to the trace.
+func (r *TraceRecorder) Record(rec TraceRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.zw == nil {
+		return errors.New("trace recorder closed")
+	}
+	return r.write(rec)
+}
+
+func (r *TraceRecorder) write(v any) error {
+	if err := r.enc.Encode(v); err != nil {
+		return fmt.Errorf("write trace: %w", err)
+	}
+	if err := r.zw.Flush(); err != nil {
+		return fmt.Errorf("write trace: %w", err)
+	}
+	return nil
+}
+
+// Close finishes the trace and closes the file opened by
+// CreateTraceRecorder. It is safe to call Close more than once.
+func (r *TraceRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.zw == nil {
+		return nil
+	}
+	err := r.zw.Close()
+	r.zw = nil
+	if r.c != nil {
+		if cerr := r.c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// TraceReader reads a trace written by TraceRecorder.
+type TraceReader struct {
+	Header TraceHeader
+
+	c         io.Closer
+	zr        *gzip.Reader
+	dec       *json.Decoder
+	truncated bool
+}
+
+// OpenTrace opens the trace file at path and reads its header.
+func OpenTrace(path string) (*TraceReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open trace: %w", err)
+	}
+	r, err := NewTraceReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.c = f
+	return r, nil
+}
+
+// NewTraceReader reads a trace from rd and checks its header. Close does
+// not close rd.
+func NewTraceReader(rd io.Reader) (*TraceReader, error) {
+	zr, err := gzip.NewReader(rd)
+	if err != nil {
+		return nil, fmt.Errorf("read trace: %w", err)
+	}
+	r := &TraceReader{zr: zr, dec: json.NewDecoder(zr)}
+	if err := r.dec.Decode(&r.Header); err != nil {
+		return nil, fmt.Errorf("read trace header: %w", err)
+	}
+	if r.Header.Format != traceFormat {
+		return nil, fmt.Errorf("read trace: not a trace file (format %q)", r.Header.Format)
+	}
+	if r.Header.Version > traceVersion {
+		return nil, fmt.Errorf("read trace: unsupported version %d", r.Header.Version)
+	}
+	return r, nil
+}
+
+// Next returns the next record, or io.EOF at the end of the trace. A trace
+// cut short by a crash ends at its last complete record; Truncated then
+// reports true.
+func (r *TraceReader) Next() (TraceRecord, error) {
+	var rec TraceRecord
+	err := r.dec.Decode(&rec)
+	switch {
+	case err == nil:
+		return rec, nil
+	case errors.Is(err, io.EOF):
+		return TraceRecord{}, io.EOF
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		r.truncated = true
+		return TraceRecord{}, io.EOF
+	default:
+		return TraceRecord{}, fmt.Errorf("read trace record: %w", err)
+	}
+}
+
+// Truncated reports whether the trace ended in an incomplete record.
+func (r *TraceReader) Truncated() bool { return r.truncated }
+
+// Close closes the file opened by OpenTrace.
+func (r *TraceReader) Close() error {
+	if r.c == nil {
+		return nil
+	}
+	return r.c.Close()
+}
+
+// openTraceRecorder opens the recorder configured by TraceRecordPath, if any.
+func openTraceRecorder(cfg config.ProfilerConfig) (*TraceRecorder, error) {
+	if cfg.TraceRecordPath == "" {
+		return nil, nil
+	}
+	return CreateTraceRecorder(cfg.TraceRecordPath)
+}
+
+// traceDeltaLocked accounts count, bytes and sampled allocations of a
+// series merged for the next trace record. Caller must hold p.mu.
+func (p *Profiler) traceDeltaLocked(key string, e *shardEntry, count, bytes, sampled uint64) {
+	a, ok := p.traceAllocs[key]
+	if !ok {
+		a = &TraceAlloc{TypeName: e.typeName, Tag: e.tag, SizeSource: e.source}
+		p.traceAllocs[key] = a
+	}
+	a.Count += count
+	a.Bytes += bytes
+	a.Sampled += sampled
+}
+
+// traceRecordLocked builds the trace record of the sample ms taken at now
+// from the merged deltas and live totals, and resets the deltas. Caller must
+// hold p.mu.
+func (p *Profiler) traceRecordLocked(ms *memSample, now time.Time) *TraceRecord {
+	for key, lc := range p.live {
+		a, ok := p.traceAllocs[key]
+		if !ok {
+			stat := p.allocs[key]
+			if stat == nil {
+				continue
+			}
+			a = &TraceAlloc{TypeName: stat.TypeName, Tag: stat.Tag, SizeSource: stat.SizeSource}
+			p.traceAllocs[key] = a
+		}
+		a.LiveObjects, a.LiveBytes = lc.objects, lc.bytes
+	}
+	rec := &TraceRecord{At: now, Mode: ms.mode, Mem: traceMemFromSample(ms), SelfOverheadPercent: p.selfOverhead(ms)}
+	keys := make([]string, 0, len(p.traceAllocs))
+	for key := range p.traceAllocs {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		rec.Allocs = append(rec.Allocs, *p.traceAllocs[key])
+	}
+	clear(p.traceAllocs)
+	return rec
+}
+
+// recordTrace appends rec to the trace recorder outside p.mu.
+func (p *Profiler) recordTrace(rec *TraceRecord) {
+	if err := p.recorder.Record(*rec); err != nil {
+		p.logger.Warn("trace record failed", "error", err)
+	}
+}
+
+// ReplayOptions configures Profiler.Replay.
+type ReplayOptions struct {
+	// Speed divides the recorded time between samples: 60 replays an hour
+	// in a minute. 0 replays as fast as possible.
+	Speed float64
+
+	// OnSample, if set, is called with the snapshot of every replayed
+	// record.
+	OnSample func(ProfilerSnapshot)
+}
+
+// Replay feeds the records of tr through the profiler in place of runtime
+// samples, as if they had been sampled at their recorded times, and returns
+// the number of records replayed. The profiler should be built with a
+// ManualClock, which Replay advances to each record's time so that windows
+// and history queries see the recorded timeline, and should not be started.
+// The GC controller and heap capture act on the current process and should
+// be disabled while replaying.
+func (p *Profiler) Replay(ctx context.Context, tr *TraceReader, opts ReplayOptions) (int, error) {
+	clock, _ := p.clock.(*ManualClock)
+	var prev time.Time
+	n := 0
+	for {
+		rec, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+
+		var wait time.Duration
+		if opts.Speed > 0 && !prev.IsZero() {
+			wait = time.Duration(float64(rec.At.Sub(prev)) / opts.Speed)
+		}
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return n, ctx.Err()
+			case <-t.C:
+			}
+		} else if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		prev = rec.At
+
+		if clock != nil {
+			clock.AdvanceTo(rec.At)
+		}
+		snap := p.replayRecord(&rec)
+		n++
+		if opts.OnSample != nil {
+			opts.OnSample(snap)
+		}
+	}
+}
+
+// replayRecord takes one sample from rec. Its allocation deltas go through
+// the shards like TrackAllocation calls; its live totals replace those of
+// the shards after the merge. The replay's own cost is still accounted in
+// SelfStats.
+func (p *Profiler) replayRecord(rec *TraceRecord) ProfilerSnapshot {
+	p.sampleMu.Lock()
+	defer p.sampleMu.Unlock()
+
+	start := time.Now()
+	for _, a := range rec.Allocs {
+		key := a.TypeName + "|" + a.Tag
+		if p.sketch != nil {
+			if a.Count > 0 || a.Bytes > 0 {
+				p.sketch.add(key, a.TypeName, a.Tag, a.SizeSource, a.Count, a.Bytes)
+			}
+			continue
+		}
+		e := p.entryFor(key, a.TypeName, a.Tag, a.SizeSource)
+		e.count.Add(a.Count)
+		e.bytes.Add(a.Bytes)
+		e.sampled.Add(a.Sampled)
+	}
+
+	ms := rec.Mem.memSample()
+	ms.mode = rec.Mode
+	if ms.mode == "" {
+		ms.mode = SamplingModeInterval
+	}
+	ms.replay = rec
+	return p.processSample(&ms, rec.At.UTC(), start)
+}
+
+// selfOverhead is the self-overhead reported with the sample ms: the
+// recorded one for replayed samples.
+func (p *Profiler) selfOverhead(ms *memSample) float64 {
+	if ms.replay != nil {
+		return ms.replay.SelfOverheadPercent
+	}
+	return p.self.overhead.Load()
+}
@@ -2,8 +2,12 @@ package profiler
 
 import (
 	"fmt"
+	"math/rand/v2"
 	"reflect"
+	"runtime"
+	"sync"
 	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -16,6 +20,10 @@ func nextID(prefix string) string {
 }
 
 func trackAllocation(p *Profiler, obj any, tag string) {
+	if p.sampler.skipCall() {
+		return
+	}
+
 	typ := reflect.TypeOf(obj)
 	if typ == nil {
 		return
@@ -26,30 +34,155 @@ func trackAllocation(p *Profiler, obj any, tag string) {
 		tag = "default"
 	}
 
-	size := estimateSize(obj, typ)
+	size, source := p.sizeOf(obj, typ)
 	if size == 0 {
 		// Avoid polluting stats with meaningless entries.
 		return
 	}
 
+	w := p.sampler.weight(size)
+	if w == 0 {
+		return
+	}
+	count := roundStochastic(w)
+	bytes := roundStochastic(w * float64(size))
+
 	key := typeName + "|" + tag
 
-	p.mu.Lock()
-	defer p.mu.Unlock()
+	if p.sketch != nil {
+		// Sketch mode keeps no per-series state, so live objects are not
+		// watched either.
+		p.sketch.add(key, typeName, tag, source, count, bytes)
+		return
+	}
 
-	stat, ok := p.allocs[key]
-	if !ok {
-		stat = &AllocationStat{
-			TypeName: typeName,
-			Tag:      tag,
-		}
-		p.allocs[key] = stat
+	// Hot path: no profiler-wide lock. The entry is looked up in a randomly
+	// chosen shard and updated atomically; sampleOnce folds shards into
+	// p.allocs.
+	e := p.entryFor(key, typeName, tag, source)
+	e.count.Add(count)
+	e.bytes.Add(bytes)
+	e.sampled.Add(1)
+	if w > 1 {
+		// Variance of the estimate contributed by this sample: (1-p)/p^2
+		// per unit, i.e. w^2 - w.
+		v := w*w - w
+		e.countVar.Add(v)
+		e.bytesVar.Add(v * float64(size) * float64(size))
 	}
 
-	stat.AllocCount++
-	stat.TotalAllocBytes += size
-	if stat.AllocCount > 0 {
-		stat.AverageAllocBytes = stat.TotalAllocBytes / stat.AllocCount
+	watchLive(obj, typ, &e.live, count, bytes)
+}
+
+// cacheLinePad keeps neighbouring shards on separate cache lines.
+const cacheLinePad = 64
+
+// allocShard holds pending allocation counters for a subset of callers.
+// Shards are picked at random per call, which spreads concurrent updates to
+// the same (type, tag) across cache lines much like per-P counters would.
+type allocShard struct {
+	entries sync.Map // key -> *shardEntry
+	_       [cacheLinePad]byte
+}
+
+// shardEntry accumulates counts for one (type, tag) within a shard. Counters
+// and variances are drained on merge; live is never reset because it tracks
+// objects that are still reachable. source records how the first observed
+// object was sized; overflow marks a series folded by the cardinality limit.
+type shardEntry struct {
+	typeName string
+	tag      string
+	source   string
+	overflow bool
+	count    atomic.Uint64
+	bytes    atomic.Uint64
+	sampled  atomic.Uint64
+	countVar atomicFloat
+	bytesVar atomicFloat
+	live     liveStat
+}
+
+func newAllocShards() []allocShard {
+	n := 1
+	for n < runtime.GOMAXPROCS(0) {
+		n <<= 1
+	}
+	return make([]allocShard, n)
+}
+
+func (p *Profiler) shardFor() *allocShard {
+	return &p.shards[rand.Uint32()&uint32(len(p.shards)-1)]
+}
+
+// mergeAllocsLocked drains pending shard counters into p.allocs, feeds the
+// per-key rate windows and refreshes the per-key live totals. count and bytes
+// are swapped independently, so an update racing with the merge may have its
+// bytes land one merge later; the totals converge on the next sample. Caller
+// must hold p.mu.
+func (p *Profiler) mergeAllocsLocked(now time.Time) {
+	clear(p.live)
+
+	for i := range p.shards {
+		p.shards[i].entries.Range(func(k, v any) bool {
+			key := k.(string)
+			e := v.(*shardEntry)
+
+			n := e.count.Swap(0)
+			b := e.bytes.Swap(0)
+			sampled := e.sampled.Swap(0)
+			countVar := e.countVar.Swap(0)
+			bytesVar := e.bytesVar.Swap(0)
+
+			stat, ok := p.allocs[key]
+			if !ok {
+				stat = &AllocationStat{
+					TypeName:       e.typeName,
+					Tag:            e.tag,
+					SizeSource:     e.source,
+					AccountingMode: AccountingExact,
+					SampleRate:     p.sampler.rate,
+					SampleMode:     p.sampler.mode,
+					overflow:       e.overflow,
+					firstSeen:      now,
+					lastSeen:       now,
+				}
+				p.allocs[key] = stat
+			}
+
+			if n > 0 || b > 0 {
+				stat.lastSeen = now
+				rw, ok := p.rates[key]
+				if !ok {
+					rw = &rateWindow{}
+					p.rates[key] = rw
+				}
+				rw.add(now, n, b)
+			}
+
+			if p.recorder != nil && (n > 0 || b > 0) {
+				p.traceDeltaLocked(key, e, n, b, sampled)
+			}
+
+			stat.AllocCount += n
+			stat.TotalAllocBytes += b
+			stat.SampledCount += sampled
+			if stat.AllocCount > 0 {
+				stat.AverageAllocBytes = stat.TotalAllocBytes / stat.AllocCount
+			}
+			stat.countVar += countVar
+			stat.bytesVar += bytesVar
+			stat.AllocCountError = errorBound(stat.countVar)
+			stat.TotalAllocBytesError = errorBound(stat.bytesVar)
+
+			lb, lo := e.live.load()
+			if lb > 0 || lo > 0 {
+				lc := p.live[key]
+				lc.bytes += lb
+				lc.objects += lo
+				p.live[key] = lc
+			}
+			return true
+		})
 	}
 }
 
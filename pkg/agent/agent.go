@@ -42,10 +42,13 @@ func Start(cfg pkgcfg.ProfilerConfig, logger pkglog.Logger) (*Agent, error) {
 
 	stop := func(shutdownCtx context.Context) error {
 		cancel()
+		err := p.Close()
 		if pprofSrv != nil {
-			return pprofSrv.Shutdown(shutdownCtx)
+			if serr := pprofSrv.Shutdown(shutdownCtx); serr != nil {
+				return serr
+			}
 		}
-		return nil
+		return err
 	}
 
 	return &Agent{Profiler: p, Handler: h, PprofServer: pprofSrv, stop: stop}, nil
@@ -6,6 +6,9 @@ import internal "github.com/abhishekchauhan17/goprof-optimizer/internal/config"
 // It aliases the internal type to keep a single source of truth.
 type ProfilerConfig = internal.ProfilerConfig
 
+// MaxDeepSizeNodes is the hard cap on values a single deep size walk visits.
+const MaxDeepSizeNodes = internal.MaxDeepSizeNodes
+
 // DefaultConfig returns sane defaults suitable for local development.
 func DefaultConfig() ProfilerConfig { return internal.DefaultConfig() }
 
@@ -13,6 +13,7 @@ import (
 type Tagger func(r *http.Request) string
 
 // DefaultTagger returns method + path (e.g., "GET /users/:id" if your router sets Path). For net/http, it's the raw URL.Path.
+// Raw paths with IDs create one series per URL; max_alloc_series bounds that, but a route-based Tagger is preferable.
 func DefaultTagger() Tagger {
 	return func(r *http.Request) string {
 		m := r.Method
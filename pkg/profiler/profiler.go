@@ -1,7 +1,10 @@
 package profiler
 
 import (
+	"io"
 	"net/http"
+	"reflect"
+	"time"
 
 	internalcfg "github.com/abhishekchauhan17/goprof-optimizer/internal/config"
 	internallog "github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
@@ -19,10 +22,233 @@ type RetentionStat = internalprof.RetentionStat
 
 type OptimizationSuggestion = internalprof.OptimizationSuggestion
 
+// Sizer can be implemented by application types to report their own memory
+// footprint to TrackAllocation instead of relying on reflection.
+type Sizer = internalprof.Sizer
+
+// Size sources reported in AllocationStat.SizeSource.
+const (
+	SizeSourceReflection = internalprof.SizeSourceReflection
+	SizeSourceSizer      = internalprof.SizeSourceSizer
+	SizeSourceRegistered = internalprof.SizeSourceRegistered
+)
+
+// Accounting modes reported in AllocationStat.AccountingMode.
+const (
+	AccountingExact  = internalprof.AccountingExact
+	AccountingSketch = internalprof.AccountingSketch
+)
+
+// Query aggregates a snapshot field over history; see Profiler.Query.
+type Query = internalprof.Query
+
+// QueryResult is returned by Profiler.Query.
+type QueryResult = internalprof.QueryResult
+
+// QueryPoint is one bucket of a stepped QueryResult.
+type QueryPoint = internalprof.QueryPoint
+
+// Aggregations for Query.Agg; percentiles are written "p<N>" (e.g. "p95").
+const (
+	AggMin   = internalprof.AggMin
+	AggMax   = internalprof.AggMax
+	AggAvg   = internalprof.AggAvg
+	AggDelta = internalprof.AggDelta
+	AggRate  = internalprof.AggRate
+)
+
+// ErrInvalidQuery is wrapped by Profiler.Query errors caused by bad input.
+var ErrInvalidQuery = internalprof.ErrInvalidQuery
+
+// DiffQuery selects the two sides of Profiler.Diff.
+type DiffQuery = internalprof.DiffQuery
+
+// SnapshotDiff is returned by Profiler.Diff.
+type SnapshotDiff = internalprof.SnapshotDiff
+
+// LeakReport is returned by Profiler.DetectLeaks and Profiler.Leaks.
+type LeakReport = internalprof.LeakReport
+
+// LeakTrend is one fitted series of a LeakReport.
+type LeakTrend = internalprof.LeakTrend
+
+// GCTuningReport is returned by Profiler.AdviseGCTuning and Profiler.GCTuning.
+type GCTuningReport = internalprof.GCTuningReport
+
+// GCSettings is a GOGC/GOMEMLIMIT configuration of a GCTuningReport.
+type GCSettings = internalprof.GCSettings
+
+// GCControllerStatus is returned by Profiler.GCController and
+// Profiler.KillGCController.
+type GCControllerStatus = internalprof.GCControllerStatus
+
+// GCAdjustment is one audit log entry of a GCControllerStatus.
+type GCAdjustment = internalprof.GCAdjustment
+
+// SelfStats is returned by Profiler.SelfStats.
+type SelfStats = internalprof.SelfStats
+
+// FragmentationStats is recorded in snapshots.
+type FragmentationStats = internalprof.FragmentationStats
+
+// SizeClassStat is returned by Profiler.SizeClasses.
+type SizeClassStat = internalprof.SizeClassStat
+
+// OOMForecast is returned by Profiler.Forecast and recorded in snapshots.
+type OOMForecast = internalprof.OOMForecast
+
+// Sources of OOMForecast.LimitSource.
+const (
+	LimitSourceGOMemLimit = internalprof.LimitSourceGOMemLimit
+	LimitSourceCgroup     = internalprof.LimitSourceCgroup
+)
+
+// Sampling modes recorded in ProfilerSnapshot.SamplingMode.
+const (
+	SamplingModeInterval = internalprof.SamplingModeInterval
+	SamplingModeGC       = internalprof.SamplingModeGC
+)
+
+// Suggestion kinds reported in OptimizationSuggestion.Kind.
+const (
+	SuggestionRetention   = internalprof.SuggestionRetention
+	SuggestionLeak        = internalprof.SuggestionLeak
+	SuggestionNonGoMemory = internalprof.SuggestionNonGoMemory
+	SuggestionGCTuning    = internalprof.SuggestionGCTuning
+	SuggestionHeapIdle    = internalprof.SuggestionHeapIdle
+	SuggestionAllocChurn  = internalprof.SuggestionAllocChurn
+)
+
+// HistoryStore persists snapshot history across restarts.
+type HistoryStore = internalprof.HistoryStore
+
+// StateStore is a HistoryStore that also keeps allocation series and
+// suggestions across restarts. Both built-in stores implement it.
+type StateStore = internalprof.StateStore
+
+// ProfilerState is what a StateStore keeps.
+type ProfilerState = internalprof.ProfilerState
+
+// PersistedAllocation is one allocation series in a ProfilerState.
+type PersistedAllocation = internalprof.PersistedAllocation
+
+// FileStoreOptions configures OpenFileStore.
+type FileStoreOptions = internalprof.FileStoreOptions
+
+// Options configures NewWithOptions.
+type Options = internalprof.Options
+
+// Clock supplies the profiler's notion of time; see Options.Clock.
+type Clock = internalprof.Clock
+
+// Ticker is returned by Clock.NewTicker.
+type Ticker = internalprof.Ticker
+
+// MemStatsSource supplies the memory statistics the profiler samples; see
+// Options.MemStats.
+type MemStatsSource = internalprof.MemStatsSource
+
+// MemStatsFunc adapts a function to MemStatsSource.
+type MemStatsFunc = internalprof.MemStatsFunc
+
+// ManualClock is a Clock that only moves when advanced.
+type ManualClock = internalprof.ManualClock
+
+// SystemClock returns the Clock backed by the time package.
+func SystemClock() Clock { return internalprof.SystemClock() }
+
+// NewManualClock returns a ManualClock set to start.
+func NewManualClock(start time.Time) *ManualClock { return internalprof.NewManualClock(start) }
+
+// TraceRecorder records raw samples for replay; see Options.Recorder.
+type TraceRecorder = internalprof.TraceRecorder
+
+// TraceReader reads a recorded trace for Profiler.Replay.
+type TraceReader = internalprof.TraceReader
+
+// TraceHeader is the first entry of a trace.
+type TraceHeader = internalprof.TraceHeader
+
+// TraceRecord is one raw sample of a trace.
+type TraceRecord = internalprof.TraceRecord
+
+// TraceMem holds the memory statistics of a TraceRecord.
+type TraceMem = internalprof.TraceMem
+
+// TraceAlloc is one allocation series of a TraceRecord.
+type TraceAlloc = internalprof.TraceAlloc
+
+// TraceSizeClass is one size class of a TraceMem.
+type TraceSizeClass = internalprof.TraceSizeClass
+
+// TraceContainer holds the procfs and cgroup values of a TraceMem.
+type TraceContainer = internalprof.TraceContainer
+
+// ReplayOptions configures Profiler.Replay.
+type ReplayOptions = internalprof.ReplayOptions
+
+// CreateTraceRecorder creates (or truncates) a trace file at path.
+func CreateTraceRecorder(path string) (*TraceRecorder, error) {
+	return internalprof.CreateTraceRecorder(path)
+}
+
+// NewTraceRecorder writes a trace to w.
+func NewTraceRecorder(w io.Writer) (*TraceRecorder, error) { return internalprof.NewTraceRecorder(w) }
+
+// OpenTrace opens a trace file for replay.
+func OpenTrace(path string) (*TraceReader, error) { return internalprof.OpenTrace(path) }
+
+// NewTraceReader reads a trace from r.
+func NewTraceReader(r io.Reader) (*TraceReader, error) { return internalprof.NewTraceReader(r) }
+
 // New constructs a new Profiler.
 func New(cfg internalcfg.ProfilerConfig, logger internallog.Logger) *Profiler {
 	return internalprof.NewProfiler(cfg, logger)
 }
 
+// NewWithStore constructs a Profiler that persists history to store and
+// replays it on construction.
+func NewWithStore(cfg internalcfg.ProfilerConfig, logger internallog.Logger, store HistoryStore) *Profiler {
+	return internalprof.NewProfilerWithStore(cfg, logger, store)
+}
+
+// NewWithOptions constructs a Profiler with an injected history store, clock
+// and memory statistics source; zero Options fields select the defaults.
+func NewWithOptions(cfg internalcfg.ProfilerConfig, logger internallog.Logger, opts Options) *Profiler {
+	return internalprof.NewProfilerWithOptions(cfg, logger, opts)
+}
+
+// OpenFileStore opens an append-only on-disk history store in dir.
+func OpenFileStore(dir string, opts FileStoreOptions) (HistoryStore, error) {
+	s, err := internalprof.OpenFileStore(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewMemoryStore returns an in-process history store.
+func NewMemoryStore(retention time.Duration) HistoryStore {
+	return internalprof.NewMemoryStore(retention)
+}
+
+// RegisterSizeFunc registers fn as the size function for values of type T,
+// for third-party types that cannot implement Sizer. It takes precedence over
+// Sizer and reflection.
+func RegisterSizeFunc[T any](fn func(T) uint64) {
+	if fn == nil {
+		internalprof.RegisterSizeFunc(reflect.TypeFor[T](), nil)
+		return
+	}
+	internalprof.RegisterSizeFunc(reflect.TypeFor[T](), func(obj any) uint64 {
+		return fn(obj.(T))
+	})
+}
+
+// UnregisterSizeFunc removes a size function registered for type T.
+func UnregisterSizeFunc[T any]() {
+	internalprof.RegisterSizeFunc(reflect.TypeFor[T](), nil)
+}
+
 // RegisterPprofHandlers exposes the standard Go pprof handlers under /debug/pprof/.
 func RegisterPprofHandlers(mux *http.ServeMux) { internalprof.RegisterPprofHandlers(mux) }
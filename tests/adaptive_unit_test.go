@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+// waitForInterval waits until the sampling interval reaches want.
+func waitForInterval(t *testing.T, p *profiler.Profiler, want time.Duration) {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if p.SamplingInterval() == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected the sampling interval to reach %v, got %v", want, p.SamplingInterval())
+}
+
+func adaptiveConfig() config.ProfilerConfig {
+	cfg := config.DefaultConfig()
+	cfg.AdaptiveSamplingEnabled = true
+	cfg.SamplingIntervalMs = 20
+	cfg.SamplingMinIntervalMs = 10
+	cfg.SamplingMaxIntervalMs = 80
+	return cfg
+}
+
+func TestAdaptiveSamplingBacksOffOverBudget(t *testing.T) {
+	cfg := adaptiveConfig()
+	// Any sample costs more than this share of the interval.
+	cfg.SamplingOverheadBudgetPercent = 0.0001
+	if err := config.Validate(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	p := profiler.NewProfiler(cfg, logging.Noop())
+	if p.SamplingInterval() != 20*time.Millisecond {
+		t.Fatalf("expected to start at sampling_interval_ms, got %v", p.SamplingInterval())
+	}
+	p.Start(testContext(t))
+
+	waitForInterval(t, p, 80*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	if got := p.LatestSnapshot().SamplingIntervalMs; got != 80 {
+		t.Fatalf("expected snapshots to record the 80ms interval, got %d", got)
+	}
+}
+
+func TestAdaptiveSamplingLengthensWhenQuiet(t *testing.T) {
+	cfg := adaptiveConfig()
+	// Treat any heap or allocation rate movement of the test process as quiet.
+	cfg.SamplingVolatilityPercent = 1e6
+	cfg.SamplingOverheadBudgetPercent = 100
+	p := profiler.NewProfiler(cfg, logging.Noop())
+	p.Start(testContext(t))
+
+	waitForInterval(t, p, 80*time.Millisecond)
+}
+
+func TestAdaptiveSamplingValidation(t *testing.T) {
+	cfg := adaptiveConfig()
+	cfg.SamplingIntervalMs = 100
+	if err := config.Validate(&cfg); err == nil {
+		t.Fatal("expected an error for a sampling interval above sampling_max_interval_ms")
+	}
+	cfg.AdaptiveSamplingEnabled = false
+	if err := config.Validate(&cfg); err != nil {
+		t.Fatalf("expected bounds to be ignored without adaptive sampling, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"fmt"
 	"runtime"
 	"testing"
 	"time"
@@ -25,6 +26,85 @@ func BenchmarkTrackAllocation(b *testing.B) {
 	}
 }
 
+// BenchmarkTrackAllocationParallel measures contention on a single hot
+// (type, tag) at increasing GOMAXPROCS, up to the number of CPUs, so the
+// ns/op of the sub-benchmarks show how throughput scales. Shards are sized
+// from GOMAXPROCS, so each sub-benchmark builds its own profiler.
+func BenchmarkTrackAllocationParallel(b *testing.B) {
+	type Foo struct{ A int }
+
+	for procs := 1; procs <= runtime.NumCPU(); procs *= 2 {
+		b.Run(fmt.Sprintf("procs=%d", procs), func(b *testing.B) {
+			defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+			p := profiler.NewProfiler(config.DefaultConfig(), logging.Noop())
+
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					p.TrackAllocation(Foo{A: i}, "bench")
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkTrackAllocationParallelTags spreads updates over several tags, as
+// a tagging middleware would with multiple routes.
+func BenchmarkTrackAllocationParallelTags(b *testing.B) {
+	cfg := config.DefaultConfig()
+	log := logging.Noop()
+	p := profiler.NewProfiler(cfg, log)
+
+	type Foo struct{ A int }
+	tags := []string{"GET /a", "GET /b", "POST /c", "PUT /d"}
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			p.TrackAllocation(Foo{A: i}, tags[i%len(tags)])
+			i++
+		}
+	})
+}
+
+// BenchmarkTrackAllocationWhileReading tracks allocations while another
+// goroutine keeps reading top allocations, as the HTTP API does.
+func BenchmarkTrackAllocationWhileReading(b *testing.B) {
+	cfg := config.DefaultConfig()
+	log := logging.Noop()
+	p := profiler.NewProfiler(cfg, log)
+
+	type Foo struct{ A int }
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				p.TopAllocations(10)
+			}
+		}
+	}()
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			p.TrackAllocation(Foo{A: i}, "bench")
+			i++
+		}
+	})
+}
+
 func BenchmarkSampleOnce(b *testing.B) {
 	cfg := config.DefaultConfig()
 	log := logging.Noop()
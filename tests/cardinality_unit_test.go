@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+func TestSeriesLimitFoldsIntoOther(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.MaxAllocSeries = 5
+	p := profiler.NewProfiler(cfg, logging.Noop())
+
+	type Foo struct{ A int }
+	for i := 0; i < 10; i++ {
+		p.TrackAllocation(Foo{A: i}, "tag-"+strconv.Itoa(i))
+	}
+
+	top := p.TopAllocations(0)
+	if len(top) != 6 {
+		t.Fatalf("expected 5 series plus overflow, got %d", len(top))
+	}
+
+	var other *profiler.AllocationStat
+	for i := range top {
+		if top[i].Tag == profiler.OverflowTag {
+			other = &top[i]
+		}
+	}
+	if other == nil || other.AllocCount != 5 {
+		t.Fatalf("expected 5 calls folded into overflow series, got %+v", other)
+	}
+
+	if st := p.SeriesStats(); st.FoldedCalls != 5 {
+		t.Fatalf("expected 5 folded calls, got %+v", st)
+	}
+}
+
+func TestSeriesLimitEvictsColdSeries(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SamplingIntervalMs = 20
+	cfg.MaxAllocSeries = 10
+	p := profiler.NewProfiler(cfg, logging.Noop())
+
+	type Foo struct{ A int }
+	for i := 0; i < 10; i++ {
+		p.TrackAllocation(Foo{A: i}, "cold-"+strconv.Itoa(i))
+	}
+
+	p.Start(testContext(t))
+	waitForSample(p, 500*time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for p.SeriesStats().Evicted == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	st := p.SeriesStats()
+	if st.Evicted == 0 || st.Series > 9 {
+		t.Fatalf("expected cold series to be evicted, got %+v", st)
+	}
+
+	// Room has been made, so new series are admitted again.
+	p.TrackAllocation(Foo{A: 1}, "fresh")
+	found := false
+	for _, a := range p.TopAllocations(0) {
+		if a.Tag == "fresh" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected new series to be admitted after eviction")
+	}
+
+	if snap := p.LatestSnapshot(); snap.AllocSeries.Evicted == 0 {
+		t.Fatalf("expected snapshot to report evictions, got %+v", snap.AllocSeries)
+	}
+}
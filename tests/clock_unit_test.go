@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/alerts"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+func TestScriptedHeapTrace(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := profiler.NewManualClock(start)
+
+	// Each sample allocates 128 MiB more; idle memory is never released.
+	var step atomic.Uint64
+	trace := profiler.MemStatsFunc(func(ms *runtime.MemStats) {
+		n := step.Load()
+		ms.HeapAlloc = 100*mib + n*128*mib
+		ms.HeapInuse = ms.HeapAlloc + 10*mib
+		ms.HeapIdle = 400 * mib
+		ms.HeapReleased = 0
+		ms.TotalAlloc = ms.HeapAlloc
+		ms.NumGC = uint32(n)
+	})
+
+	cfg := config.DefaultConfig()
+	p := profiler.NewProfilerWithOptions(cfg, logging.Noop(), profiler.Options{Clock: clock, MemStats: trace})
+
+	for i := range 5 {
+		step.Store(uint64(i))
+		clock.Advance(time.Second)
+		snap := p.SampleNow()
+		if want := start.Add(time.Duration(i+1) * time.Second); !snap.Timestamp.Equal(want) {
+			t.Fatalf("sample %d: expected timestamp %v, got %v", i, want, snap.Timestamp)
+		}
+		if snap.HeapAllocBytes != 100*mib+uint64(i)*128*mib {
+			t.Fatalf("sample %d: unexpected heap %d", i, snap.HeapAllocBytes)
+		}
+	}
+	if got := p.LastSampleTime(); !got.Equal(start.Add(5 * time.Second)) {
+		t.Fatalf("expected last sample at the clock time, got %v", got)
+	}
+
+	snap := p.LatestSnapshot()
+	var idle bool
+	for _, s := range p.Suggestions() {
+		idle = idle || s.Kind == profiler.SuggestionHeapIdle
+	}
+	if !idle {
+		t.Fatalf("expected a heap_idle suggestion, got %+v", p.Suggestions())
+	}
+	var heapHigh bool
+	for _, a := range alerts.BuildAlertsFromSnapshot(snap, p.Suggestions(), cfg, clock.Now()) {
+		heapHigh = heapHigh || a.ID == "heap-high"
+	}
+	if !heapHigh {
+		t.Fatal("expected a heap-high alert for the 612 MiB heap")
+	}
+}
+
+func TestManualClockDrivesSamplingLoop(t *testing.T) {
+	clock := profiler.NewManualClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	var reads atomic.Int32
+	trace := profiler.MemStatsFunc(func(ms *runtime.MemStats) {
+		reads.Add(1)
+		ms.HeapAlloc = 10 * mib
+	})
+
+	cfg := config.DefaultConfig()
+	cfg.SamplingIntervalMs = 1000
+	p := profiler.NewProfilerWithOptions(cfg, logging.Noop(), profiler.Options{Clock: clock, MemStats: trace})
+	p.Start(testContext(t))
+
+	// Nothing is sampled until the clock reaches the first tick.
+	time.Sleep(50 * time.Millisecond)
+	clock.Advance(999 * time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	if n := reads.Load(); n != 0 {
+		t.Fatalf("expected no samples before the first tick, got %d", n)
+	}
+
+	clock.Advance(time.Millisecond)
+	waitForSample(p, time.Second)
+	if got, want := p.LastSampleTime(), clock.Now(); !got.Equal(want) {
+		t.Fatalf("expected a sample at %v, got %v", want, got)
+	}
+}
+
+func TestManualClockThrottlesGCSampling(t *testing.T) {
+	clock := profiler.NewManualClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	var reads atomic.Int32
+	trace := profiler.MemStatsFunc(func(ms *runtime.MemStats) {
+		reads.Add(1)
+		ms.HeapAlloc = 10 * mib
+	})
+
+	cfg := config.DefaultConfig()
+	cfg.SamplingIntervalMs = 1000
+	cfg.SamplingMode = profiler.SamplingModeGC
+	p := profiler.NewProfilerWithOptions(cfg, logging.Noop(), profiler.Options{Clock: clock, MemStats: trace})
+	p.Start(testContext(t))
+
+	// gcSample forces GCs until one is sampled at the clock's time.
+	gcSample := func() bool {
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			runtime.GC()
+			time.Sleep(10 * time.Millisecond)
+			if p.LastSampleTime().Equal(clock.Now()) {
+				return true
+			}
+		}
+		return false
+	}
+	if !gcSample() {
+		t.Fatal("expected a sample after the first GC")
+	}
+
+	// Less than an interval on the profiler's clock: GCs are throttled, no
+	// matter how much wall time passes.
+	n := reads.Load()
+	clock.Advance(500 * time.Millisecond)
+	if gcSample() || reads.Load() != n {
+		t.Fatalf("expected GCs within the interval to be throttled, got %d samples", reads.Load()-n)
+	}
+
+	// A full interval on the profiler's clock, however little wall time.
+	clock.Advance(500 * time.Millisecond)
+	if !gcSample() {
+		t.Fatal("expected a GC sample once the clock passed the interval")
+	}
+}
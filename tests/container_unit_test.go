@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/alerts"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+// fakeTree writes files relative to a temporary directory standing in for a
+// sysfs or procfs mount.
+func fakeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// sampleWithTrees takes one sample with cgroup and procfs read from the
+// given roots.
+func sampleWithTrees(t *testing.T, cgroupRoot, procRoot string) (*profiler.Profiler, config.ProfilerConfig) {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	cfg.SamplingIntervalMs = 20
+	cfg.CgroupRoot = cgroupRoot
+	cfg.ProcRoot = procRoot
+	p := profiler.NewProfiler(cfg, logging.Noop())
+	p.Start(testContext(t))
+	waitForSample(p, 500*time.Millisecond)
+	if p.LastSampleTime().IsZero() {
+		t.Fatal("no sample taken")
+	}
+	return p, cfg
+}
+
+func TestContainerAccountingCgroupV2(t *testing.T) {
+	cgroupRoot := fakeTree(t, map[string]string{
+		"cgroup.controllers":                "cpu memory pids\n",
+		"kubepods/pod1/memory.current":      "805306368\n",
+		"kubepods/pod1/memory.max":          "1073741824\n",
+		"kubepods/pod1/memory.stat":         "anon 700000000\nfile 100000000\ninactive_file 52428800\n",
+		"kubepods/pod1/memory.events":       "low 0\nhigh 0\nmax 3\noom 2\noom_kill 1\n",
+		"kubepods/other/memory.current":     "1\n",
+		"kubepods/other/memory.max":         "max\n",
+		"kubepods/other/memory.events":      "oom_kill 9\n",
+		"kubepods/other/cgroup.controllers": "memory\n",
+	})
+	procRoot := fakeTree(t, map[string]string{
+		"self/cgroup":       "0::/kubepods/pod1\n",
+		"self/status":       "Name:\tapp\nVmHWM:\t 1100000 kB\nVmRSS:\t 1048576 kB\nRssAnon:\t  900000 kB\nRssFile:\t  148576 kB\nRssShmem:\t       0 kB\nThreads:\t12\n",
+		"self/smaps_rollup": "55d0c0000000-7ffd00000000 ---p 00000000 00:00 0    [rollup]\nRss:             1048576 kB\nPss:             1000000 kB\nSwap:               1024 kB\n",
+	})
+
+	p, cfg := sampleWithTrees(t, cgroupRoot, procRoot)
+	snap := p.LatestSnapshot()
+
+	if snap.RSSBytes != 1<<30 || snap.RSSAnonBytes != 900000*1024 || snap.RSSFileBytes != 148576*1024 {
+		t.Fatalf("unexpected RSS: %d anon=%d file=%d", snap.RSSBytes, snap.RSSAnonBytes, snap.RSSFileBytes)
+	}
+	if snap.PSSBytes != 1000000*1024 || snap.SwapBytes != 1<<20 {
+		t.Fatalf("unexpected smaps_rollup values: pss=%d swap=%d", snap.PSSBytes, snap.SwapBytes)
+	}
+	if snap.CgroupVersion != 2 || snap.CgroupUsageBytes != 768*mib || snap.CgroupLimitBytes != 1<<30 {
+		t.Fatalf("unexpected cgroup: v%d usage=%d limit=%d", snap.CgroupVersion, snap.CgroupUsageBytes, snap.CgroupLimitBytes)
+	}
+	if snap.CgroupWorkingSetBytes != 718*mib {
+		t.Fatalf("expected working set of usage minus inactive_file, got %d", snap.CgroupWorkingSetBytes)
+	}
+	if snap.CgroupOOMEvents != 2 || snap.CgroupOOMKills != 1 {
+		t.Fatalf("unexpected OOM counters: events=%d kills=%d", snap.CgroupOOMEvents, snap.CgroupOOMKills)
+	}
+	if snap.NonGoBytes == 0 || snap.NonGoBytes >= snap.RSSBytes {
+		t.Fatalf("expected non-Go memory below RSS, got %d", snap.NonGoBytes)
+	}
+
+	var nonGo *profiler.OptimizationSuggestion
+	sugs := p.Suggestions()
+	for i := range sugs {
+		if sugs[i].Kind == profiler.SuggestionNonGoMemory {
+			nonGo = &sugs[i]
+		}
+	}
+	if nonGo == nil || nonGo.Severity != "critical" {
+		t.Fatalf("expected a critical non-Go memory suggestion, got %+v", sugs)
+	}
+
+	ids := make(map[string]alerts.Alert)
+	for _, a := range alerts.BuildAlertsFromSnapshot(snap, sugs, cfg, time.Now()) {
+		ids[a.ID] = a
+	}
+	if _, ok := ids["non-go-memory"]; !ok {
+		t.Fatalf("expected a non-go-memory alert, got %v", ids)
+	}
+	if _, ok := ids["cgroup-oom-kill"]; !ok {
+		t.Fatalf("expected a cgroup-oom-kill alert, got %v", ids)
+	}
+	if _, ok := ids["cgroup-usage-high"]; ok {
+		t.Fatal("working set is 70% of the limit, unexpected cgroup-usage-high alert")
+	}
+	if _, ok := ids["critical-suggestion--"]; ok {
+		t.Fatal("non-Go memory suggestion was escalated twice")
+	}
+}
+
+func TestContainerAccountingCgroupV1(t *testing.T) {
+	cgroupRoot := fakeTree(t, map[string]string{
+		"memory/memory.usage_in_bytes": "104857600\n",
+		"memory/memory.limit_in_bytes": "9223372036854771712\n",
+		"memory/memory.stat":           "cache 2097152\nrss 100000\ntotal_inactive_file 1048576\n",
+		"memory/memory.oom_control":    "oom_kill_disable 0\nunder_oom 0\noom_kill 4\n",
+	})
+	// The container sees the host's cgroup path, which is not mounted here.
+	procRoot := fakeTree(t, map[string]string{
+		"self/cgroup": "12:cpu,cpuacct:/docker/abc\n11:memory:/docker/abc\n",
+		"self/status": "VmRSS:\t 65536 kB\n",
+	})
+
+	p, _ := sampleWithTrees(t, cgroupRoot, procRoot)
+	snap := p.LatestSnapshot()
+
+	if snap.CgroupVersion != 1 || snap.CgroupUsageBytes != 100*mib || snap.CgroupWorkingSetBytes != 99*mib {
+		t.Fatalf("unexpected cgroup: v%d usage=%d working set=%d", snap.CgroupVersion, snap.CgroupUsageBytes, snap.CgroupWorkingSetBytes)
+	}
+	if snap.CgroupLimitBytes != 0 {
+		t.Fatalf("expected the v1 unlimited value to read as 0, got %d", snap.CgroupLimitBytes)
+	}
+	if snap.CgroupOOMKills != 4 || snap.RSSBytes != 64*mib {
+		t.Fatalf("unexpected kills=%d rss=%d", snap.CgroupOOMKills, snap.RSSBytes)
+	}
+}
+
+func TestContainerAccountingDisabled(t *testing.T) {
+	p, _ := sampleWithTrees(t, "", "")
+	snap := p.LatestSnapshot()
+
+	if snap.RSSBytes != 0 || snap.CgroupVersion != 0 || snap.CgroupLimitBytes != 0 || snap.NonGoBytes != 0 {
+		t.Fatalf("expected no container accounting, got %+v", snap)
+	}
+}
+
+func TestCgroupUsageAlert(t *testing.T) {
+	cfg := config.DefaultConfig()
+	snap := profiler.ProfilerSnapshot{
+		Timestamp:             time.Now(),
+		CgroupLimitBytes:      1 << 30,
+		CgroupWorkingSetBytes: 950 * mib,
+	}
+
+	for _, a := range alerts.BuildAlertsFromSnapshot(snap, nil, cfg, time.Now()) {
+		if a.ID == "cgroup-usage-high" && a.Severity == "critical" {
+			return
+		}
+	}
+	t.Fatal("expected a critical cgroup-usage-high alert")
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+type deepRequest struct {
+	Method  string
+	Headers map[string]string
+	Body    []byte
+}
+
+type deepNode struct {
+	Payload [64]byte
+	Next    *deepNode
+}
+
+func newDeepProfiler(maxNodes, maxBytes int) *profiler.Profiler {
+	cfg := config.DefaultConfig()
+	cfg.DeepSizeEnabled = true
+	cfg.DeepSizeMaxNodes = maxNodes
+	cfg.DeepSizeMaxBytes = maxBytes
+	return profiler.NewProfiler(cfg, logging.Noop())
+}
+
+func trackedBytes(t *testing.T, p *profiler.Profiler) uint64 {
+	t.Helper()
+	top := p.TopAllocations(1)
+	if len(top) != 1 {
+		t.Fatalf("expected 1 allocation entry, got %d", len(top))
+	}
+	return top[0].TotalAllocBytes
+}
+
+func TestDeepSizeFollowsReferences(t *testing.T) {
+	p := newDeepProfiler(0, 0)
+
+	req := &deepRequest{
+		Method:  "POST",
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    make([]byte, 4<<20),
+	}
+	p.TrackAllocation(req, "deep")
+
+	if got := trackedBytes(t, p); got < 4<<20 {
+		t.Fatalf("expected body to be counted, got %d bytes", got)
+	}
+}
+
+func TestDeepSizeUsesCapacity(t *testing.T) {
+	p := newDeepProfiler(0, 0)
+
+	buf := make([]byte, 10, 1000)
+	p.TrackAllocation(buf, "cap")
+
+	if got := trackedBytes(t, p); got != 1000 {
+		t.Fatalf("expected capacity-based size 1000, got %d", got)
+	}
+}
+
+func TestDeepSizeHandlesCyclesAndSharing(t *testing.T) {
+	p := newDeepProfiler(0, 0)
+
+	a := &deepNode{}
+	b := &deepNode{Next: a}
+	a.Next = b
+	p.TrackAllocation(a, "cycle")
+
+	// Two distinct nodes, each counted once.
+	if got := trackedBytes(t, p); got != 2*uint64(sizeOfDeepNode()) {
+		t.Fatalf("expected %d bytes for a 2-node cycle, got %d", 2*sizeOfDeepNode(), got)
+	}
+
+	p2 := newDeepProfiler(0, 0)
+	shared := make([]byte, 1024)
+	pair := struct{ A, B []byte }{A: shared, B: shared}
+	p2.TrackAllocation(&pair, "shared")
+
+	want := 1024 + uint64(unsafe.Sizeof(pair))
+	if got := trackedBytes(t, p2); got != want {
+		t.Fatalf("expected shared slice counted once (%d bytes), got %d", want, got)
+	}
+}
+
+func TestDeepSizeRespectsBudget(t *testing.T) {
+	var head *deepNode
+	for i := 0; i < 1000; i++ {
+		head = &deepNode{Next: head}
+	}
+
+	full := newDeepProfiler(0, 0)
+	full.TrackAllocation(head, "list")
+	limited := newDeepProfiler(10, 0)
+	limited.TrackAllocation(head, "list")
+	byteLimited := newDeepProfiler(0, 1024)
+	byteLimited.TrackAllocation(head, "list")
+
+	if got := trackedBytes(t, full); got != 1000*uint64(sizeOfDeepNode()) {
+		t.Fatalf("expected full list size, got %d", got)
+	}
+	if got := trackedBytes(t, limited); got > 10*uint64(sizeOfDeepNode()) {
+		t.Fatalf("node budget not respected, got %d", got)
+	}
+	if got := trackedBytes(t, byteLimited); got > 1024+uint64(sizeOfDeepNode()) {
+		t.Fatalf("byte budget not respected, got %d", got)
+	}
+}
+
+type deepLink struct {
+	Next *deepLink
+}
+
+func TestDeepSizeHardNodeCap(t *testing.T) {
+	// Each link costs two walk nodes (pointer and struct), so this list is
+	// twice as long as an unlimited walk may follow. A recursive walk would
+	// also nest a million calls deep.
+	var head *deepLink
+	for i := 0; i < config.MaxDeepSizeNodes; i++ {
+		head = &deepLink{Next: head}
+	}
+
+	p := newDeepProfiler(0, 0)
+	p.TrackAllocation(head, "chain")
+
+	link := uint64(unsafe.Sizeof(deepLink{}))
+	if got, want := trackedBytes(t, p), config.MaxDeepSizeNodes/2*link; got != want {
+		t.Fatalf("expected the walk to stop at the hard cap (%d bytes), got %d", want, got)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.DeepSizeMaxNodes = config.MaxDeepSizeNodes + 1
+	if err := config.Validate(&cfg); err == nil {
+		t.Fatal("expected deep_size_max_nodes above the hard cap to be rejected")
+	}
+}
+
+func sizeOfDeepNode() int {
+	return int(unsafe.Sizeof(deepNode{}))
+}
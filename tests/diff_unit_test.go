@@ -0,0 +1,205 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+func TestDiffBetweenSnapshots(t *testing.T) {
+	p, base := newProfilerWithHistory(t, 60, func(i int, s *profiler.ProfilerSnapshot) {
+		s.HeapAllocBytes = uint64(1000 + 10*i)
+		if i < 30 {
+			s.TopAllocations = []profiler.AllocationStat{
+				{TypeName: "[]uint8", Tag: "grow", BytesPerSec: 100},
+				{TypeName: "[]uint8", Tag: "shrink", BytesPerSec: 400},
+				{TypeName: "[]uint8", Tag: "gone", BytesPerSec: 5},
+			}
+			s.TopRetentions = []profiler.RetentionStat{{TypeName: "*tests.Foo", Tag: "cache", RetainedBytes: 1 << 20}}
+		} else {
+			s.TopAllocations = []profiler.AllocationStat{
+				{TypeName: "[]uint8", Tag: "grow", BytesPerSec: 300},
+				{TypeName: "[]uint8", Tag: "shrink", BytesPerSec: 100},
+				{TypeName: "[]uint8", Tag: "new", BytesPerSec: 50},
+			}
+			s.TopRetentions = []profiler.RetentionStat{{TypeName: "*tests.Foo", Tag: "cache", RetainedBytes: 3 << 20}}
+		}
+	})
+
+	d, err := p.Diff(profiler.DiffQuery{From: base.Add(10 * time.Second), To: base.Add(50 * time.Second)})
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+
+	heap := d.Fields["heap_alloc_bytes"]
+	if heap.From != 1100 || heap.To != 1500 || heap.Delta != 400 {
+		t.Fatalf("unexpected heap delta: %+v", heap)
+	}
+
+	if len(d.Appeared) != 1 || d.Appeared[0].Tag != "new" {
+		t.Fatalf("expected series 'new' to appear, got %+v", d.Appeared)
+	}
+	if len(d.Disappeared) != 1 || d.Disappeared[0].Tag != "gone" {
+		t.Fatalf("expected series 'gone' to disappear, got %+v", d.Disappeared)
+	}
+
+	a := d.Allocations
+	if len(a.Growers) == 0 || a.Growers[0].Tag != "grow" || a.Growers[0].Delta != 200 {
+		t.Fatalf("unexpected growers: %+v", a.Growers)
+	}
+	if len(a.Shrinkers) == 0 || a.Shrinkers[0].Tag != "shrink" || a.Shrinkers[0].Delta != -300 {
+		t.Fatalf("unexpected shrinkers: %+v", a.Shrinkers)
+	}
+	if len(a.RelativeGrowers) != 1 || a.RelativeGrowers[0].Percent != 200 {
+		t.Fatalf("unexpected relative growers: %+v", a.RelativeGrowers)
+	}
+	if len(a.RelativeShrinkers) != 1 || a.RelativeShrinkers[0].Percent != -75 {
+		t.Fatalf("unexpected relative shrinkers: %+v", a.RelativeShrinkers)
+	}
+	if r := d.Retentions.Growers; len(r) != 1 || r[0].Tag != "cache" || r[0].Delta != 2<<20 {
+		t.Fatalf("unexpected retention growers: %+v", r)
+	}
+
+	w, err := p.Diff(profiler.DiffQuery{From: base.Add(9 * time.Second), To: base.Add(59 * time.Second), Window: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("windowed diff: %v", err)
+	}
+	if w.FromSamples != 10 || w.Fields["heap_alloc_bytes"].From != 1045 {
+		t.Fatalf("expected windowed average over 10 samples, got %d samples, %+v", w.FromSamples, w.Fields["heap_alloc_bytes"])
+	}
+
+	if _, err := p.Diff(profiler.DiffQuery{From: base.Add(-time.Hour)}); !errors.Is(err, profiler.ErrInvalidQuery) {
+		t.Fatalf("expected ErrInvalidQuery before history, got %v", err)
+	}
+}
+
+func TestDiffFallsBackToRollups(t *testing.T) {
+	// An hour of 1s snapshots; the raw ring keeps only the last 10 minutes.
+	p, base := newProfilerWithHistory(t, 3600, func(i int, s *profiler.ProfilerSnapshot) {
+		s.HeapAllocBytes = uint64(i)
+		s.TopAllocations = []profiler.AllocationStat{{TypeName: "[]uint8", Tag: "buf", BytesPerSec: float64(i)}}
+	})
+
+	at := base.Add(10*time.Minute + 30*time.Second)
+	d, err := p.Diff(profiler.DiffQuery{From: at, To: base.Add(3599 * time.Second)})
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if d.FromTier != profiler.TierMinute || d.ToTier != profiler.TierRaw {
+		t.Fatalf("expected a 1m from side and a raw to side, got %q / %q", d.FromTier, d.ToTier)
+	}
+	if !d.From.Equal(base.Add(10*time.Minute)) || d.FromSamples != 60 {
+		t.Fatalf("expected the bucket holding from, got %s with %d samples", d.From, d.FromSamples)
+	}
+	if heap := d.Fields["heap_alloc_bytes"]; heap.From != 629.5 || heap.To != 3599 {
+		t.Fatalf("unexpected heap delta: %+v", heap)
+	}
+	// Rollups keep no series, so rankings are marked unavailable rather
+	// than reporting every series as new.
+	if !d.SeriesUnavailable || len(d.Appeared) != 0 || len(d.Allocations.Growers) != 0 {
+		t.Fatalf("expected series to be unavailable, got %+v", d)
+	}
+
+	w, err := p.Diff(profiler.DiffQuery{From: at, Window: 2 * time.Minute})
+	if err != nil {
+		t.Fatalf("windowed diff: %v", err)
+	}
+	if w.FromSamples != 180 || w.Fields["heap_alloc_bytes"].From != 569.5 {
+		t.Fatalf("expected the buckets overlapping the window, got %d samples, %+v", w.FromSamples, w.Fields["heap_alloc_bytes"])
+	}
+}
+
+func TestDiffBeyondTopLists(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := profiler.NewManualClock(start)
+	p := profiler.NewProfilerWithOptions(config.DefaultConfig(), logging.Noop(), profiler.Options{Clock: clock})
+
+	// 15 series, so s10..s14 miss the snapshot top lists.
+	trackAll := func() {
+		for i := range 15 {
+			p.TrackAllocation(make([]byte, (20-i)*1024), fmt.Sprintf("s%02d", i))
+		}
+	}
+	trackAll()
+	clock.Advance(10 * time.Second)
+	t1 := p.SampleNow().Timestamp
+
+	// s14 jumps to the top and a new series starts; s08 and s09 fall out of
+	// the top 10 without going away.
+	clock.Advance(2 * time.Minute)
+	trackAll()
+	for range 100 {
+		p.TrackAllocation(make([]byte, 64<<10), "s14")
+	}
+	for range 50 {
+		p.TrackAllocation(make([]byte, 64<<10), "burst")
+	}
+	t2 := p.SampleNow().Timestamp
+
+	d, err := p.Diff(profiler.DiffQuery{From: t1})
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if len(d.Appeared) != 1 || d.Appeared[0].Tag != "burst" {
+		t.Fatalf("expected only 'burst' to appear, got %+v", d.Appeared)
+	}
+	if len(d.Disappeared) != 0 {
+		t.Fatalf("expected nothing to disappear, got %+v", d.Disappeared)
+	}
+	if !slices.ContainsFunc(d.Allocations.Growers, func(c profiler.SeriesChange) bool { return c.Tag == "burst" && c.From == 0 }) {
+		t.Fatalf("expected the new series among growers, got %+v", d.Allocations.Growers)
+	}
+	for _, c := range d.Allocations.Growers {
+		if c.Tag >= "s10" && c.Tag <= "s14" {
+			t.Fatalf("series %s had no From value but was ranked: %+v", c.Tag, c)
+		}
+	}
+
+	// Both sides historical: rank churn in the top lists is not reported.
+	clock.Advance(time.Second)
+	p.SampleNow()
+	h, err := p.Diff(profiler.DiffQuery{From: t1, To: t2})
+	if err != nil {
+		t.Fatalf("historical diff: %v", err)
+	}
+	if len(h.Appeared) != 0 || len(h.Disappeared) != 0 {
+		t.Fatalf("expected no appeared/disappeared from truncated top lists, got %+v / %+v", h.Appeared, h.Disappeared)
+	}
+	for _, c := range h.Allocations.Shrinkers {
+		if c.To == 0 {
+			t.Fatalf("series %s fell out of the top list but was ranked as shrinking to 0", c.Tag)
+		}
+	}
+}
+
+func TestDiffEndpoint(t *testing.T) {
+	h := newTestServer(t)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	for _, tc := range []struct {
+		query string
+		code  int
+	}{
+		{"to=" + now, http.StatusBadRequest},
+		{"from=1714564800", http.StatusBadRequest}, // before any history
+		{"from=" + now + "&window=-1s", http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest("GET", "/v1/metrics/diff?"+tc.query, nil)
+		w := httptest.NewRecorder()
+
+		h.ServeHTTP(w, req)
+
+		if w.Code != tc.code {
+			t.Fatalf("%s: expected %d, got %d", tc.query, tc.code, w.Code)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+func testSnapshot(ts time.Time) profiler.ProfilerSnapshot {
+	return profiler.ProfilerSnapshot{
+		Timestamp:      ts.UTC(),
+		HeapAllocBytes: uint64(ts.Unix()),
+		TopAllocations: []profiler.AllocationStat{{TypeName: "tests.Foo", Tag: "t", TotalAllocBytes: 64}},
+	}
+}
+
+func TestFileStoreReopenAndTornTail(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := profiler.OpenFileStore(dir, profiler.FileStoreOptions{})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	base := time.Now().Add(-time.Minute)
+	for i := 0; i < 3; i++ {
+		if err := s.Append(testSnapshot(base.Add(time.Duration(i) * time.Second))); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	// Simulate a crash in the middle of writing a record.
+	segs, _ := filepath.Glob(filepath.Join(dir, "seg-*.log"))
+	if len(segs) != 1 {
+		t.Fatalf("expected 1 segment, got %v", segs)
+	}
+	f, err := os.OpenFile(segs[0], os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("open segment: %v", err)
+	}
+	_, _ = f.Write([]byte{200, 0, 0, 0, 1, 2, 3, 4, '{', '"'})
+	_ = f.Close()
+
+	s, err = profiler.OpenFileStore(dir, profiler.FileStoreOptions{})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.Append(testSnapshot(base.Add(3 * time.Second))); err != nil {
+		t.Fatalf("append after repair: %v", err)
+	}
+
+	got, err := s.Load(time.Time{})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("expected 4 snapshots after repair, got %d", len(got))
+	}
+	for i, snap := range got {
+		if want := base.Add(time.Duration(i) * time.Second); !snap.Timestamp.Equal(want.UTC()) {
+			t.Fatalf("snapshot %d: expected %v, got %v", i, want, snap.Timestamp)
+		}
+	}
+}
+
+func TestFileStoreRetentionAndCompaction(t *testing.T) {
+	dir := t.TempDir()
+
+	// One record per segment so every append seals the previous segment.
+	s, err := profiler.OpenFileStore(dir, profiler.FileStoreOptions{
+		SegmentBytes: 1,
+		Retention:    time.Hour,
+		CompactAfter: 10 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	now := time.Now()
+	old := now.Add(-30 * time.Minute).Truncate(time.Minute)
+	stamps := []time.Time{
+		now.Add(-2 * time.Hour), // beyond retention
+		old,
+		old.Add(time.Second),
+		now.Add(-2 * time.Second),
+		now.Add(-time.Second),
+	}
+	for _, ts := range stamps {
+		if err := s.Append(testSnapshot(ts)); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "index.json")); err != nil {
+		t.Fatalf("expected index.json: %v", err)
+	}
+
+	s, err = profiler.OpenFileStore(dir, profiler.FileStoreOptions{SegmentBytes: 1})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+
+	got, err := s.Load(time.Time{})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("expected 4 snapshots after retention, got %d", len(got))
+	}
+	if !got[0].Timestamp.Equal(old.UTC()) {
+		t.Fatalf("expected oldest snapshot %v, got %v", old, got[0].Timestamp)
+	}
+	if len(got[0].TopAllocations) != 0 || len(got[1].TopAllocations) != 0 {
+		t.Fatalf("expected compacted snapshots to drop top allocations")
+	}
+	if len(got[3].TopAllocations) != 1 || got[3].HeapAllocBytes == 0 {
+		t.Fatalf("expected recent snapshot to be intact: %+v", got[3])
+	}
+}
+
+func loadTimestamps(t *testing.T, s *profiler.FileStore) []time.Time {
+	t.Helper()
+	snaps, err := s.Load(time.Time{})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	var out []time.Time
+	for _, snap := range snaps {
+		out = append(out, snap.Timestamp)
+	}
+	return out
+}
+
+func TestFileStoreMaintainsWithoutRotation(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	a, b, c, d := base.Add(-2*time.Hour), base.Add(-time.Minute), base, base.Add(time.Hour)
+
+	s, err := profiler.OpenFileStore(dir, profiler.FileStoreOptions{SegmentBytes: 1})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for _, ts := range []time.Time{a, b, c} {
+		if err := s.Append(testSnapshot(ts)); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	// Retention applies on open, before anything is appended or sealed.
+	clock := profiler.NewManualClock(base)
+	opts := profiler.FileStoreOptions{Retention: time.Hour, SyncEvery: 4, Clock: clock}
+	s, err = profiler.OpenFileStore(dir, opts)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if got := loadTimestamps(t, s); len(got) != 2 || !got[0].Equal(b) {
+		t.Fatalf("expected the 2h old segment dropped on open, got %v", got)
+	}
+
+	// And periodically while the active segment fills without rotating.
+	clock.Advance(time.Hour)
+	if err := s.Append(testSnapshot(d)); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if got := loadTimestamps(t, s); len(got) != 2 || !got[0].Equal(c) || !got[1].Equal(d) {
+		t.Fatalf("expected the aged segment dropped on append, got %v", got)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	// Close flushes appends still waiting for a batched fsync.
+	s, err = profiler.OpenFileStore(dir, opts)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	if got := loadTimestamps(t, s); len(got) != 2 || !got[1].Equal(d) {
+		t.Fatalf("expected the batched append after reopen, got %v", got)
+	}
+}
+
+func TestFileStoreLoadDedupesAcrossClockSteps(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	a, b, c := base, base.Add(time.Second), base.Add(2*time.Second)
+
+	s, err := profiler.OpenFileStore(dir, profiler.FileStoreOptions{SegmentBytes: 1})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for _, ts := range []time.Time{a, b, c} {
+		if err := s.Append(testSnapshot(ts)); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	// A compaction that merged the second segment into the first and then
+	// crashed before removing it.
+	segs, _ := filepath.Glob(filepath.Join(dir, "seg-*.log"))
+	if len(segs) != 3 {
+		t.Fatalf("expected 3 segments, got %v", segs)
+	}
+	second, err := os.ReadFile(segs[1])
+	if err != nil {
+		t.Fatalf("read segment: %v", err)
+	}
+	f, err := os.OpenFile(segs[0], os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("open segment: %v", err)
+	}
+	_, _ = f.Write(second)
+	_ = f.Close()
+
+	s, err = profiler.OpenFileStore(dir, profiler.FileStoreOptions{SegmentBytes: 1})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+
+	// The wall clock steps back 30 seconds.
+	stepped := c.Add(-30 * time.Second)
+	if err := s.Append(testSnapshot(stepped)); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	got := loadTimestamps(t, s)
+	want := []time.Time{a, b, c, stepped}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestFileStoreKeepsSnapshotsSharingATimestamp(t *testing.T) {
+	dir := t.TempDir()
+	ts := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// A manual or coarse clock stamps consecutive samples alike; each is a
+	// distinct record and must survive a reload.
+	s, err := profiler.OpenFileStore(dir, profiler.FileStoreOptions{})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for range 2 {
+		if err := s.Append(testSnapshot(ts)); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	s, err = profiler.OpenFileStore(dir, profiler.FileStoreOptions{})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	if err := s.Append(testSnapshot(ts)); err != nil {
+		t.Fatalf("append after reopen: %v", err)
+	}
+	if got := loadTimestamps(t, s); len(got) != 3 {
+		t.Fatalf("expected 3 snapshots with the same timestamp, got %d", len(got))
+	}
+}
+
+func TestProfilerReplaysFileStore(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SamplingIntervalMs = 20
+	cfg.HistoryStore = profiler.HistoryStoreFile
+	cfg.HistoryStoreDir = t.TempDir()
+
+	p1 := profiler.NewProfiler(cfg, logging.Noop())
+	ctx, cancel := context.WithCancel(testContext(t))
+	p1.Start(ctx)
+	time.Sleep(150 * time.Millisecond)
+	cancel()
+	if err := p1.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	before := p1.Snapshots(0)
+	if len(before) == 0 {
+		t.Fatalf("expected snapshots before restart")
+	}
+
+	p2 := profiler.NewProfiler(cfg, logging.Noop())
+	after := p2.Snapshots(0)
+	if len(after) == 0 {
+		t.Fatalf("expected history to be replayed")
+	}
+	if !after[0].Timestamp.Equal(before[0].Timestamp) {
+		t.Fatalf("expected replay to start at %v, got %v", before[0].Timestamp, after[0].Timestamp)
+	}
+	if res := p2.History(time.Now().Add(-2*time.Hour), time.Time{}, time.Minute, 0); len(res.Rollups) == 0 {
+		t.Fatalf("expected replayed snapshots in rollup tiers")
+	}
+}
+
+func TestProfilerSharesMemoryStore(t *testing.T) {
+	cfg := config.DefaultConfig()
+	store := profiler.NewMemoryStore(0)
+
+	p1 := profiler.NewProfilerWithStore(cfg, logging.Noop(), store)
+	_ = store.Append(testSnapshot(time.Now().Add(-time.Second)))
+	_ = p1.Close()
+
+	p2 := profiler.NewProfilerWithStore(cfg, logging.Noop(), store)
+	if got := p2.Snapshots(0); len(got) != 1 {
+		t.Fatalf("expected 1 replayed snapshot, got %d", len(got))
+	}
+}
+
+func TestProfilerRestoresAllocationState(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.HistoryStore = profiler.HistoryStoreFile
+	cfg.HistoryStoreDir = t.TempDir()
+
+	p1 := profiler.NewProfiler(cfg, logging.Noop())
+	for range 10 {
+		p1.TrackAllocation(make([]byte, 1024), "restored")
+	}
+	p1.SampleNow()
+	before := p1.TopAllocations(0)
+	wantSuggestions := len(p1.Suggestions())
+	if err := p1.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	p2 := profiler.NewProfiler(cfg, logging.Noop())
+	after := p2.TopAllocations(0)
+	if len(after) != len(before) || after[0].Tag != "restored" {
+		t.Fatalf("expected the allocation series to be restored, got %+v", after)
+	}
+	if after[0].AllocCount != before[0].AllocCount || after[0].TotalAllocBytes != before[0].TotalAllocBytes {
+		t.Fatalf("expected restored totals %+v, got %+v", before[0], after[0])
+	}
+	if got := len(p2.Suggestions()); got != wantSuggestions {
+		t.Fatalf("expected %d restored suggestions, got %d", wantSuggestions, got)
+	}
+	// Live objects do not outlive the process.
+	if ret := p2.TopRetentions(0); len(ret) != 0 {
+		t.Fatalf("expected no restored retentions, got %+v", ret)
+	}
+
+	// New calls add to the restored totals.
+	p2.TrackAllocation(make([]byte, 1024), "restored")
+	if got := p2.TopAllocations(0)[0].AllocCount; got != before[0].AllocCount+1 {
+		t.Fatalf("expected %d allocations after one more call, got %d", before[0].AllocCount+1, got)
+	}
+	_ = p2.Close()
+}
+
+func TestMemoryStoreKeepsState(t *testing.T) {
+	store := profiler.NewMemoryStore(0)
+	if _, ok, err := store.LoadState(); ok || err != nil {
+		t.Fatalf("expected no state in a new store, got ok=%v err=%v", ok, err)
+	}
+	want := profiler.ProfilerState{
+		Suggestions: []profiler.OptimizationSuggestion{{ID: "s-1", Kind: profiler.SuggestionLeak}},
+	}
+	_ = store.SaveState(want)
+	got, ok, err := store.LoadState()
+	if !ok || err != nil || len(got.Suggestions) != 1 || got.Suggestions[0].ID != "s-1" {
+		t.Fatalf("expected the saved state back, got %+v ok=%v err=%v", got, ok, err)
+	}
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/alerts"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/health"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/metrics"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+// leakingProfiler has an hour of history growing 50 MiB/h from ~200 MiB,
+// with the runtime holding 50 MiB beyond the live heap.
+func leakingProfiler(t *testing.T, cfg config.ProfilerConfig, memLimit, cgroupLimit uint64) *profiler.Profiler {
+	t.Helper()
+
+	trace := heapTrace(50 * mib)
+	p, _ := newProfilerWithHistoryConfig(t, cfg, 3600, func(i int, s *profiler.ProfilerSnapshot) {
+		trace(i, s)
+		s.RuntimeTotalBytes = s.HeapLiveBytes + 50*mib
+		s.GOMemLimitBytes = memLimit
+		s.CgroupLimitBytes = cgroupLimit
+	})
+	p.DetectLeaks()
+	return p
+}
+
+func TestForecastAgainstCgroupLimit(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.OOMAlertMinutes = 24 * 60
+	p := leakingProfiler(t, cfg, 1<<63-1, 1<<30)
+
+	f := p.Forecast()
+	if f.LimitSource != profiler.LimitSourceCgroup || f.LimitBytes != 1<<30 {
+		t.Fatalf("expected the 1 GiB cgroup limit, got %d from %q", f.LimitBytes, f.LimitSource)
+	}
+	// ~720 MiB of headroom at ~50 MiB/h.
+	if f.SecondsToLimit < 12*3600 || f.SecondsToLimit > 17*3600 {
+		t.Fatalf("expected ~14.5h to the limit, got %v", time.Duration(f.SecondsToLimit)*time.Second)
+	}
+	if f.PredictedAt == nil || f.PredictedAt.IsZero() {
+		t.Fatal("expected a predicted time")
+	}
+
+	snap := p.LatestSnapshot()
+	snap.Forecast = f
+	built := alerts.BuildAlertsFromSnapshot(snap, nil, cfg, time.Now())
+	found := false
+	for _, a := range built {
+		if a.ID == "oom-forecast" {
+			found = true
+			if a.Severity != "warning" || a.Source != "forecast" {
+				t.Fatalf("unexpected alert: %+v", a)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("expected an oom-forecast alert, got %+v", built)
+	}
+
+	cfg.OOMAlertMinutes = 60
+	for _, a := range alerts.BuildAlertsFromSnapshot(snap, nil, cfg, time.Now()) {
+		if a.ID == "oom-forecast" {
+			t.Fatalf("limit is hours away, unexpected alert: %+v", a)
+		}
+	}
+}
+
+func TestForecastPrefersLowerGOMemLimit(t *testing.T) {
+	p := leakingProfiler(t, config.DefaultConfig(), 512*mib, 1<<30)
+
+	f := p.Forecast()
+	if f.LimitSource != profiler.LimitSourceGOMemLimit || f.LimitBytes != 512*mib {
+		t.Fatalf("expected the 512 MiB GOMEMLIMIT, got %d from %q", f.LimitBytes, f.LimitSource)
+	}
+	if f.SecondsToLimit < 3*3600 || f.SecondsToLimit > 5.5*3600 {
+		t.Fatalf("expected ~4h to the limit, got %v", time.Duration(f.SecondsToLimit)*time.Second)
+	}
+}
+
+func TestForecastWithoutLimit(t *testing.T) {
+	p := leakingProfiler(t, config.DefaultConfig(), 1<<63-1, 0)
+
+	f := p.Forecast()
+	if f.LimitBytes != 0 || f.SecondsToLimit != -1 {
+		t.Fatalf("expected no forecast, got %+v", f)
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "predicted_at") {
+		t.Fatalf("expected predicted_at to be omitted without a prediction, got %s", b)
+	}
+}
+
+func TestForecastWithoutLeakTrend(t *testing.T) {
+	// Leak detection disabled.
+	cfg := config.DefaultConfig()
+	cfg.LeakWindowSec = 0
+	p := leakingProfiler(t, cfg, 1<<63-1, 1<<30)
+	if rep := p.Leaks(); rep.Heap != nil {
+		t.Fatalf("expected no leak report with leak detection off, got %+v", rep.Heap)
+	}
+	if f := p.Forecast(); f.SecondsToLimit < 12*3600 || f.SecondsToLimit > 17*3600 {
+		t.Fatalf("expected ~14.5h to the limit with leak detection off, got %+v", f)
+	}
+
+	// Leak detection still warming up: 10 minutes of a 1h window.
+	trace := heapTrace(500 * mib)
+	q, _ := newProfilerWithHistoryConfig(t, config.DefaultConfig(), 600, func(i int, s *profiler.ProfilerSnapshot) {
+		trace(i, s)
+		s.RuntimeTotalBytes = s.HeapLiveBytes + 50*mib
+		s.CgroupLimitBytes = 1 << 30
+	})
+	if rep := q.DetectLeaks(); rep.Heap != nil {
+		t.Fatalf("expected leak detection to need more history, got %+v", rep.Heap)
+	}
+	if f := q.Forecast(); f.SecondsToLimit <= 0 || f.GrowthBytesPerHour < 400*mib {
+		t.Fatalf("expected a forecast from 10 minutes of ~500 MiB/h growth, got %+v", f)
+	}
+}
+
+func TestForecastEndpoint(t *testing.T) {
+	cfg := config.DefaultConfig()
+	p := leakingProfiler(t, cfg, 1<<63-1, 1<<30)
+	h := metrics.NewServer(cfg, p, alerts.NewEngine(), health.NewChecker(cfg, p), logging.Noop()).Router()
+
+	req := httptest.NewRequest("GET", "/v1/forecast", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var f profiler.OOMForecast
+	if err := json.Unmarshal(w.Body.Bytes(), &f); err != nil {
+		t.Fatal(err)
+	}
+	if f.LimitBytes != 1<<30 || f.SecondsToLimit <= 0 {
+		t.Fatalf("unexpected forecast: %+v", f)
+	}
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/alerts"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/health"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/metrics"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+var churnSink []*[4]int64
+
+func TestHeapIdleSuggestion(t *testing.T) {
+	p := profiler.NewProfiler(config.DefaultConfig(), logging.Noop())
+	now := time.Now()
+
+	ms := &runtime.MemStats{
+		HeapAlloc:    100 * mib,
+		HeapInuse:    200 * mib,
+		HeapIdle:     500 * mib,
+		HeapReleased: 100 * mib,
+	}
+	var idle *profiler.OptimizationSuggestion
+	sugs := p.GenerateSuggestionsTest(ms, now)
+	for i := range sugs {
+		if sugs[i].Kind == profiler.SuggestionHeapIdle {
+			idle = &sugs[i]
+		}
+	}
+	if idle == nil {
+		t.Fatalf("expected a heap_idle suggestion, got %+v", sugs)
+	}
+	for _, want := range []string{"~400.0 MiB", "200.0% of the 200.0 MiB heap", "100.0 MiB of in-use spans", "debug.FreeOSMemory", "set GOMEMLIMIT"} {
+		if !strings.Contains(idle.Message, want) {
+			t.Fatalf("expected %q in message: %s", want, idle.Message)
+		}
+	}
+
+	// Idle memory the scavenger has released is not flagged.
+	ms.HeapReleased = 480 * mib
+	for _, s := range p.GenerateSuggestionsTest(ms, now) {
+		if s.Kind == profiler.SuggestionHeapIdle {
+			t.Fatalf("unexpected suggestion: %+v", s)
+		}
+	}
+}
+
+func TestSmallObjectChurn(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SamplingIntervalMs = 20
+	cfg.SmallObjectChurnPerSec = 1000
+	p := profiler.NewProfiler(cfg, logging.Noop())
+	p.Start(testContext(t))
+	waitForSample(p, 500*time.Millisecond)
+
+	var churn *profiler.OptimizationSuggestion
+	deadline := time.Now().Add(5 * time.Second)
+	for churn == nil && time.Now().Before(deadline) {
+		for range 10_000 {
+			churnSink = append(churnSink[:0], new([4]int64))
+		}
+		sugs := p.Suggestions()
+		for i := range sugs {
+			if sugs[i].Kind == profiler.SuggestionAllocChurn {
+				churn = &sugs[i]
+			}
+		}
+	}
+	if churn == nil {
+		t.Fatal("expected an alloc_churn suggestion")
+	}
+	if !strings.Contains(churn.Message, "sync.Pool") {
+		t.Fatalf("unexpected message: %s", churn.Message)
+	}
+	if snap := p.LatestSnapshot(); snap.Fragmentation.InuseToAllocRatio < 1 {
+		t.Fatalf("unexpected fragmentation stats: %+v", snap.Fragmentation)
+	}
+
+	h := metrics.NewServer(cfg, p, alerts.NewEngine(), health.NewChecker(cfg, p), logging.Noop()).Router()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/v1/metrics/size-classes", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var classes []profiler.SizeClassStat
+	if err := json.Unmarshal(w.Body.Bytes(), &classes); err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, c := range classes {
+		if c.Size == 31 {
+			t.Fatalf("expected class sizes, not bucket bounds: %+v", c)
+		}
+		if c.Size == 32 && c.Mallocs > 10_000 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected allocations in the 32 B size class, got %+v", classes)
+	}
+}
@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"runtime/debug"
+	"testing"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/alerts"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/health"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/metrics"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+// resetGCSettings sets GOGC=100 without a memory limit and restores the
+// previous settings after the test.
+func resetGCSettings(t *testing.T) {
+	t.Helper()
+
+	gogc := debug.SetGCPercent(100)
+	limit := debug.SetMemoryLimit(math.MaxInt64)
+	t.Cleanup(func() {
+		debug.SetGCPercent(gogc)
+		debug.SetMemoryLimit(limit)
+	})
+}
+
+func TestGCControllerAdjustsAndKills(t *testing.T) {
+	resetGCSettings(t)
+	cfg := config.DefaultConfig()
+	cfg.GCControllerEnabled = true
+	p, _ := newProfilerWithHistoryConfig(t, cfg, 900, gcTrace(0.08, math.MaxInt64, 1<<30))
+	if rep := p.AdviseGCTuning(); rep.Recommended.GOGCPercent != 325 {
+		t.Fatalf("expected the advisor to recommend GOGC=325, got %d", rep.Recommended.GOGCPercent)
+	}
+
+	adj, ok := p.AdjustGC()
+	if !ok {
+		t.Fatal("expected an adjustment")
+	}
+	// The limit is set outright; GOGC moves by at most 25%.
+	if adj.FromMemoryLimitBytes != 0 || adj.ToMemoryLimitBytes != 921*mib {
+		t.Fatalf("unexpected memory limit change: %+v", adj)
+	}
+	if adj.FromGOGCPercent != 100 || adj.ToGOGCPercent != 125 {
+		t.Fatalf("unexpected GOGC change: %+v", adj)
+	}
+	if got := debug.SetMemoryLimit(-1); got != 921*mib {
+		t.Fatalf("expected the runtime limit to be 921 MiB, got %d", got)
+	}
+	if _, ok := p.AdjustGC(); ok {
+		t.Fatal("expected the second adjustment to be rate limited")
+	}
+
+	h := metrics.NewServer(cfg, p, alerts.NewEngine(), health.NewChecker(cfg, p), logging.Noop()).Router()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/v1/gc-controller/kill", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for GET on the kill switch, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/v1/gc-controller/kill", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var st profiler.GCControllerStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if !st.Killed || st.Active || st.GOGCPercent != 100 || st.MemoryLimitBytes != 0 {
+		t.Fatalf("expected the original settings to be restored, got %+v", st)
+	}
+	if st.AdjustmentsTotal != 2 || len(st.Adjustments) != 2 || st.Adjustments[1].ToGOGCPercent != 100 {
+		t.Fatalf("expected the adjustment and the restore in the audit log, got %+v", st.Adjustments)
+	}
+	if _, ok := p.AdjustGC(); ok {
+		t.Fatal("expected no adjustment after the kill switch")
+	}
+}
+
+func TestGCControllerDisabledByDefault(t *testing.T) {
+	resetGCSettings(t)
+	p, _ := newProfilerWithHistory(t, 900, gcTrace(0.08, math.MaxInt64, 1<<30))
+	p.AdviseGCTuning()
+
+	if _, ok := p.AdjustGC(); ok {
+		t.Fatal("expected no adjustment without gc_controller_enabled")
+	}
+	if st := p.GCController(); st.Active || st.AdjustmentsTotal != 0 {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+
+	// Without a controller the unauthenticated kill switch is not served.
+	cfg := config.DefaultConfig()
+	h := metrics.NewServer(cfg, p, alerts.NewEngine(), health.NewChecker(cfg, p), logging.Noop()).Router()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/v1/gc-controller/kill", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for the kill switch with the controller disabled, got %d", w.Code)
+	}
+}
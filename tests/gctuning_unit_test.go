@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/alerts"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/health"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/metrics"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+// gcTrace is 15 minutes of a 200 MiB live heap allocating 20 MiB/s with a GC
+// every 5s at GOGC=100.
+func gcTrace(gcCPU float64, memLimit, cgroupLimit uint64) func(i int, s *profiler.ProfilerSnapshot) {
+	return func(i int, s *profiler.ProfilerSnapshot) {
+		s.NumGC = uint32(i / 5)
+		s.TotalAllocBytes = uint64(i) * 20 * mib
+		s.HeapLiveBytes = 200 * mib
+		s.HeapAllocBytes = 200*mib + uint64(i%5)*20*mib
+		s.HeapInuseBytes = 400 * mib
+		s.RuntimeTotalBytes = 420 * mib
+		s.NextGCBytes = 400 * mib
+		s.GOGCPercent = 100
+		s.GOMemLimitBytes = memLimit
+		s.GCCPUFraction = gcCPU
+		s.CgroupLimitBytes = cgroupLimit
+	}
+}
+
+func TestGCTuningSizesLimitAndRaisesGOGC(t *testing.T) {
+	cfg := config.DefaultConfig()
+	p, _ := newProfilerWithHistoryConfig(t, cfg, 900, gcTrace(0.08, math.MaxInt64, 1<<30))
+
+	rep := p.AdviseGCTuning()
+	cur, rec := rep.Current, rep.Recommended
+	if cur.GCCyclesPerMinute < 11.5 || cur.GCCyclesPerMinute > 12.5 {
+		t.Fatalf("expected ~12 GC cycles/min, got %.2f", cur.GCCyclesPerMinute)
+	}
+	if cur.GOMemLimitBytes != 0 || cur.PeakHeapBytes != 400*mib {
+		t.Fatalf("unexpected current settings: %+v", cur)
+	}
+	// 90% of 1 GiB, rounded down to MiB.
+	if rec.GOMemLimitBytes != 921*mib {
+		t.Fatalf("expected GOMEMLIMIT=921MiB, got %d", rec.GOMemLimitBytes)
+	}
+	// GC CPU is 3.2x the 2.5% target and the live heap fits at GOGC=350.
+	if rec.GOGCPercent != 325 {
+		t.Fatalf("expected GOGC=325, got %d", rec.GOGCPercent)
+	}
+	if rec.GCCyclesPerMinute >= cur.GCCyclesPerMinute/2 || rec.GCCPUFraction >= cur.GCCPUFraction/2 {
+		t.Fatalf("expected far fewer cycles, got %+v", rec)
+	}
+	if rec.PeakHeapBytes != 850*mib {
+		t.Fatalf("expected a 850 MiB peak heap, got %d", rec.PeakHeapBytes)
+	}
+
+	if len(rep.Suggestions) != 1 {
+		t.Fatalf("expected one suggestion, got %+v", rep.Suggestions)
+	}
+	sug := rep.Suggestions[0]
+	if sug.Kind != profiler.SuggestionGCTuning || sug.Severity != "warning" {
+		t.Fatalf("unexpected suggestion: %+v", sug)
+	}
+	if !strings.Contains(sug.Message, "Set GOMEMLIMIT=921MiB, GOGC=325") || !strings.Contains(sug.Message, "peak heap 400.0 MiB -> 850.0 MiB") {
+		t.Fatalf("unexpected message: %s", sug.Message)
+	}
+
+	h := metrics.NewServer(cfg, p, alerts.NewEngine(), health.NewChecker(cfg, p), logging.Noop()).Router()
+	req := httptest.NewRequest("GET", "/v1/suggestions/gc-tuning", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var got profiler.GCTuningReport
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Recommended.GOGCPercent != 325 || len(got.Suggestions) != 1 {
+		t.Fatalf("unexpected report: %+v", got)
+	}
+}
+
+func TestGCTuningWarnsWhenLiveHeapFillsLimit(t *testing.T) {
+	p, _ := newProfilerWithHistory(t, 900, gcTrace(0.03, 230*mib, 0))
+
+	rep := p.AdviseGCTuning()
+	if rep.Recommended.GOGCPercent != 100 || rep.Recommended.GOMemLimitBytes != 230*mib {
+		t.Fatalf("expected no settings change, got %+v", rep.Recommended)
+	}
+	if len(rep.Suggestions) != 1 || rep.Suggestions[0].Severity != "warning" || !strings.Contains(rep.Suggestions[0].Message, "nearly fills") {
+		t.Fatalf("expected a warning about the live heap, got %+v", rep.Suggestions)
+	}
+}
+
+func TestGCTuningWithoutHistory(t *testing.T) {
+	p, _ := newProfilerWithHistory(t, 1, gcTrace(0.02, math.MaxInt64, 0))
+
+	rep := p.AdviseGCTuning()
+	if rep.From != nil || rep.To != nil {
+		t.Fatalf("expected no observation span, got %v..%v", rep.From, rep.To)
+	}
+	b, err := json.Marshal(rep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), `"from"`) || strings.Contains(string(b), `"to"`) {
+		t.Fatalf("expected from/to to be omitted, got %s", b)
+	}
+}
+
+func TestGCTuningQuietWhenOnTarget(t *testing.T) {
+	p, _ := newProfilerWithHistory(t, 900, gcTrace(0.02, math.MaxInt64, 0))
+
+	rep := p.AdviseGCTuning()
+	if len(rep.Suggestions) != 0 || rep.Recommended != rep.Current {
+		t.Fatalf("expected no advice, got %+v", rep)
+	}
+}
@@ -0,0 +1,194 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+func TestHistoryPrunedByRetentionWindow(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SamplingIntervalMs = 50
+	cfg.RetentionWindowSec = 1
+	cfg.MaxHistorySamples = 1000
+
+	p := profiler.NewProfiler(cfg, logging.Noop())
+	p.Start(testContext(t))
+
+	time.Sleep(1500 * time.Millisecond)
+
+	snaps := p.Snapshots(0)
+	if len(snaps) == 0 {
+		t.Fatalf("expected snapshots to be produced")
+	}
+	newest := snaps[len(snaps)-1].Timestamp
+	if age := newest.Sub(snaps[0].Timestamp); age > time.Second {
+		t.Fatalf("expected history within retention window, oldest is %v older than newest", age)
+	}
+	if len(snaps) > 25 {
+		t.Fatalf("expected pruning to bound history, got %d snapshots", len(snaps))
+	}
+}
+
+func TestSnapshotsRange(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SamplingIntervalMs = 20
+	cfg.MaxHistorySamples = 100
+
+	p := profiler.NewProfiler(cfg, logging.Noop())
+	p.Start(testContext(t))
+
+	time.Sleep(200 * time.Millisecond)
+
+	all := p.Snapshots(0)
+	if len(all) < 4 {
+		t.Fatalf("expected at least 4 snapshots, got %d", len(all))
+	}
+	since, until := all[1].Timestamp, all[2].Timestamp
+
+	got := p.SnapshotsRange(since, until, 0)
+	if len(got) != 2 || !got[0].Timestamp.Equal(since) || !got[1].Timestamp.Equal(until) {
+		t.Fatalf("expected the 2 snapshots in range, got %d", len(got))
+	}
+
+	if got := p.SnapshotsRange(since, time.Time{}, 1); len(got) != 1 || got[0].Timestamp.Before(all[len(all)-1].Timestamp) {
+		t.Fatalf("expected limit to keep the newest snapshot in range")
+	}
+
+	if got := p.SnapshotsRange(time.Time{}, all[0].Timestamp.Add(-time.Second), 0); len(got) != 0 {
+		t.Fatalf("expected empty range, got %d", len(got))
+	}
+}
+
+func TestHistoryEndpointTimeRange(t *testing.T) {
+	h := newTestServer(t)
+
+	for _, tc := range []struct {
+		query string
+		code  int
+	}{
+		{"from=2024-05-01T12:00:00Z", http.StatusOK},
+		{"from=1714564800&to=1714568400", http.StatusOK},
+		{"from=yesterday", http.StatusBadRequest},
+		{"from=1714568400&to=1714564800", http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest("GET", "/v1/metrics/history?"+tc.query, nil)
+		w := httptest.NewRecorder()
+
+		h.ServeHTTP(w, req)
+
+		if w.Code != tc.code {
+			t.Fatalf("%s: expected %d, got %d", tc.query, tc.code, w.Code)
+		}
+	}
+}
+
+func TestHistoryPicksTier(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SamplingIntervalMs = 20
+	cfg.MaxHistorySamples = 100
+
+	p := profiler.NewProfiler(cfg, logging.Noop())
+	p.Start(testContext(t))
+
+	time.Sleep(150 * time.Millisecond)
+	now := time.Now()
+
+	if res := p.History(time.Time{}, time.Time{}, 0, 0); res.Tier != profiler.TierRaw || len(res.Snapshots) == 0 {
+		t.Fatalf("expected raw snapshots by default, got tier %q with %d snapshots", res.Tier, len(res.Snapshots))
+	}
+
+	res := p.History(now.Add(-2*time.Hour), time.Time{}, time.Minute, 0)
+	if res.Tier != profiler.TierMinute || len(res.Rollups) == 0 {
+		t.Fatalf("expected 1m rollups, got tier %q with %d rollups", res.Tier, len(res.Rollups))
+	}
+	pt := res.Rollups[len(res.Rollups)-1]
+	heap, ok := pt.Fields["heap_alloc_bytes"]
+	if !ok || pt.Samples == 0 {
+		t.Fatalf("expected heap_alloc_bytes rollup, got %+v", pt)
+	}
+	if heap.Min > heap.Avg || heap.Avg > heap.Max || heap.Last < heap.Min || heap.Last > heap.Max {
+		t.Fatalf("inconsistent rollup: %+v", heap)
+	}
+
+	if res := p.History(now.Add(-48*time.Hour), time.Time{}, 0, 0); res.Tier != profiler.TierHour {
+		t.Fatalf("expected 1h tier for a 2 day range, got %q", res.Tier)
+	}
+
+	// A coarse step over a short range still uses the coarsest fitting tier.
+	if res := p.History(now.Add(-time.Minute), time.Time{}, 2*time.Hour, 0); res.Tier != profiler.TierHour {
+		t.Fatalf("expected 1h tier for a 2h step, got %q", res.Tier)
+	}
+}
+
+func TestHistoryRawTierFollowsRing(t *testing.T) {
+	// Configured for 1s samples, but sampled every 10s (as adaptive or
+	// GC-triggered sampling would), so 100 snapshots span 990s.
+	cfg := config.DefaultConfig()
+	cfg.SamplingIntervalMs = 1000
+	cfg.MaxHistorySamples = 100
+	cfg.RetentionWindowSec = 3600
+	clock := profiler.NewManualClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	p := profiler.NewProfilerWithOptions(cfg, logging.Noop(), profiler.Options{Clock: clock})
+	for range 100 {
+		clock.Advance(10 * time.Second)
+		p.SampleNow()
+	}
+
+	res := p.History(clock.Now().Add(-500*time.Second), time.Time{}, 0, 0)
+	if res.Tier != profiler.TierRaw || res.Step != "10s" {
+		t.Fatalf("expected the raw tier at a 10s step to cover 500s, got tier %q step %q", res.Tier, res.Step)
+	}
+	if len(res.Snapshots) != 51 {
+		t.Fatalf("expected 51 raw snapshots, got %d", len(res.Snapshots))
+	}
+}
+
+func TestHistoryEndpointRollups(t *testing.T) {
+	h := newTestServer(t)
+
+	from := time.Now().Add(-3 * time.Hour).UTC().Format(time.RFC3339)
+	req := httptest.NewRequest("GET", "/v1/metrics/history?step=1m&from="+from, nil)
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if tier := w.Header().Get("X-Goprof-History-Tier"); tier != profiler.TierMinute {
+		t.Fatalf("expected 1m tier, got %q", tier)
+	}
+	var res profiler.HistoryResult
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("expected a history envelope: %v", err)
+	}
+	if res.Tier != profiler.TierMinute || res.Step != "1m0s" || res.Snapshots != nil {
+		t.Fatalf("unexpected envelope: tier %q step %q, %d rollups", res.Tier, res.Step, len(res.Rollups))
+	}
+
+	// Without step, an old from still gets the plain snapshot array.
+	req = httptest.NewRequest("GET", "/v1/metrics/history?from="+from, nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	var snaps []profiler.ProfilerSnapshot
+	if err := json.Unmarshal(w.Body.Bytes(), &snaps); err != nil {
+		t.Fatalf("expected a snapshot array without step: %v", err)
+	}
+	if tier := w.Header().Get("X-Goprof-History-Tier"); tier != profiler.TierRaw {
+		t.Fatalf("expected raw tier without step, got %q", tier)
+	}
+
+	req = httptest.NewRequest("GET", "/v1/metrics/history?step=-1s", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid step, got %d", w.Code)
+	}
+}
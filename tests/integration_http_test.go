@@ -18,7 +18,7 @@ func TestHTTPIntegration(t *testing.T) {
 	log := logging.Noop()
 
 	p := profiler.NewProfiler(cfg, log)
-	p.Start(testContext())
+	p.Start(testContext(t))
 
 	alertEng := alerts.NewEngine()
 	hc := health.NewChecker(cfg, p)
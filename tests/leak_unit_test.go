@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/alerts"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+const mib = 1 << 20
+
+// heapTrace fills a GC sawtooth whose post-GC baseline grows by growth bytes
+// per hour, with noise and occasional outliers.
+func heapTrace(growth float64) func(i int, s *profiler.ProfilerSnapshot) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	return func(i int, s *profiler.ProfilerSnapshot) {
+		base := 200*mib + growth*float64(i)/3600 + rng.Float64()*4*mib
+		if i%97 == 0 {
+			base += 300 * mib
+		}
+		s.NumGC = uint32(i / 5)
+		s.HeapLiveBytes = uint64(base)
+		s.HeapAllocBytes = uint64(base) + uint64(i%5)*20*mib
+	}
+}
+
+func TestDetectLeaksFindsHeapGrowth(t *testing.T) {
+	p, _ := newProfilerWithHistory(t, 3600, heapTrace(50*mib))
+
+	rep := p.DetectLeaks()
+	if rep.Heap == nil {
+		t.Fatal("expected a heap trend")
+	}
+	if rep.Tier != profiler.TierMinute {
+		t.Fatalf("expected the 1m tier for a 1h window, got %q", rep.Tier)
+	}
+	h := rep.Heap
+	if h.GrowthBytesPerHour < 45*mib || h.GrowthBytesPerHour > 55*mib {
+		t.Fatalf("expected ~50 MiB/h, got %.1f MiB/h", h.GrowthBytesPerHour/mib)
+	}
+	if h.GrowthLowerBytesPerHour > h.GrowthBytesPerHour || h.GrowthUpperBytesPerHour < h.GrowthBytesPerHour {
+		t.Fatalf("interval [%v, %v] does not contain %v", h.GrowthLowerBytesPerHour, h.GrowthUpperBytesPerHour, h.GrowthBytesPerHour)
+	}
+	if !h.Leaking || h.Confidence < 0.99 {
+		t.Fatalf("expected a confident leak, got leaking=%v confidence=%v", h.Leaking, h.Confidence)
+	}
+
+	sugs := p.GenerateSuggestionsTest(&runtime.MemStats{}, time.Now())
+	var leak *profiler.OptimizationSuggestion
+	for i := range sugs {
+		if sugs[i].Kind == profiler.SuggestionLeak {
+			leak = &sugs[i]
+		}
+	}
+	if leak == nil {
+		t.Fatalf("expected a leak suggestion, got %+v", sugs)
+	}
+	if !strings.Contains(leak.Message, "MiB/h") {
+		t.Fatalf("expected growth per hour in message, got %q", leak.Message)
+	}
+
+	cfg := config.DefaultConfig()
+	built := alerts.BuildAlertsFromSnapshot(p.LatestSnapshot(), sugs, cfg, time.Now())
+	found := false
+	for _, a := range built {
+		if a.ID == "leak-heap" && a.Source == "leak" && a.Message == leak.Message {
+			found = true
+		}
+		if a.Source == "suggestion" && strings.Contains(a.Message, "MiB/h") {
+			t.Fatalf("leak reported twice: %+v", a)
+		}
+	}
+	if !found {
+		t.Fatalf("expected a leak-heap alert, got %+v", built)
+	}
+}
+
+func TestDetectLeaksIgnoresFlatHeap(t *testing.T) {
+	p, _ := newProfilerWithHistory(t, 3600, heapTrace(0))
+
+	rep := p.DetectLeaks()
+	if rep.Heap == nil {
+		t.Fatal("expected a heap trend")
+	}
+	if rep.Heap.Leaking {
+		t.Fatalf("flat heap reported as leaking: %+v", *rep.Heap)
+	}
+	for _, s := range p.GenerateSuggestionsTest(&runtime.MemStats{}, time.Now()) {
+		if s.Kind == profiler.SuggestionLeak {
+			t.Fatalf("unexpected leak suggestion: %+v", s)
+		}
+	}
+}
+
+func TestDetectLeaksPerTagRetention(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.LeakWindowSec = 600
+
+	rng := rand.New(rand.NewPCG(3, 4))
+	store := profiler.NewMemoryStore(0)
+	base := time.Now().UTC().Add(-10 * time.Minute)
+	for i := 0; i < 600; i++ {
+		noise := rng.Float64() * mib
+		_ = store.Append(profiler.ProfilerSnapshot{
+			Timestamp:      base.Add(time.Duration(i) * time.Second),
+			NumGC:          uint32(i),
+			HeapLiveBytes:  100 * mib,
+			HeapAllocBytes: 120 * mib,
+			TopRetentions: []profiler.RetentionStat{
+				{TypeName: "*Session", Tag: "cache", RetainedBytes: uint64(10*mib + 100*mib*float64(i)/3600 + noise)},
+				{TypeName: "[]uint8", Tag: "buf", RetainedBytes: uint64(5*mib + noise)},
+			},
+		})
+	}
+	p := profiler.NewProfilerWithStore(cfg, logging.Noop(), store)
+
+	rep := p.DetectLeaks()
+	if rep.Tier != profiler.TierRaw || len(rep.Retentions) != 2 {
+		t.Fatalf("expected 2 raw retention trends, got %d from %q", len(rep.Retentions), rep.Tier)
+	}
+	cache, buf := rep.Retentions[0], rep.Retentions[1]
+	if cache.Tag != "cache" || !cache.Leaking {
+		t.Fatalf("expected cache to lead as a leak, got %+v", cache)
+	}
+	if cache.GrowthBytesPerHour < 90*mib || cache.GrowthBytesPerHour > 110*mib {
+		t.Fatalf("expected ~100 MiB/h, got %.1f MiB/h", cache.GrowthBytesPerHour/mib)
+	}
+	if buf.Leaking {
+		t.Fatalf("flat series reported as leaking: %+v", buf)
+	}
+
+	got := p.Leaks()
+	if len(got.Retentions) != 2 || got.Retentions[0].Tag != "cache" {
+		t.Fatalf("expected Leaks to return the detected report, got %+v", got)
+	}
+
+	cfg.LeakWindowSec = 0
+	p = profiler.NewProfilerWithStore(cfg, logging.Noop(), store)
+	if rep := p.DetectLeaks(); rep.Heap != nil || len(rep.Retentions) != 0 {
+		t.Fatalf("expected no trends when disabled, got %+v", rep)
+	}
+}
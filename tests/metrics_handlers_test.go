@@ -3,6 +3,7 @@ package tests
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/abhishekchauhan17/goprof-optimizer/internal/alerts"
@@ -13,12 +14,12 @@ import (
 	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
 )
 
-func newTestServer() http.Handler {
+func newTestServer(t *testing.T) http.Handler {
 	cfg := config.DefaultConfig()
 	log := logging.Noop()
 
 	p := profiler.NewProfiler(cfg, log)
-	p.Start(testContext())
+	p.Start(testContext(t))
 
 	alertEng := alerts.NewEngine()
 	healthChk := health.NewChecker(cfg, p)
@@ -28,7 +29,7 @@ func newTestServer() http.Handler {
 }
 
 func TestMetricsLatest(t *testing.T) {
-	h := newTestServer()
+	h := newTestServer(t)
 
 	req := httptest.NewRequest("GET", "/v1/metrics/latest", nil)
 	w := httptest.NewRecorder()
@@ -40,8 +41,54 @@ func TestMetricsLatest(t *testing.T) {
 	}
 }
 
+func TestPrometheusExportsRuntimeMetrics(t *testing.T) {
+	h := newTestServer(t)
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	body := w.Body.String()
+	for _, name := range []string{
+		"goprof_heap_live_bytes",
+		"goprof_goroutines",
+		"goprof_gomemlimit_bytes",
+		"goprof_oom_forecast_seconds",
+		"goprof_gc_controller_gogc_percent",
+		"goprof_sampling_interval_seconds",
+		"goprof_gc_cpu_fraction",
+		"goprof_gc_pause_seconds",
+		"goprof_sched_latency_seconds",
+	} {
+		if !strings.Contains(body, name) {
+			t.Fatalf("expected %s in /metrics output", name)
+		}
+	}
+}
+
+func TestTopAllocationsReportsAccountingMode(t *testing.T) {
+	h := newTestServer(t)
+
+	req := httptest.NewRequest("GET", "/v1/metrics/allocations/top", nil)
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("X-Goprof-Accounting-Mode"); got != profiler.AccountingExact {
+		t.Fatalf("expected accounting mode %q, got %q", profiler.AccountingExact, got)
+	}
+}
+
 func TestSuggestionsEndpoint(t *testing.T) {
-	h := newTestServer()
+	h := newTestServer(t)
 
 	req := httptest.NewRequest("GET", "/v1/suggestions", nil)
 	w := httptest.NewRecorder()
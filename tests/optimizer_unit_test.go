@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"runtime"
 	"testing"
 
 	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
@@ -16,11 +17,14 @@ func TestOptimizerGeneratesSuggestions(t *testing.T) {
 	log := logging.Noop()
 	p := profiler.NewProfiler(cfg, log)
 
-	p.TrackAllocation(make([]byte, 1000), "test")
-	p.TrackAllocation(make([]byte, 2000), "test")
+	// Keep the tracked buffers reachable so they count as retained.
+	small := make([]byte, 1000)
+	large := make([]byte, 2000)
+	p.TrackAllocation(small, "test")
+	p.TrackAllocation(large, "test")
 
 	// Start sampling so retention stats and suggestions are computed.
-	p.Start(testContext())
+	p.Start(testContext(t))
 	// Wait deterministically for the first sample.
 	waitForSample(p, 500_000_000) // 500ms
 
@@ -37,4 +41,7 @@ func TestOptimizerGeneratesSuggestions(t *testing.T) {
 	if sugs[0].Message == "" {
 		t.Fatal("expected message")
 	}
+
+	runtime.KeepAlive(small)
+	runtime.KeepAlive(large)
 }
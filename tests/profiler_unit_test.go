@@ -69,7 +69,16 @@ func TestSamplingLoopProducesSnapshots(t *testing.T) {
 		t.Fatalf("expected snapshots to be produced")
 	}
 
-	if snaps[len(snaps)-1].HeapAllocBytes == 0 {
+	last := snaps[len(snaps)-1]
+	if last.HeapAllocBytes == 0 {
 		t.Fatalf("expected heap alloc > 0")
 	}
+
+	if last.Goroutines == 0 || last.RuntimeTotalBytes == 0 || last.StackBytes == 0 {
+		t.Fatalf("expected runtime/metrics fields to be populated: %+v", last)
+	}
+
+	if last.GOGCPercent == 0 || last.GOMemLimitBytes == 0 {
+		t.Fatalf("expected GC settings to be populated: %+v", last)
+	}
 }
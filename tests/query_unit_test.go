@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+// newProfilerWithHistory returns a profiler whose raw history holds one
+// snapshot per second for the last n seconds, built by fill.
+func newProfilerWithHistory(t *testing.T, n int, fill func(i int, s *profiler.ProfilerSnapshot)) (*profiler.Profiler, time.Time) {
+	t.Helper()
+	return newProfilerWithHistoryConfig(t, config.DefaultConfig(), n, fill)
+}
+
+// newProfilerWithHistoryConfig is newProfilerWithHistory with a custom config.
+func newProfilerWithHistoryConfig(t *testing.T, cfg config.ProfilerConfig, n int, fill func(i int, s *profiler.ProfilerSnapshot)) (*profiler.Profiler, time.Time) {
+	t.Helper()
+
+	store := profiler.NewMemoryStore(0)
+	base := time.Now().UTC().Add(-time.Duration(n) * time.Second).Truncate(time.Minute)
+	for i := 0; i < n; i++ {
+		s := profiler.ProfilerSnapshot{Timestamp: base.Add(time.Duration(i) * time.Second)}
+		fill(i, &s)
+		_ = store.Append(s)
+	}
+	return profiler.NewProfilerWithStore(cfg, logging.Noop(), store), base
+}
+
+func TestQueryAggregations(t *testing.T) {
+	p, base := newProfilerWithHistory(t, 100, func(i int, s *profiler.ProfilerSnapshot) {
+		s.HeapAllocBytes = uint64(i + 1)
+	})
+
+	for _, tc := range []struct {
+		agg  string
+		want float64
+	}{
+		{profiler.AggMin, 1},
+		{profiler.AggMax, 100},
+		{profiler.AggAvg, 50.5},
+		{"p50", 50.5},
+		{"p95", 95.05},
+		{profiler.AggDelta, 99},
+		{profiler.AggRate, 1},
+	} {
+		res, err := p.Query(profiler.Query{Field: "heap_alloc_bytes", Agg: tc.agg})
+		if err != nil {
+			t.Fatalf("%s: %v", tc.agg, err)
+		}
+		if res.Tier != profiler.TierRaw || res.Samples != 100 {
+			t.Fatalf("%s: expected 100 raw samples, got %d from %q", tc.agg, res.Samples, res.Tier)
+		}
+		if math.Abs(res.Value-tc.want) > 1e-9 {
+			t.Fatalf("%s: expected %v, got %v", tc.agg, tc.want, res.Value)
+		}
+	}
+
+	res, err := p.Query(profiler.Query{
+		Field: "heap_alloc_bytes",
+		Agg:   profiler.AggMax,
+		From:  base.Add(10 * time.Second),
+		To:    base.Add(29 * time.Second),
+		Step:  10 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("ranged query: %v", err)
+	}
+	if res.Value != 30 || res.Samples != 20 || len(res.Points) != 2 || res.Points[0].Value != 20 {
+		t.Fatalf("unexpected ranged result: %+v", res)
+	}
+}
+
+func TestQueryRejectsInvalidInput(t *testing.T) {
+	p := profiler.NewProfiler(config.DefaultConfig(), logging.Noop())
+
+	for _, q := range []profiler.Query{
+		{Field: "no_such_field", Agg: profiler.AggAvg},
+		{Field: "heap_alloc_bytes", Agg: "median"},
+		{Field: "heap_alloc_bytes", Agg: "p101"},
+		{Field: "heap_alloc_bytes", Agg: profiler.AggAvg, From: time.Now(), To: time.Now().Add(-time.Hour)},
+	} {
+		if _, err := p.Query(q); !errors.Is(err, profiler.ErrInvalidQuery) {
+			t.Fatalf("%+v: expected ErrInvalidQuery, got %v", q, err)
+		}
+	}
+}
+
+func TestQueryEndpoint(t *testing.T) {
+	h := newTestServer(t)
+
+	for _, tc := range []struct {
+		query string
+		code  int
+	}{
+		{"field=heap_alloc_bytes&agg=p95", http.StatusOK},
+		{"field=goroutines&agg=rate&step=1m&from=1714564800", http.StatusOK},
+		{"field=nope", http.StatusBadRequest},
+		{"field=heap_alloc_bytes&step=0s", http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest("GET", "/v1/metrics/query?"+tc.query, nil)
+		w := httptest.NewRecorder()
+
+		h.ServeHTTP(w, req)
+
+		if w.Code != tc.code {
+			t.Fatalf("%s: expected %d, got %d", tc.query, tc.code, w.Code)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+func TestTopAllocationsWindowRates(t *testing.T) {
+	p := profiler.NewProfiler(config.DefaultConfig(), logging.Noop())
+
+	// "bulk" has the larger lifetime total, "chatty" the higher call rate.
+	p.TrackAllocation(make([]byte, 64<<10), "bulk")
+	for i := 0; i < 100; i++ {
+		p.TrackAllocation(make([]byte, 16), "chatty")
+	}
+
+	byBytes := p.TopAllocationsWindow(0, 5*time.Minute, profiler.SortByBytes)
+	if len(byBytes) != 2 || byBytes[0].Tag != "bulk" {
+		t.Fatalf("expected bulk first by bytes, got %+v", byBytes)
+	}
+
+	byRate := p.TopAllocationsWindow(0, 5*time.Minute, profiler.SortByAllocsRate)
+	if byRate[0].Tag != "chatty" {
+		t.Fatalf("expected chatty first by alloc rate, got %+v", byRate)
+	}
+
+	for _, st := range byRate {
+		if st.RateWindow != "5m0s" {
+			t.Fatalf("expected rate window 5m0s, got %q", st.RateWindow)
+		}
+		if st.BytesPerSec <= 0 || st.AllocsPerSec <= 0 {
+			t.Fatalf("expected positive rates, got %+v", st)
+		}
+	}
+}
+
+func TestTopAllocationsEndpointWindow(t *testing.T) {
+	h := newTestServer(t)
+
+	req := httptest.NewRequest("GET", "/v1/metrics/allocations/top?window=5m&sort=bytes_rate", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var out []profiler.AllocationStat
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	for _, q := range []string{"window=banana", "window=1h", "sort=sideways"} {
+		req := httptest.NewRequest("GET", "/v1/metrics/allocations/top?"+q, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", q, w.Code)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+//go:build unix
+
+package tests
+
+import (
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+func TestTrackNonHeapMemory(t *testing.T) {
+	b, err := syscall.Mmap(-1, 0, 1<<16, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		t.Skipf("mmap unavailable: %v", err)
+	}
+	defer syscall.Munmap(b)
+
+	cfg := config.DefaultConfig()
+	cfg.SamplingIntervalMs = 10
+	p := profiler.NewProfiler(cfg, logging.Noop())
+	p.TrackAllocation(b, "mmap")
+	kept := new([1024]byte)
+	p.TrackAllocation(kept, "heap")
+
+	p.Start(testContext(t))
+	waitForSample(p, 500*time.Millisecond)
+
+	var tracked bool
+	for _, a := range p.TopAllocations(10) {
+		tracked = tracked || (a.Tag == "mmap" && a.AllocCount == 1)
+	}
+	if !tracked {
+		t.Fatalf("expected the mmap'd buffer to count as an allocation, got %+v", p.TopAllocations(10))
+	}
+	// The GC does not manage the mapping, so it is never counted as live;
+	// heap objects tracked alongside it still are.
+	var heapLive bool
+	for _, r := range p.TopRetentions(10) {
+		if r.Tag == "mmap" {
+			t.Fatalf("expected no retention for non-heap memory, got %+v", r)
+		}
+		heapLive = heapLive || r.Tag == "heap"
+	}
+	if !heapLive {
+		t.Fatalf("expected retention for the heap object, got %+v", p.TopRetentions(10))
+	}
+	runtime.KeepAlive(kept)
+}
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"runtime"
 	"testing"
 	"time"
 
@@ -17,13 +18,17 @@ func TestRetentionCalculation(t *testing.T) {
 
 	p := profiler.NewProfiler(cfg, log)
 
-	// Create artificial allocation entries
-	p.TrackAllocation([]byte("hello123"), "tag1")
-	p.TrackAllocation([]byte("hello123"), "tag1")
-	p.TrackAllocation([]byte("foobar"), "tag2")
+	// Create artificial allocation entries and keep them reachable so they
+	// count as retained.
+	a := []byte("hello123")
+	b := []byte("hello123")
+	c := []byte("foobar")
+	p.TrackAllocation(a, "tag1")
+	p.TrackAllocation(b, "tag1")
+	p.TrackAllocation(c, "tag2")
 
 	// Start sampling so retention stats are computed.
-	p.Start(testContext())
+	p.Start(testContext(t))
 	// Wait deterministically for the first sample.
 	waitForSample(p, 500*time.Millisecond)
 
@@ -39,4 +44,136 @@ func TestRetentionCalculation(t *testing.T) {
 	if ret[0].RetainedPercent <= 0 {
 		t.Fatalf("expected retained percent > 0")
 	}
+
+	if ret[0].LiveObjects == 0 {
+		t.Fatalf("expected live objects > 0")
+	}
+
+	runtime.KeepAlive(a)
+	runtime.KeepAlive(b)
+	runtime.KeepAlive(c)
+}
+
+func TestRetentionDropsCollectedObjects(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SamplingIntervalMs = 20
+	log := logging.Noop()
+
+	p := profiler.NewProfiler(cfg, log)
+
+	kept := make([]byte, 1<<20)
+	p.TrackAllocation(kept, "kept")
+	p.TrackAllocation(make([]byte, 1<<20), "dropped")
+
+	p.Start(testContext(t))
+	waitForSample(p, 500*time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		runtime.GC()
+		time.Sleep(40 * time.Millisecond)
+
+		tags := map[string]profiler.RetentionStat{}
+		for _, rs := range p.TopRetentions(0) {
+			tags[rs.Tag] = rs
+		}
+		if _, ok := tags["dropped"]; !ok {
+			rs, ok := tags["kept"]
+			if !ok || rs.RetainedBytes != 1<<20 || rs.LiveObjects != 1 {
+				t.Fatalf("unexpected retention for kept object: %+v", rs)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("collected object still reported as retained: %+v", tags["dropped"])
+		}
+	}
+
+	// Lifetime allocation totals are unaffected by collection.
+	if len(p.TopAllocations(0)) != 2 {
+		t.Fatalf("expected 2 allocation entries")
+	}
+
+	runtime.KeepAlive(kept)
+}
+
+func TestRetentionGCAlignedSampling(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SamplingIntervalMs = 20
+	cfg.SamplingMode = profiler.SamplingModeGC
+	p := profiler.NewProfiler(cfg, logging.Noop())
+
+	buf := make([]byte, 1<<20)
+	p.TrackAllocation(buf, "gc-aligned")
+	p.Start(testContext(t))
+
+	var snap profiler.ProfilerSnapshot
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(30 * time.Millisecond)
+		if snap = p.LatestSnapshot(); snap.SamplingMode == profiler.SamplingModeGC {
+			break
+		}
+	}
+	if snap.SamplingMode != profiler.SamplingModeGC {
+		t.Fatalf("expected a GC-aligned snapshot, got mode %q", snap.SamplingMode)
+	}
+	if len(snap.TopRetentions) == 0 || snap.HeapLiveBytes == 0 {
+		t.Fatalf("expected retentions and a live heap, got %+v", snap)
+	}
+	rs := snap.TopRetentions[0]
+	want := 100 * float64(rs.RetainedBytes) / float64(snap.HeapLiveBytes)
+	if rs.RetainedPercent != want {
+		t.Fatalf("expected retention against the live heap (%.4f%%), got %.4f%%", want, rs.RetainedPercent)
+	}
+	runtime.KeepAlive(buf)
+}
+
+func TestRetentionCountsRepeatedTracking(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SamplingIntervalMs = 20
+	p := profiler.NewProfiler(cfg, logging.Noop())
+
+	// Live tracking is not keyed by address, so the same buffer tracked
+	// twice is reported as two live objects.
+	buf := make([]byte, 4096)
+	p.TrackAllocation(buf, "twice")
+	p.TrackAllocation(buf, "twice")
+
+	p.Start(testContext(t))
+	waitForSample(p, 500*time.Millisecond)
+
+	ret := p.TopRetentions(0)
+	if len(ret) != 1 || ret[0].Tag != "twice" {
+		t.Fatalf("expected a single retention entry, got %+v", ret)
+	}
+	if ret[0].LiveObjects != 2 || ret[0].RetainedBytes != 2*4096 {
+		t.Fatalf("expected the buffer to count as live twice, got %+v", ret[0])
+	}
+	runtime.KeepAlive(buf)
+}
+
+func TestRetentionIgnoresValues(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SamplingIntervalMs = 20
+	p := profiler.NewProfiler(cfg, logging.Noop())
+
+	type record struct {
+		id   int
+		name string
+	}
+	p.TrackAllocation(record{id: 1, name: "a"}, "struct")
+	p.TrackAllocation("a string value", "string")
+
+	p.Start(testContext(t))
+	waitForSample(p, 500*time.Millisecond)
+
+	// Values passed by copy count as allocations but are never live.
+	if n := len(p.TopAllocations(0)); n != 2 {
+		t.Fatalf("expected 2 allocation entries, got %d", n)
+	}
+	if ret := p.TopRetentions(0); len(ret) != 0 {
+		t.Fatalf("expected no retention for values, got %+v", ret)
+	}
 }
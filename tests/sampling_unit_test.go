@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+func TestSamplingByCallsEstimatesTotals(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.AllocSampleMode = "calls"
+	cfg.AllocSampleRate = 10
+	p := profiler.NewProfiler(cfg, logging.Noop())
+
+	type Foo struct{ A, B int64 }
+	const calls = 100_000
+
+	for i := 0; i < calls; i++ {
+		p.TrackAllocation(Foo{A: int64(i)}, "sampled")
+	}
+
+	top := p.TopAllocations(1)
+	if len(top) != 1 {
+		t.Fatalf("expected 1 allocation entry, got %d", len(top))
+	}
+	st := top[0]
+
+	if st.SampleRate != 10 || st.SampleMode != "calls" {
+		t.Fatalf("unexpected sampling info: %+v", st)
+	}
+	if st.SampledCount == 0 || st.SampledCount >= calls {
+		t.Fatalf("expected a subset of calls to be sampled, got %d", st.SampledCount)
+	}
+	if st.AllocCountError == 0 {
+		t.Fatalf("expected non-zero error bound")
+	}
+
+	// The 95% bound is ~2 sigma; allow 2x that to keep the test stable.
+	if diff := absDiff(st.AllocCount, calls); diff > 2*st.AllocCountError {
+		t.Fatalf("estimate %d too far from %d (bound %d)", st.AllocCount, calls, st.AllocCountError)
+	}
+	if diff := absDiff(st.TotalAllocBytes, calls*16); diff > 2*st.TotalAllocBytesError {
+		t.Fatalf("byte estimate %d too far from %d (bound %d)", st.TotalAllocBytes, calls*16, st.TotalAllocBytesError)
+	}
+}
+
+func TestSamplingByBytesEstimatesTotals(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.AllocSampleMode = "bytes"
+	cfg.AllocSampleRate = 4096
+	p := profiler.NewProfiler(cfg, logging.Noop())
+
+	const calls = 50_000
+	var want uint64
+	for i := 0; i < calls; i++ {
+		n := 64 + (i%32)*64
+		want += uint64(n)
+		p.TrackAllocation(make([]byte, n), "sampled")
+	}
+
+	st := p.TopAllocations(1)[0]
+	if st.SampleMode != "bytes" || st.SampledCount >= calls {
+		t.Fatalf("unexpected sampling info: %+v", st)
+	}
+	if diff := absDiff(st.TotalAllocBytes, want); diff > 2*st.TotalAllocBytesError {
+		t.Fatalf("byte estimate %d too far from %d (bound %d)", st.TotalAllocBytes, want, st.TotalAllocBytesError)
+	}
+	if diff := absDiff(st.AllocCount, calls); diff > 2*st.AllocCountError {
+		t.Fatalf("count estimate %d too far from %d (bound %d)", st.AllocCount, calls, st.AllocCountError)
+	}
+}
+
+func TestSamplingDisabledIsExact(t *testing.T) {
+	cfg := config.DefaultConfig()
+	p := profiler.NewProfiler(cfg, logging.Noop())
+
+	for i := 0; i < 1000; i++ {
+		p.TrackAllocation(make([]byte, 100), "exact")
+	}
+
+	st := p.TopAllocations(1)[0]
+	if st.AllocCount != 1000 || st.TotalAllocBytes != 100_000 {
+		t.Fatalf("expected exact totals, got %+v", st)
+	}
+	if st.SampleRate != 1 || st.AllocCountError != 0 || st.TotalAllocBytesError != 0 {
+		t.Fatalf("expected no sampling error, got %+v", st)
+	}
+}
+
+func absDiff(a, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
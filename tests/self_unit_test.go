@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/alerts"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/health"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/metrics"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+func TestSelfOverheadFirstWindow(t *testing.T) {
+	trace := profiler.MemStatsFunc(func(ms *runtime.MemStats) { ms.HeapAlloc = 10 * mib })
+	p := profiler.NewProfilerWithOptions(config.DefaultConfig(), logging.Noop(), profiler.Options{MemStats: trace})
+	time.Sleep(50 * time.Millisecond)
+
+	// The first window starts when the profiler is built, not at the first
+	// sample, which would make that sample cost 100% of its window.
+	p.SampleNow()
+	if st := p.SelfStats(); st.OverheadPercent <= 0 || st.OverheadPercent >= 50 {
+		t.Fatalf("expected the first sample to be measured against the time since start, got %v%%", st.OverheadPercent)
+	}
+}
+
+func TestSelfStats(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SamplingIntervalMs = 20
+	p := profiler.NewProfiler(cfg, logging.Noop())
+
+	tags := []string{"a", "b", "c", "d", "e"}
+	kept := make([]*[64]byte, 0, 10_000)
+	for i := range 10_000 {
+		obj := new([64]byte)
+		kept = append(kept, obj)
+		p.TrackAllocation(obj, tags[i%len(tags)])
+	}
+	p.Start(testContext(t))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for p.SelfStats().SamplesTotal < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	h := metrics.NewServer(cfg, p, alerts.NewEngine(), health.NewChecker(cfg, p), logging.Noop()).Router()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/v1/self", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var st profiler.SelfStats
+	if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.SamplesTotal < 2 || st.SampleSecondsTotal <= 0 || st.LastSampleSeconds <= 0 {
+		t.Fatalf("expected sampling costs, got %+v", st)
+	}
+	// Calls are counted exactly; only their time is estimated.
+	if st.TrackAllocationCallsTotal != 10_000 || st.TrackAllocationSecondsTotal <= 0 {
+		t.Fatalf("expected 10000 calls and an estimated TrackAllocation cost, got %+v", st)
+	}
+	if st.Series != len(tags) || st.HistoryBytes == 0 || st.AllocsBytes == 0 || st.RetentionsBytes == 0 {
+		t.Fatalf("expected memory and series figures, got %+v", st)
+	}
+	if st.OverheadPercent <= 0 || st.OverheadBudgetPercent != cfg.SelfOverheadBudgetPercent {
+		t.Fatalf("expected an overhead figure, got %+v", st)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	for _, name := range []string{
+		"goprof_self_sample_seconds_total",
+		"goprof_self_track_allocation_seconds_total",
+		"goprof_self_lock_wait_seconds_total",
+		`goprof_self_memory_bytes{component="history"}`,
+		"goprof_self_series 5",
+		"goprof_self_overhead_percent",
+	} {
+		if !strings.Contains(w.Body.String(), name) {
+			t.Fatalf("expected %s in /metrics output", name)
+		}
+	}
+	runtime.KeepAlive(kept)
+}
+
+func TestSelfOverheadAlert(t *testing.T) {
+	cfg := config.DefaultConfig()
+	snap := profiler.ProfilerSnapshot{Timestamp: time.Now(), SelfOverheadPercent: 2.5}
+
+	var found bool
+	for _, a := range alerts.BuildAlertsFromSnapshot(snap, nil, cfg, time.Now()) {
+		if a.ID == "self-overhead" && a.Source == "self" && strings.Contains(a.Message, "2.5% of one CPU") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("expected a self-overhead alert over the 1% budget")
+	}
+
+	cfg.SelfOverheadBudgetPercent = 0
+	for _, a := range alerts.BuildAlertsFromSnapshot(snap, nil, cfg, time.Now()) {
+		if a.ID == "self-overhead" {
+			t.Fatal("expected no alert with the budget disabled")
+		}
+	}
+}
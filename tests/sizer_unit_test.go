@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+	pkgprof "github.com/abhishekchauhan17/goprof-optimizer/pkg/profiler"
+)
+
+// ringBuffer reports a preallocated capacity that reflection cannot see.
+type ringBuffer struct {
+	head, tail int
+}
+
+func (r *ringBuffer) SizeBytes() uint64 { return 1 << 16 }
+
+// thirdPartyArena stands in for a type we cannot add methods to.
+type thirdPartyArena struct {
+	chunks int
+}
+
+func TestSizerOverridesReflection(t *testing.T) {
+	p := profiler.NewProfiler(config.DefaultConfig(), logging.Noop())
+
+	p.TrackAllocation(&ringBuffer{}, "ring")
+
+	top := p.TopAllocations(1)
+	if len(top) != 1 {
+		t.Fatalf("expected 1 allocation entry, got %d", len(top))
+	}
+	if top[0].TotalAllocBytes != 1<<16 || top[0].SizeSource != pkgprof.SizeSourceSizer {
+		t.Fatalf("expected Sizer-reported size, got %+v", top[0])
+	}
+}
+
+// fixedArena reports its size through a value receiver, so a nil
+// *fixedArena panics if SizeBytes is called on it.
+type fixedArena struct {
+	chunks int
+}
+
+func (a fixedArena) SizeBytes() uint64 { return uint64(a.chunks) * 4096 }
+
+func TestSizerNilReferences(t *testing.T) {
+	pkgprof.RegisterSizeFunc(func(a *thirdPartyArena) uint64 {
+		return uint64(a.chunks) * 4096
+	})
+	defer pkgprof.UnregisterSizeFunc[*thirdPartyArena]()
+
+	p := profiler.NewProfiler(config.DefaultConfig(), logging.Noop())
+	p.TrackAllocation((*fixedArena)(nil), "nil")
+	p.TrackAllocation((*thirdPartyArena)(nil), "nil")
+	p.TrackAllocation([]fixedArena(nil), "nil")
+	p.TrackAllocation(map[string]fixedArena(nil), "nil")
+
+	p.TrackAllocation(&fixedArena{chunks: 2}, "live")
+
+	// Nil references size as 0 and are not recorded.
+	top := p.TopAllocations(0)
+	if len(top) != 1 || top[0].Tag != "live" || top[0].TotalAllocBytes != 2*4096 {
+		t.Fatalf("expected only the non-nil arena, got %+v", top)
+	}
+}
+
+func TestRegisteredSizeFunc(t *testing.T) {
+	pkgprof.RegisterSizeFunc(func(a *thirdPartyArena) uint64 {
+		return uint64(a.chunks) * 4096
+	})
+	defer pkgprof.UnregisterSizeFunc[*thirdPartyArena]()
+
+	p := profiler.NewProfiler(config.DefaultConfig(), logging.Noop())
+	p.TrackAllocation(&thirdPartyArena{chunks: 3}, "arena")
+	p.TrackAllocation(&ringBuffer{}, "reflect")
+	p.TrackAllocation(make([]byte, 10), "reflect")
+
+	sources := map[string]profiler.AllocationStat{}
+	for _, st := range p.TopAllocations(0) {
+		sources[st.TypeName] = st
+	}
+
+	if st := sources["*tests.thirdPartyArena"]; st.TotalAllocBytes != 3*4096 || st.SizeSource != pkgprof.SizeSourceRegistered {
+		t.Fatalf("expected registered size, got %+v", st)
+	}
+	if st := sources["[]uint8"]; st.SizeSource != pkgprof.SizeSourceReflection {
+		t.Fatalf("expected reflection size source, got %+v", st)
+	}
+}
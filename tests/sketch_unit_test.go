@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+func TestSketchModeFindsHeavyHitters(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.AllocAccountingMode = profiler.AccountingSketch
+	cfg.SketchCapacity = 32
+	p := profiler.NewProfiler(cfg, logging.Noop())
+
+	if p.AccountingMode() != profiler.AccountingSketch {
+		t.Fatalf("expected sketch mode, got %q", p.AccountingMode())
+	}
+
+	const (
+		heavy     = 5
+		heavyHits = 200
+		tenants   = 20000
+	)
+	buf := make([]byte, 4096)
+	for i := 0; i < tenants; i++ {
+		p.TrackAllocation(buf[:64], "tenant-"+strconv.Itoa(i))
+		if i%(tenants/heavyHits) == 0 {
+			for h := 0; h < heavy; h++ {
+				p.TrackAllocation(buf, "heavy-"+strconv.Itoa(h))
+			}
+		}
+	}
+
+	top := p.TopAllocations(heavy)
+	if len(top) != heavy {
+		t.Fatalf("expected %d entries, got %d", heavy, len(top))
+	}
+	for _, st := range top {
+		if st.AccountingMode != profiler.AccountingSketch {
+			t.Fatalf("expected sketch accounting mode, got %q", st.AccountingMode)
+		}
+		if len(st.Tag) < 6 || st.Tag[:6] != "heavy-" {
+			t.Fatalf("expected heavy hitter in top-%d, got %q", heavy, st.Tag)
+		}
+		trueBytes := uint64(heavyHits * len(buf))
+		if st.TotalAllocBytes < trueBytes || st.TotalAllocBytes-st.TotalAllocBytesError > trueBytes {
+			t.Fatalf("bytes %d±%d do not bound true value %d", st.TotalAllocBytes, st.TotalAllocBytesError, trueBytes)
+		}
+		if st.AllocCount < heavyHits || st.AllocCount-st.AllocCountError > heavyHits {
+			t.Fatalf("count %d±%d do not bound true value %d", st.AllocCount, st.AllocCountError, heavyHits)
+		}
+	}
+
+	st := p.SeriesStats()
+	if st.Mode != profiler.AccountingSketch {
+		t.Fatalf("expected sketch mode in series stats, got %q", st.Mode)
+	}
+	// One summary per shard, shards rounded up to a power of two.
+	if st.Series == 0 || st.Series > cfg.SketchCapacity*2*runtime.GOMAXPROCS(0) {
+		t.Fatalf("expected bounded counters, got %+v", st)
+	}
+	want := float64(tenants + heavy)
+	if got := float64(st.DistinctTags); got < want*0.95 || got > want*1.05 {
+		t.Fatalf("distinct tags estimate %v too far from %v", got, want)
+	}
+}
@@ -2,14 +2,17 @@ package tests
 
 import (
 	"context"
+	"testing"
 	"time"
 
 	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
 )
 
 // testContext returns a context that auto-times-out to avoid leaks in tests.
-func testContext() context.Context {
-	ctx, _ := context.WithTimeout(context.Background(), 2*time.Second)
+// It is also cancelled when the test finishes.
+func testContext(t testing.TB) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	t.Cleanup(cancel)
 	return ctx
 }
 
@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"bytes"
+	"runtime"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/alerts"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/config"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/logging"
+	"github.com/abhishekchauhan17/goprof-optimizer/internal/profiler"
+)
+
+var traceSink []*[256]byte
+
+// recordScriptedTrace samples a profiler driven by a scripted heap trace n
+// times, one second apart, tracking 10 live 256-byte objects per sample.
+// flushed, if set, is called with the trace size after every sample.
+func recordScriptedTrace(t *testing.T, cfg config.ProfilerConfig, n int, flushed func(size int)) (*bytes.Buffer, []profiler.ProfilerSnapshot) {
+	t.Helper()
+	var buf bytes.Buffer
+	rec, err := profiler.NewTraceRecorder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := profiler.NewManualClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	var step atomic.Uint64
+	trace := profiler.MemStatsFunc(func(ms *runtime.MemStats) {
+		i := step.Load()
+		ms.HeapAlloc = 100*mib + i*128*mib
+		ms.HeapInuse = ms.HeapAlloc + 10*mib
+		ms.HeapIdle = 400 * mib
+		ms.TotalAlloc = ms.HeapAlloc
+		ms.NumGC = uint32(i)
+	})
+	p := profiler.NewProfilerWithOptions(cfg, logging.Noop(), profiler.Options{Clock: clock, MemStats: trace, Recorder: rec})
+
+	traceSink = traceSink[:0]
+	var snaps []profiler.ProfilerSnapshot
+	for i := range n {
+		for range 10 {
+			obj := new([256]byte)
+			traceSink = append(traceSink, obj)
+			p.TrackAllocation(obj, "orders")
+		}
+		step.Store(uint64(i))
+		clock.Advance(time.Second)
+		snaps = append(snaps, p.SampleNow())
+		if flushed != nil {
+			flushed(buf.Len())
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf, snaps
+}
+
+func alertIDs(snap profiler.ProfilerSnapshot, sugs []profiler.OptimizationSuggestion, cfg config.ProfilerConfig) []string {
+	var ids []string
+	for _, a := range alerts.BuildAlertsFromSnapshot(snap, sugs, cfg, snap.Timestamp) {
+		ids = append(ids, a.ID)
+	}
+	return ids
+}
+
+func TestTraceRecordAndReplay(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.MemorySpikeThresholdPercent = 0.0001
+	buf, recorded := recordScriptedTrace(t, cfg, 5, nil)
+	data := buf.Bytes()
+
+	r, err := profiler.NewTraceReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := profiler.NewManualClock(time.Time{})
+	p := profiler.NewProfilerWithOptions(cfg, logging.Noop(), profiler.Options{Clock: clock})
+	var replayed []profiler.ProfilerSnapshot
+	n, err := p.Replay(testContext(t), r, profiler.ReplayOptions{Speed: 1000, OnSample: func(s profiler.ProfilerSnapshot) {
+		replayed = append(replayed, s)
+	}})
+	if err != nil || n != 5 || r.Truncated() {
+		t.Fatalf("expected 5 replayed samples, got %d (err %v, truncated %v)", n, err, r.Truncated())
+	}
+
+	for i, want := range recorded {
+		got := replayed[i]
+		if !got.Timestamp.Equal(want.Timestamp) || got.HeapAllocBytes != want.HeapAllocBytes || got.HeapInuseBytes != want.HeapInuseBytes {
+			t.Fatalf("sample %d: replayed %v/%d/%d, recorded %v/%d/%d", i,
+				got.Timestamp, got.HeapAllocBytes, got.HeapInuseBytes, want.Timestamp, want.HeapAllocBytes, want.HeapInuseBytes)
+		}
+		if len(got.TopAllocations) != 1 || got.TopAllocations[0].AllocCount != want.TopAllocations[0].AllocCount ||
+			got.TopAllocations[0].TotalAllocBytes != want.TopAllocations[0].TotalAllocBytes {
+			t.Fatalf("sample %d: replayed allocations %+v, recorded %+v", i, got.TopAllocations, want.TopAllocations)
+		}
+		if got.SelfOverheadPercent != want.SelfOverheadPercent {
+			t.Fatalf("sample %d: replayed self-overhead %v, recorded %v", i, got.SelfOverheadPercent, want.SelfOverheadPercent)
+		}
+		if len(got.TopRetentions) != 1 || got.TopRetentions[0] != want.TopRetentions[0] {
+			t.Fatalf("sample %d: replayed retentions %+v, recorded %+v", i, got.TopRetentions, want.TopRetentions)
+		}
+	}
+	if last := recorded[len(recorded)-1]; !clock.Now().Equal(last.Timestamp) {
+		t.Fatalf("expected the clock at the last record, got %v", clock.Now())
+	}
+	if got := p.TopAllocations(1)[0]; got.AllocCount != 50 || got.Tag != "orders" {
+		t.Fatalf("unexpected replayed totals: %+v", got)
+	}
+
+	last := replayed[len(replayed)-1]
+	ids := alertIDs(last, p.Suggestions(), cfg)
+	for _, want := range []string{"heap-high", "retention-*[256]uint8-orders"} {
+		if !slices.Contains(ids, want) {
+			t.Fatalf("expected alert %q, got %v", want, ids)
+		}
+	}
+
+	// The same trace under a stricter heap idle threshold.
+	strict := cfg
+	strict.HeapIdleThresholdPercent = 0
+	r, err = profiler.NewTraceReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := profiler.NewProfilerWithOptions(strict, logging.Noop(), profiler.Options{Clock: profiler.NewManualClock(time.Time{})})
+	if _, err := q.Replay(testContext(t), r, profiler.ReplayOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range q.Suggestions() {
+		if s.Kind == profiler.SuggestionHeapIdle {
+			t.Fatalf("expected no heap_idle suggestion with the check disabled, got %+v", s)
+		}
+	}
+}
+
+func TestTraceTruncated(t *testing.T) {
+	var ends []int
+	buf, _ := recordScriptedTrace(t, config.DefaultConfig(), 3, func(size int) { ends = append(ends, size) })
+
+	// A crash while writing the third record.
+	data := buf.Bytes()[:ends[1]+(ends[2]-ends[1])/2]
+	r, err := profiler.NewTraceReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := profiler.NewProfilerWithOptions(config.DefaultConfig(), logging.Noop(), profiler.Options{Clock: profiler.NewManualClock(time.Time{})})
+	n, err := p.Replay(testContext(t), r, profiler.ReplayOptions{})
+	if err != nil || n != 2 || !r.Truncated() {
+		t.Fatalf("expected 2 samples from a truncated trace, got %d (err %v, truncated %v)", n, err, r.Truncated())
+	}
+}